@@ -0,0 +1,209 @@
+// Copyright 2013 bee authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+package migrate
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+
+	beeLogger "github.com/beego/bee/logger"
+)
+
+// sourceChecksum returns the SHA-256 of a migration file.
+// Line endings are normalized so that a checkout on Windows does not count as an edit.
+func sourceChecksum(file string) string {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		beeLogger.Log.Fatalf("Could not read migration file '%s': %s", file, err)
+	}
+	sum := sha256.Sum256(bytes.Replace(content, []byte("\r\n"), []byte("\n"), -1))
+	return hex.EncodeToString(sum[:])
+}
+
+// latestRecords returns the last record of each migration in the migrations table
+func latestRecords(db *sql.DB, dialect MigrationDialect) map[string]MigrationRecord {
+	latest := make(map[string]MigrationRecord)
+	for _, r := range dialect.Migrations(db) {
+		latest[r.Name] = r
+	}
+	return latest
+}
+
+// storedChecksums returns the recorded checksums, or none when the table does not exist yet
+func storedChecksums(db *sql.DB, dialect MigrationDialect) map[string]string {
+	if !dialect.TableExists(db, "migration_checksums") {
+		return map[string]string{}
+	}
+	return dialect.Checksums(db)
+}
+
+// modifiedMigrations returns the applied migrations whose source changed since they were applied
+func modifiedMigrations(db *sql.DB, dialect MigrationDialect, sources []migrationSource) (modified []migrationSource) {
+	latest := latestRecords(db, dialect)
+	sums := storedChecksums(db, dialect)
+	for _, s := range sources {
+		if latest[s.Name].Status != "update" || sums[s.Name] == "" {
+			continue
+		}
+		if sourceChecksum(s.File) != sums[s.Name] {
+			modified = append(modified, s)
+		}
+	}
+	return
+}
+
+// checkChecksums fails when an applied migration was edited after it was applied.
+// With -repair the current sources are accepted as the new baseline instead.
+func checkChecksums(db *sql.DB, dialect MigrationDialect, sources []migrationSource) {
+	if mRepair && !mDryRun {
+		repairChecksums(db, dialect, sources)
+		return
+	}
+	modified := modifiedMigrations(db, dialect, sources)
+	if len(modified) == 0 {
+		return
+	}
+	for _, s := range modified {
+		beeLogger.Log.Warnf("Migration '%s' was modified after it was applied: %s", s.Name, s.File)
+	}
+	if mDryRun {
+		return
+	}
+	releaseLock()
+	beeLogger.Log.Hint("Revert the changes, or run 'bee migrate -repair' to accept them as the new baseline")
+	beeLogger.Log.Fatal("Applied migrations do not match their recorded checksums")
+}
+
+// recordChecksums records the checksum of newly applied migrations
+// and forgets the ones of rolled back migrations.
+func recordChecksums(db *sql.DB, dialect MigrationDialect, sources []migrationSource) {
+	latest := latestRecords(db, dialect)
+	sums := dialect.Checksums(db)
+	for _, s := range sources {
+		applied := latest[s.Name].Status == "update"
+		if applied && sums[s.Name] == "" {
+			dialect.SaveChecksum(db, s.Name, sourceChecksum(s.File))
+		} else if !applied && sums[s.Name] != "" {
+			dialect.SaveChecksum(db, s.Name, "")
+		}
+	}
+}
+
+// repairChecksums records the current checksum of every applied migration
+func repairChecksums(db *sql.DB, dialect MigrationDialect, sources []migrationSource) {
+	latest := latestRecords(db, dialect)
+	sums := dialect.Checksums(db)
+	repaired := 0
+	for _, s := range sources {
+		checksum := ""
+		if latest[s.Name].Status == "update" {
+			checksum = sourceChecksum(s.File)
+		}
+		if checksum != sums[s.Name] {
+			dialect.SaveChecksum(db, s.Name, checksum)
+			repaired++
+		}
+	}
+	beeLogger.Log.Infof("Re-baselined %d migration checksum(s)", repaired)
+}
+
+// MigrateStatus prints the state of each migration and returns the number of
+// applied migrations that were modified since they were applied
+func MigrateStatus(currpath, driver, connStr, dir string) int {
+	dialect := getDialect(driver)
+	db, err := sql.Open(sqlDriverName(driver), connStr)
+	if err != nil {
+		beeLogger.Log.Fatalf("Could not connect to database using '%s': %s", connStr, err)
+	}
+	defer db.Close()
+	_, source := migrationProgram()
+	sources := readMigrationSources(dir, source)
+
+	latest := map[string]MigrationRecord{}
+	sums := map[string]string{}
+	if dialect.TableExists(db, "migrations") {
+		if mRepair {
+			// repairing writes to the migrations tables, like a migration run
+			lockMigrations(db, dialect)
+			checkForSchemaUpdateTable(db, dialect)
+			repairChecksums(db, dialect, sources)
+			releaseLock()
+		}
+		latest = latestRecords(db, dialect)
+		sums = storedChecksums(db, dialect)
+	}
+
+	modified := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "MIGRATION\tSTATUS\tDATE\tCHECKSUM\tFILE")
+	known := make(map[string]bool)
+	for _, s := range sources {
+		known[s.Name] = true
+		for _, name := range s.Squashed {
+			known[name] = true
+		}
+		r := latest[s.Name]
+		status, checksum := "pending", "-"
+		if r.Status == "" && len(s.Squashed) > 0 && appliedCount(latest, s.Squashed) == len(s.Squashed) {
+			// recorded by the next migration run
+			status, checksum = "applied", "baseline"
+		}
+		switch r.Status {
+		case "update":
+			status, checksum = "applied", "untracked"
+			if sums[s.Name] != "" {
+				checksum = "ok"
+				if sourceChecksum(s.File) != sums[s.Name] {
+					checksum = "modified"
+					modified++
+				}
+			}
+		case "rollback":
+			status = "rolled back"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", s.Name, status, dash(r.CreatedAt), checksum, filepath.Base(s.File))
+	}
+	var orphans []string
+	for name, r := range latest {
+		if !known[name] && r.Status == "update" {
+			orphans = append(orphans, name)
+		}
+	}
+	sort.Strings(orphans)
+	for _, name := range orphans {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", name, "applied", latest[name].CreatedAt, "-", "missing")
+	}
+	w.Flush()
+
+	if modified > 0 {
+		beeLogger.Log.Warnf("%d applied migration(s) were modified after they were applied", modified)
+		beeLogger.Log.Hint("Revert the changes, or run 'bee migrate status -repair' to accept them as the new baseline")
+	}
+	return modified
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
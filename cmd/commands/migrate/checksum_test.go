@@ -0,0 +1,144 @@
+// Copyright 2013 bee authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package migrate
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// tempDir creates a temporary directory, removed by the returned function
+func tempDir(t *testing.T) (string, func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "bee-migrate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+// writeFile writes content to name in dir and returns its path
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	file := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(file, []byte(content), 0666); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+// sqliteDB opens an empty SQLite database in dir
+func sqliteDB(t *testing.T, dir string) *sql.DB {
+	t.Helper()
+	db, err := sql.Open(sqlDriverName("sqlite"), filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestSourceChecksum(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	unix := writeFile(t, dir, "unix.go", "package main\n\nfunc main() {}\n")
+	windows := writeFile(t, dir, "windows.go", "package main\r\n\r\nfunc main() {}\r\n")
+	edited := writeFile(t, dir, "edited.go", "package main\n\nfunc main() { println() }\n")
+
+	if sourceChecksum(unix) != sourceChecksum(windows) {
+		t.Error("line endings changed the checksum")
+	}
+	if sourceChecksum(unix) == sourceChecksum(edited) {
+		t.Error("an edit did not change the checksum")
+	}
+	if len(sourceChecksum(unix)) != 64 {
+		t.Errorf("checksum %q is not a hex SHA-256", sourceChecksum(unix))
+	}
+}
+
+func TestModifiedMigrations(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	db := sqliteDB(t, dir)
+	defer db.Close()
+	dialect := getDialect("sqlite")
+
+	sources := []migrationSource{
+		{Name: "CreateUsers_20200101_120000", File: writeFile(t, dir, "users.go", "package main\n")},
+		{Name: "CreatePosts_20200102_120000", File: writeFile(t, dir, "posts.go", "package main\n")},
+		{Name: "CreateTags_20200103_120000", File: writeFile(t, dir, "tags.go", "package main\n")},
+	}
+
+	if sums := storedChecksums(db, dialect); len(sums) != 0 {
+		t.Fatalf("checksums %v recorded before the table exists", sums)
+	}
+	checkForSchemaUpdateTable(db, dialect)
+	for _, s := range sources[:2] {
+		dialect.InsertMigration(db, s.Name, "update", "")
+	}
+	recordChecksums(db, dialect, sources)
+	if sums := dialect.Checksums(db); len(sums) != 2 || sums[sources[2].Name] != "" {
+		t.Fatalf("recorded checksums %v, want those of the 2 applied migrations", sums)
+	}
+	if modified := modifiedMigrations(db, dialect, sources); len(modified) != 0 {
+		t.Fatalf("unchanged migrations reported as modified: %v", modified)
+	}
+
+	writeFile(t, dir, "posts.go", "package main\n\n// edited\n")
+	writeFile(t, dir, "tags.go", "package main\n\n// not applied\n")
+	modified := modifiedMigrations(db, dialect, sources)
+	if len(modified) != 1 || modified[0].Name != sources[1].Name {
+		t.Fatalf("modified migrations %v, want %s only", modified, sources[1].Name)
+	}
+
+	repairChecksums(db, dialect, sources)
+	if modified := modifiedMigrations(db, dialect, sources); len(modified) != 0 {
+		t.Fatalf("repaired migrations reported as modified: %v", modified)
+	}
+
+	dialect.InsertMigration(db, sources[1].Name, "rollback", "")
+	recordChecksums(db, dialect, sources)
+	if sums := dialect.Checksums(db); sums[sources[1].Name] != "" {
+		t.Errorf("checksum of a rolled back migration kept: %v", sums)
+	}
+}
+
+func TestMigrateStatusRepair(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	db := sqliteDB(t, dir)
+	defer db.Close()
+	dialect := getDialect("sqlite")
+	checkForSchemaUpdateTable(db, dialect)
+	source := migrationSource{Name: "CreateUsers_20200101_120000", File: writeFile(t, dir, "users.go", usersMigration)}
+	dialect.InsertMigration(db, source.Name, "update", "")
+	recordChecksums(db, dialect, []migrationSource{source})
+	writeFile(t, dir, "users.go", usersMigration+"\n// edited\n")
+
+	defer func(repair bool) { mRepair = repair }(mRepair)
+	mRepair = true
+	if modified := MigrateStatus(dir, "sqlite", filepath.Join(dir, "test.db"), dir); modified != 0 {
+		t.Errorf("%d modified migrations after a repair", modified)
+	}
+	// the repair took the lock, and released it
+	var locks int
+	if err := db.QueryRow("SELECT COUNT(*) FROM migrations_lock").Scan(&locks); err != nil || locks != 0 {
+		t.Errorf("migrations lock not released: %d rows, %v", locks, err)
+	}
+}
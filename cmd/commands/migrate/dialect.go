@@ -0,0 +1,632 @@
+// Copyright 2013 bee authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	beeLogger "github.com/beego/bee/logger"
+)
+
+// MigrationDialect manages the 'migrations' bookkeeping table of a DBMS
+type MigrationDialect interface {
+	// TableExists reports whether a bookkeeping table exists
+	TableExists(db *sql.DB, table string) bool
+	// CreateTable creates the migrations table
+	CreateTable(db *sql.DB)
+	// ValidateTable checks the migrations table has the expected columns
+	ValidateTable(db *sql.DB)
+	// Lock takes a database-wide lock held by conn, waiting at most timeout for it.
+	// A negative timeout waits indefinitely.
+	Lock(conn *sql.Conn, timeout time.Duration)
+	// Unlock releases the lock taken by Lock
+	Unlock(conn *sql.Conn)
+	// InsertMigration records a migration with the given status
+	InsertMigration(db *sql.DB, name, status, statements string)
+	// LatestMigration returns the name of the latest applied migration, or an empty string
+	LatestMigration(db *sql.DB) string
+	// Migrations returns every record of the migrations table in insertion order
+	Migrations(db *sql.DB) []MigrationRecord
+	// CreateChecksumTable creates the migration_checksums table if it does not exist
+	CreateChecksumTable(db *sql.DB)
+	// Checksums returns the recorded source checksum of each applied migration
+	Checksums(db *sql.DB) map[string]string
+	// SaveChecksum records the source checksum of a migration. An empty checksum removes it.
+	SaveChecksum(db *sql.DB, name, checksum string)
+	// CreateSeedsTable creates the seeds table if it does not exist
+	CreateSeedsTable(db *sql.DB)
+	// AppliedSeeds returns the names of the seeds already applied
+	AppliedSeeds(db *sql.DB) map[string]bool
+	// InsertSeed records a seed as applied, as part of tx
+	InsertSeed(tx *sql.Tx, name string)
+	// RenameMigration renames every record of a migration
+	RenameMigration(db *sql.DB, from, to string)
+}
+
+// MigrationRecord is a row of the migrations table
+type MigrationRecord struct {
+	Name      string
+	CreatedAt string
+	Status    string
+}
+
+// MysqlDialect is the MySQL version of MigrationDialect
+type MysqlDialect struct {
+}
+
+// PostgresDialect is the PostgreSQL version of MigrationDialect
+type PostgresDialect struct {
+}
+
+// SQLiteDialect is the SQLite version of MigrationDialect
+type SQLiteDialect struct {
+}
+
+// migrationDialects maps a DBMS name to its version of MigrationDialect
+var migrationDialects = map[string]MigrationDialect{
+	"mysql":    &MysqlDialect{},
+	"postgres": &PostgresDialect{},
+	"sqlite":   &SQLiteDialect{},
+	"sqlite3":  &SQLiteDialect{},
+}
+
+// lockName identifies the lock taken around a migration run
+const lockName = "bee_migrations"
+
+// lockPollInterval is how often a lock that cannot block is retried
+const lockPollInterval = 500 * time.Millisecond
+
+// getDialect returns the MigrationDialect of a driver
+func getDialect(driver string) MigrationDialect {
+	d, ok := migrationDialects[driver]
+	if !ok {
+		beeLogger.Log.Fatalf("Driver '%s' is not supported. Must be either mysql, postgres or sqlite", driver)
+	}
+	return d
+}
+
+// TableExists for MySQL
+func (*MysqlDialect) TableExists(db *sql.DB, table string) bool {
+	return hasRows(db, "SELECT table_name FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?", table)
+}
+
+// CreateTable for MySQL
+func (*MysqlDialect) CreateTable(db *sql.DB) {
+	createTable(db, MYSQLMigrationDDL)
+}
+
+// ValidateTable for MySQL checks the output of DESC migrations
+func (*MysqlDialect) ValidateTable(db *sql.DB) {
+	rows, err := db.Query("DESC migrations")
+	if err != nil {
+		beeLogger.Log.Fatalf("Could not show columns of migrations table: %s", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var fieldBytes, typeBytes, nullBytes, keyBytes, defaultBytes, extraBytes []byte
+		if err := rows.Scan(&fieldBytes, &typeBytes, &nullBytes, &keyBytes, &defaultBytes, &extraBytes); err != nil {
+			beeLogger.Log.Fatalf("Could not read column information: %s", err)
+		}
+		fieldStr, typeStr, nullStr, keyStr, defaultStr, extraStr :=
+			string(fieldBytes), string(typeBytes), string(nullBytes), string(keyBytes), string(defaultBytes), string(extraBytes)
+		if fieldStr == "id_migration" {
+			if keyStr != "PRI" || extraStr != "auto_increment" {
+				beeLogger.Log.Hint("Expecting KEY: PRI, EXTRA: auto_increment")
+				beeLogger.Log.Fatalf("Column migration.id_migration type mismatch: KEY: %s, EXTRA: %s", keyStr, extraStr)
+			}
+		} else if fieldStr == "name" {
+			if !strings.HasPrefix(typeStr, "varchar") || nullStr != "YES" {
+				beeLogger.Log.Hint("Expecting TYPE: varchar, NULL: YES")
+				beeLogger.Log.Fatalf("Column migration.name type mismatch: TYPE: %s, NULL: %s", typeStr, nullStr)
+			}
+		} else if fieldStr == "created_at" {
+			if typeStr != "timestamp" || defaultStr != "CURRENT_TIMESTAMP" {
+				beeLogger.Log.Hint("Expecting TYPE: timestamp, DEFAULT: CURRENT_TIMESTAMP")
+				beeLogger.Log.Fatalf("Column migration.timestamp type mismatch: TYPE: %s, DEFAULT: %s", typeStr, defaultStr)
+			}
+		}
+	}
+}
+
+// Lock for MySQL uses GET_LOCK, which is held by the session of conn
+func (*MysqlDialect) Lock(conn *sql.Conn, timeout time.Duration) {
+	seconds := int64(timeout / time.Second)
+	if timeout < 0 {
+		seconds = -1
+	}
+	var acquired sql.NullInt64
+	if err := conn.QueryRowContext(context.Background(), "SELECT GET_LOCK(?, ?)", lockName, seconds).Scan(&acquired); err != nil {
+		beeLogger.Log.Fatalf("Could not lock migrations: %s", err)
+	}
+	if acquired.Int64 != 1 {
+		lockTimedOut(timeout)
+	}
+}
+
+// Unlock for MySQL
+func (*MysqlDialect) Unlock(conn *sql.Conn) {
+	if _, err := conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", lockName); err != nil {
+		beeLogger.Log.Warnf("Could not unlock migrations: %s", err)
+	}
+}
+
+// InsertMigration for MySQL
+func (*MysqlDialect) InsertMigration(db *sql.DB, name, status, statements string) {
+	insertMigration(db, "INSERT INTO migrations(name, created_at, statements, status) VALUES(?, ?, ?, ?)", name, status, statements)
+}
+
+// LatestMigration for MySQL
+func (*MysqlDialect) LatestMigration(db *sql.DB) string {
+	return latestMigration(db)
+}
+
+// Migrations for MySQL
+func (*MysqlDialect) Migrations(db *sql.DB) []MigrationRecord {
+	return migrationRecords(db)
+}
+
+// CreateChecksumTable for MySQL
+func (*MysqlDialect) CreateChecksumTable(db *sql.DB) {
+	if _, err := db.Exec(MYSQLChecksumDDL); err != nil {
+		beeLogger.Log.Fatalf("Could not create migration_checksums table: %s", err)
+	}
+}
+
+// Checksums for MySQL
+func (*MysqlDialect) Checksums(db *sql.DB) map[string]string {
+	return checksums(db)
+}
+
+// SaveChecksum for MySQL
+func (*MysqlDialect) SaveChecksum(db *sql.DB, name, checksum string) {
+	saveChecksum(db, "DELETE FROM migration_checksums WHERE name = ?", "INSERT INTO migration_checksums(name, checksum) VALUES(?, ?)", name, checksum)
+}
+
+// CreateSeedsTable for MySQL
+func (*MysqlDialect) CreateSeedsTable(db *sql.DB) {
+	if _, err := db.Exec(MYSQLSeedsDDL); err != nil {
+		beeLogger.Log.Fatalf("Could not create seeds table: %s", err)
+	}
+}
+
+// AppliedSeeds for MySQL
+func (*MysqlDialect) AppliedSeeds(db *sql.DB) map[string]bool {
+	return appliedSeeds(db)
+}
+
+// InsertSeed for MySQL
+func (*MysqlDialect) InsertSeed(tx *sql.Tx, name string) {
+	if _, err := tx.Exec("INSERT INTO seeds(name) VALUES(?)", name); err != nil {
+		beeLogger.Log.Fatalf("Could not record seed '%s': %s", name, err)
+	}
+}
+
+// RenameMigration for MySQL
+func (*MysqlDialect) RenameMigration(db *sql.DB, from, to string) {
+	renameMigration(db, "UPDATE migrations SET name = ? WHERE name = ?", from, to)
+}
+
+// TableExists for PostgreSQL looks for the table in the current schema
+func (*PostgresDialect) TableExists(db *sql.DB, table string) bool {
+	return hasRows(db, `SELECT table_name FROM information_schema.tables
+		WHERE table_schema = current_schema() AND table_name = $1`, table)
+}
+
+// CreateTable for PostgreSQL
+func (*PostgresDialect) CreateTable(db *sql.DB) {
+	createTable(db, POSTGRESMigrationDDL)
+}
+
+// ValidateTable for PostgreSQL checks the columns from information_schema
+func (*PostgresDialect) ValidateTable(db *sql.DB) {
+	rows, err := db.Query(`
+		SELECT
+			c.column_name, c.data_type, c.is_nullable, COALESCE(c.column_default, ''),
+			EXISTS (
+				SELECT 1 FROM information_schema.table_constraints tc
+				INNER JOIN information_schema.key_column_usage u
+					ON tc.constraint_name = u.constraint_name AND tc.table_schema = u.table_schema
+				WHERE tc.constraint_type = 'PRIMARY KEY' AND tc.table_schema = c.table_schema
+					AND tc.table_name = c.table_name AND u.column_name = c.column_name
+			)
+		FROM information_schema.columns c
+		WHERE c.table_schema = current_schema() AND c.table_name = 'migrations'`)
+	if err != nil {
+		beeLogger.Log.Fatalf("Could not show columns of migrations table: %s", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var fieldStr, typeStr, nullStr, defaultStr string
+		var isPk bool
+		if err := rows.Scan(&fieldStr, &typeStr, &nullStr, &defaultStr, &isPk); err != nil {
+			beeLogger.Log.Fatalf("Could not read column information: %s", err)
+		}
+		if fieldStr == "id_migration" {
+			if !isPk || !strings.HasPrefix(defaultStr, "nextval(") {
+				beeLogger.Log.Hint("Expecting TYPE: serial, PRIMARY KEY")
+				beeLogger.Log.Fatalf("Column migration.id_migration type mismatch: TYPE: %s, DEFAULT: %s", typeStr, defaultStr)
+			}
+		} else if fieldStr == "name" {
+			if typeStr != "character varying" || nullStr != "YES" {
+				beeLogger.Log.Hint("Expecting TYPE: character varying, NULL: YES")
+				beeLogger.Log.Fatalf("Column migration.name type mismatch: TYPE: %s, NULL: %s", typeStr, nullStr)
+			}
+		} else if fieldStr == "created_at" {
+			if !strings.HasPrefix(typeStr, "timestamp") || (defaultStr != "CURRENT_TIMESTAMP" && defaultStr != "now()") {
+				beeLogger.Log.Hint("Expecting TYPE: timestamp, DEFAULT: CURRENT_TIMESTAMP")
+				beeLogger.Log.Fatalf("Column migration.timestamp type mismatch: TYPE: %s, DEFAULT: %s", typeStr, defaultStr)
+			}
+		}
+	}
+}
+
+// Lock for PostgreSQL uses a session level advisory lock held by conn
+func (*PostgresDialect) Lock(conn *sql.Conn, timeout time.Duration) {
+	ctx := context.Background()
+	if timeout < 0 {
+		if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock(hashtext($1))", lockName); err != nil {
+			beeLogger.Log.Fatalf("Could not lock migrations: %s", err)
+		}
+		return
+	}
+	pollLock(timeout, func() bool {
+		var acquired bool
+		if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock(hashtext($1))", lockName).Scan(&acquired); err != nil {
+			beeLogger.Log.Fatalf("Could not lock migrations: %s", err)
+		}
+		return acquired
+	})
+}
+
+// Unlock for PostgreSQL
+func (*PostgresDialect) Unlock(conn *sql.Conn) {
+	if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock(hashtext($1))", lockName); err != nil {
+		beeLogger.Log.Warnf("Could not unlock migrations: %s", err)
+	}
+}
+
+// InsertMigration for PostgreSQL
+func (*PostgresDialect) InsertMigration(db *sql.DB, name, status, statements string) {
+	insertMigration(db, "INSERT INTO migrations(name, created_at, statements, status) VALUES($1, $2, $3, $4)", name, status, statements)
+}
+
+// LatestMigration for PostgreSQL
+func (*PostgresDialect) LatestMigration(db *sql.DB) string {
+	return latestMigration(db)
+}
+
+// Migrations for PostgreSQL
+func (*PostgresDialect) Migrations(db *sql.DB) []MigrationRecord {
+	return migrationRecords(db)
+}
+
+// CreateChecksumTable for PostgreSQL
+func (*PostgresDialect) CreateChecksumTable(db *sql.DB) {
+	if _, err := db.Exec(POSTGRESChecksumDDL); err != nil {
+		beeLogger.Log.Fatalf("Could not create migration_checksums table: %s", err)
+	}
+}
+
+// Checksums for PostgreSQL
+func (*PostgresDialect) Checksums(db *sql.DB) map[string]string {
+	return checksums(db)
+}
+
+// SaveChecksum for PostgreSQL
+func (*PostgresDialect) SaveChecksum(db *sql.DB, name, checksum string) {
+	saveChecksum(db, "DELETE FROM migration_checksums WHERE name = $1", "INSERT INTO migration_checksums(name, checksum) VALUES($1, $2)", name, checksum)
+}
+
+// CreateSeedsTable for PostgreSQL
+func (*PostgresDialect) CreateSeedsTable(db *sql.DB) {
+	if _, err := db.Exec(POSTGRESSeedsDDL); err != nil {
+		beeLogger.Log.Fatalf("Could not create seeds table: %s", err)
+	}
+}
+
+// AppliedSeeds for PostgreSQL
+func (*PostgresDialect) AppliedSeeds(db *sql.DB) map[string]bool {
+	return appliedSeeds(db)
+}
+
+// InsertSeed for PostgreSQL
+func (*PostgresDialect) InsertSeed(tx *sql.Tx, name string) {
+	if _, err := tx.Exec("INSERT INTO seeds(name) VALUES($1)", name); err != nil {
+		beeLogger.Log.Fatalf("Could not record seed '%s': %s", name, err)
+	}
+}
+
+// RenameMigration for PostgreSQL
+func (*PostgresDialect) RenameMigration(db *sql.DB, from, to string) {
+	renameMigration(db, "UPDATE migrations SET name = $1 WHERE name = $2", from, to)
+}
+
+// TableExists for SQLite
+func (*SQLiteDialect) TableExists(db *sql.DB, table string) bool {
+	return hasRows(db, "SELECT name FROM sqlite_master WHERE type = 'table' AND name = ?", table)
+}
+
+// CreateTable for SQLite
+func (*SQLiteDialect) CreateTable(db *sql.DB) {
+	createTable(db, SQLiteMigrationDDL)
+}
+
+// ValidateTable for SQLite checks the columns using PRAGMA table_info
+func (*SQLiteDialect) ValidateTable(db *sql.DB) {
+	rows, err := db.Query("PRAGMA table_info(migrations)")
+	if err != nil {
+		beeLogger.Log.Fatalf("Could not show columns of migrations table: %s", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var cid, notNull, pk int
+		var fieldStr, typeStr string
+		var defaultStr sql.NullString
+		if err := rows.Scan(&cid, &fieldStr, &typeStr, &notNull, &defaultStr, &pk); err != nil {
+			beeLogger.Log.Fatalf("Could not read column information: %s", err)
+		}
+		typeStr = strings.ToLower(typeStr)
+		if fieldStr == "id_migration" {
+			if pk != 1 || typeStr != "integer" {
+				beeLogger.Log.Hint("Expecting TYPE: INTEGER, PRIMARY KEY")
+				beeLogger.Log.Fatalf("Column migration.id_migration type mismatch: TYPE: %s, PK: %d", typeStr, pk)
+			}
+		} else if fieldStr == "name" {
+			if !strings.HasPrefix(typeStr, "varchar") || notNull != 0 {
+				beeLogger.Log.Hint("Expecting TYPE: varchar, NULL: YES")
+				beeLogger.Log.Fatalf("Column migration.name type mismatch: TYPE: %s, NOT NULL: %d", typeStr, notNull)
+			}
+		} else if fieldStr == "created_at" {
+			if typeStr != "timestamp" || strings.ToUpper(defaultStr.String) != "CURRENT_TIMESTAMP" {
+				beeLogger.Log.Hint("Expecting TYPE: timestamp, DEFAULT: CURRENT_TIMESTAMP")
+				beeLogger.Log.Fatalf("Column migration.timestamp type mismatch: TYPE: %s, DEFAULT: %s", typeStr, defaultStr.String)
+			}
+		}
+	}
+}
+
+// Lock for SQLite inserts the single row of the migrations_lock table.
+// The row records the host and pid of its holder, so that a lock left
+// behind by a process that died on this host can be taken over.
+func (*SQLiteDialect) Lock(conn *sql.Conn, timeout time.Duration) {
+	ctx := context.Background()
+	if _, err := conn.ExecContext(ctx, SQLiteLockDDL); err != nil {
+		beeLogger.Log.Fatalf("Could not create migrations_lock table: %s", err)
+	}
+	host, _ := os.Hostname()
+	holder := fmt.Sprintf("%s:%d", host, os.Getpid())
+	try := func() bool {
+		_, err := conn.ExecContext(ctx, "INSERT INTO migrations_lock(id, holder) VALUES(1, ?)", holder)
+		if err == nil {
+			return true
+		}
+		var current string
+		if err := conn.QueryRowContext(ctx, "SELECT holder FROM migrations_lock WHERE id = 1").Scan(&current); err != nil {
+			return false
+		}
+		if i := strings.LastIndex(current, ":"); i > 0 && current[:i] == host {
+			if pid, err := strconv.Atoi(current[i+1:]); err == nil && !processExists(pid) {
+				beeLogger.Log.Warnf("Removing migrations lock left behind by process %d", pid)
+				conn.ExecContext(ctx, "DELETE FROM migrations_lock WHERE id = 1 AND holder = ?", current)
+			}
+		}
+		return false
+	}
+	if timeout < 0 {
+		for !try() {
+			time.Sleep(lockPollInterval)
+		}
+		return
+	}
+	pollLock(timeout, try)
+}
+
+// Unlock for SQLite
+func (*SQLiteDialect) Unlock(conn *sql.Conn) {
+	if _, err := conn.ExecContext(context.Background(), "DELETE FROM migrations_lock WHERE id = 1"); err != nil {
+		beeLogger.Log.Warnf("Could not unlock migrations: %s", err)
+	}
+}
+
+// InsertMigration for SQLite
+func (*SQLiteDialect) InsertMigration(db *sql.DB, name, status, statements string) {
+	insertMigration(db, "INSERT INTO migrations(name, created_at, statements, status) VALUES(?, ?, ?, ?)", name, status, statements)
+}
+
+// LatestMigration for SQLite
+func (*SQLiteDialect) LatestMigration(db *sql.DB) string {
+	return latestMigration(db)
+}
+
+// Migrations for SQLite
+func (*SQLiteDialect) Migrations(db *sql.DB) []MigrationRecord {
+	return migrationRecords(db)
+}
+
+// CreateChecksumTable for SQLite
+func (*SQLiteDialect) CreateChecksumTable(db *sql.DB) {
+	if _, err := db.Exec(SQLiteChecksumDDL); err != nil {
+		beeLogger.Log.Fatalf("Could not create migration_checksums table: %s", err)
+	}
+}
+
+// Checksums for SQLite
+func (*SQLiteDialect) Checksums(db *sql.DB) map[string]string {
+	return checksums(db)
+}
+
+// SaveChecksum for SQLite
+func (*SQLiteDialect) SaveChecksum(db *sql.DB, name, checksum string) {
+	saveChecksum(db, "DELETE FROM migration_checksums WHERE name = ?", "INSERT INTO migration_checksums(name, checksum) VALUES(?, ?)", name, checksum)
+}
+
+// CreateSeedsTable for SQLite
+func (*SQLiteDialect) CreateSeedsTable(db *sql.DB) {
+	if _, err := db.Exec(SQLiteSeedsDDL); err != nil {
+		beeLogger.Log.Fatalf("Could not create seeds table: %s", err)
+	}
+}
+
+// AppliedSeeds for SQLite
+func (*SQLiteDialect) AppliedSeeds(db *sql.DB) map[string]bool {
+	return appliedSeeds(db)
+}
+
+// InsertSeed for SQLite
+func (*SQLiteDialect) InsertSeed(tx *sql.Tx, name string) {
+	if _, err := tx.Exec("INSERT INTO seeds(name) VALUES(?)", name); err != nil {
+		beeLogger.Log.Fatalf("Could not record seed '%s': %s", name, err)
+	}
+}
+
+// RenameMigration for SQLite
+func (*SQLiteDialect) RenameMigration(db *sql.DB, from, to string) {
+	renameMigration(db, "UPDATE migrations SET name = ? WHERE name = ?", from, to)
+}
+
+// hasRows reports whether a query returns at least one row
+func hasRows(db *sql.DB, query string, args ...interface{}) bool {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		beeLogger.Log.Fatalf("Could not show tables: %s", err)
+	}
+	defer rows.Close()
+	return rows.Next()
+}
+
+func createTable(db *sql.DB, ddl string) {
+	beeLogger.Log.Infof("Creating 'migrations' table...")
+	if _, err := db.Exec(ddl); err != nil {
+		beeLogger.Log.Fatalf("Could not create migrations table: %s", err)
+	}
+}
+
+func insertMigration(db *sql.DB, query, name, status, statements string) {
+	if _, err := db.Exec(query, name, time.Now().Format("2006-01-02 15:04:05"), statements, status); err != nil {
+		beeLogger.Log.Fatalf("Could not record migration '%s': %s", name, err)
+	}
+}
+
+func latestMigration(db *sql.DB) (name string) {
+	err := db.QueryRow("SELECT name FROM migrations WHERE status = 'update' ORDER BY id_migration DESC LIMIT 1").Scan(&name)
+	if err != nil && err != sql.ErrNoRows {
+		beeLogger.Log.Fatalf("Could not retrieve migrations: %s", err)
+	}
+	return
+}
+
+func migrationRecords(db *sql.DB) (records []MigrationRecord) {
+	rows, err := db.Query("SELECT name, created_at, status FROM migrations ORDER BY id_migration")
+	if err != nil {
+		beeLogger.Log.Fatalf("Could not retrieve migrations: %s", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var nameBytes, createdAtBytes, statusBytes []byte
+		if err := rows.Scan(&nameBytes, &createdAtBytes, &statusBytes); err != nil {
+			beeLogger.Log.Fatalf("Could not read migrations in database: %s", err)
+		}
+		records = append(records, MigrationRecord{string(nameBytes), string(createdAtBytes), string(statusBytes)})
+	}
+	return
+}
+
+func checksums(db *sql.DB) map[string]string {
+	rows, err := db.Query("SELECT name, checksum FROM migration_checksums")
+	if err != nil {
+		beeLogger.Log.Fatalf("Could not retrieve migration checksums: %s", err)
+	}
+	defer rows.Close()
+	sums := make(map[string]string)
+	for rows.Next() {
+		var name, checksum string
+		if err := rows.Scan(&name, &checksum); err != nil {
+			beeLogger.Log.Fatalf("Could not read migration checksums: %s", err)
+		}
+		sums[name] = checksum
+	}
+	return sums
+}
+
+func saveChecksum(db *sql.DB, deleteQuery, insertQuery, name, checksum string) {
+	if _, err := db.Exec(deleteQuery, name); err != nil {
+		beeLogger.Log.Fatalf("Could not remove checksum of migration '%s': %s", name, err)
+	}
+	if checksum == "" {
+		return
+	}
+	if _, err := db.Exec(insertQuery, name, checksum); err != nil {
+		beeLogger.Log.Fatalf("Could not record checksum of migration '%s': %s", name, err)
+	}
+}
+
+func renameMigration(db *sql.DB, query, from, to string) {
+	if _, err := db.Exec(query, to, from); err != nil {
+		beeLogger.Log.Fatalf("Could not rename migration '%s': %s", from, err)
+	}
+}
+
+func appliedSeeds(db *sql.DB) map[string]bool {
+	rows, err := db.Query("SELECT name FROM seeds")
+	if err != nil {
+		beeLogger.Log.Fatalf("Could not retrieve seeds: %s", err)
+	}
+	defer rows.Close()
+	seeds := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			beeLogger.Log.Fatalf("Could not read seeds: %s", err)
+		}
+		seeds[name] = true
+	}
+	return seeds
+}
+
+// pollLock calls try until it succeeds or timeout elapses
+func pollLock(timeout time.Duration, try func() bool) {
+	deadline := time.Now().Add(timeout)
+	for !try() {
+		if !time.Now().Before(deadline) {
+			lockTimedOut(timeout)
+		}
+		time.Sleep(lockPollInterval)
+	}
+}
+
+// processExists reports whether a process with the given pid runs on this host
+func processExists(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		return true
+	}
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || err == syscall.EPERM
+}
+
+func lockTimedOut(timeout time.Duration) {
+	beeLogger.Log.Hint("Another migration is running against this database. Wait for it to finish or raise the lock timeout")
+	beeLogger.Log.Fatalf("Could not lock migrations within %s", timeout)
+}
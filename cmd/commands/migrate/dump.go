@@ -0,0 +1,171 @@
+// Copyright 2013 bee authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+package migrate
+
+import (
+	"bytes"
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	beeLogger "github.com/beego/bee/logger"
+)
+
+// SchemaDumper returns the CREATE statements of the tables of a database.
+// It is set by the generate package, which owns the database introspection.
+var SchemaDumper func(driver string, db *sql.DB) []string
+
+// SchemaCatalog returns the objects of the schema of a database as its catalog lists them,
+// one line per object, so that two databases can be compared. It is set with SchemaDumper.
+var SchemaCatalog func(driver string, db *sql.DB) []string
+
+// schemaMigrationPrefix marks the lines listing the applied migrations in a schema file
+const schemaMigrationPrefix = "-- migration: "
+
+const schemaHeader = `-- Database schema generated by 'bee migrate dump'. Do not edit it by hand.
+-- Use 'bee migrate load' to create a fresh database from it.
+`
+
+// MigrateDump writes the schema of the database and the applied migrations to file
+func MigrateDump(currpath, driver, connStr, file string) {
+	if SchemaDumper == nil {
+		beeLogger.Log.Fatal("Dumping the schema is not available in this build")
+	}
+	dialect := getDialect(driver)
+	db, err := sql.Open(sqlDriverName(driver), connStr)
+	if err != nil {
+		beeLogger.Log.Fatalf("Could not connect to database using '%s': %s", connStr, err)
+	}
+	defer db.Close()
+
+	var buf bytes.Buffer
+	buf.WriteString(schemaHeader)
+	for _, stmt := range SchemaDumper(driver, db) {
+		buf.WriteString("\n" + stmt + ";\n")
+	}
+	buf.WriteString("\n-- Applied migrations\n")
+	if dialect.TableExists(db, "migrations") {
+		for _, name := range appliedMigrations(db, dialect) {
+			buf.WriteString(schemaMigrationPrefix + name + "\n")
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), 0777); err != nil {
+		beeLogger.Log.Fatalf("Could not create directory: %s", err)
+	}
+	if err := ioutil.WriteFile(file, buf.Bytes(), 0666); err != nil {
+		beeLogger.Log.Fatalf("Could not write schema file: %s", err)
+	}
+	beeLogger.Log.Infof("Schema written to '%s'", file)
+}
+
+// MigrateLoad creates the tables of a schema file in an empty database and records
+// its migrations as applied, without running them
+func MigrateLoad(currpath, driver, connStr, dir, file string) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		beeLogger.Log.Fatalf("Could not read schema file: %s", err)
+	}
+	statements, migrations := parseSchemaFile(string(content))
+
+	dialect := getDialect(driver)
+	db, err := sql.Open(sqlDriverName(driver), connStr)
+	if err != nil {
+		beeLogger.Log.Fatalf("Could not connect to database using '%s': %s", connStr, err)
+	}
+	defer db.Close()
+	lockMigrations(db, dialect)
+	defer releaseLock()
+	if dialect.TableExists(db, "migrations") && dialect.LatestMigration(db) != "" {
+		releaseLock()
+		beeLogger.Log.Hint("Load the schema into a new database, or use 'bee migrate' to update this one")
+		beeLogger.Log.Fatal("The database already has applied migrations")
+	}
+
+	for _, stmt := range statements {
+		if _, err := db.Exec(stmt); err != nil {
+			releaseLock()
+			beeLogger.Log.Fatalf("Could not execute '%s': %s", stmt, err)
+		}
+	}
+	checkForSchemaUpdateTable(db, dialect)
+	for _, name := range migrations {
+		dialect.InsertMigration(db, name, "update", "")
+	}
+	_, source := migrationProgram()
+	recordChecksums(db, dialect, readMigrationSources(dir, source))
+	beeLogger.Log.Infof("Loaded %d table statement(s) and %d migration(s) from '%s'", len(statements), len(migrations), file)
+}
+
+// appliedMigrations returns the names of the applied migrations, oldest first
+func appliedMigrations(db *sql.DB, dialect MigrationDialect) (names []string) {
+	for name, r := range latestRecords(db, dialect) {
+		if r.Status == "update" {
+			names = append(names, name)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if ti, tj := migrationTimestamp(names[i]), migrationTimestamp(names[j]); ti != tj {
+			return ti < tj
+		}
+		return names[i] < names[j]
+	})
+	return
+}
+
+// migrationTimestamp returns the timestamp suffix of a migration name
+func migrationTimestamp(name string) string {
+	if len(name) < len(migrationDateFormat) {
+		return name
+	}
+	return name[len(name)-len(migrationDateFormat):]
+}
+
+// parseSchemaFile splits a schema file into its statements and applied migrations
+func parseSchemaFile(content string) (statements, migrations []string) {
+	var rest []string
+	for _, line := range strings.Split(strings.Replace(content, "\r\n", "\n", -1), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, schemaMigrationPrefix) {
+			migrations = append(migrations, strings.TrimSpace(strings.TrimPrefix(trimmed, schemaMigrationPrefix)))
+			continue
+		}
+		rest = append(rest, line)
+	}
+	return splitStatements(strings.Join(rest, "\n")), migrations
+}
+
+// splitStatements splits SQL into statements ending with a semicolon at the end of a line.
+// Lines starting with -- are comments.
+func splitStatements(content string) (statements []string) {
+	var stmt []string
+	for _, line := range strings.Split(strings.Replace(content, "\r\n", "\n", -1), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		stmt = append(stmt, line)
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSuffix(strings.TrimSpace(strings.Join(stmt, "\n")), ";"))
+			stmt = nil
+		}
+	}
+	if len(stmt) > 0 {
+		statements = append(statements, strings.TrimSpace(strings.Join(stmt, "\n")))
+	}
+	return
+}
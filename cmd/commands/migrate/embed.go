@@ -0,0 +1,525 @@
+// Copyright 2013 bee authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+package migrate
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	beeLogger "github.com/beego/bee/logger"
+	"github.com/beego/bee/utils"
+)
+
+// embeddedHeader starts every file of the package embedding the migrations
+const embeddedHeader = "// Code generated by bee migrate embed. DO NOT EDIT.\n\n"
+
+var goModModuleRegex = regexp.MustCompile(`(?m)^module\s+"?([^"\s]+)"?`)
+
+// MigrateEmbed generates a package in pkgDir which registers the migrations of dir and
+// runs them from within the application, so that no Go toolchain is needed where it runs.
+// Files of a previous generation are replaced.
+func MigrateEmbed(currpath, driver, dir, pkgDir string) {
+	pkg := filepath.Base(pkgDir)
+	if !token.IsIdentifier(pkg) {
+		beeLogger.Log.Fatalf("'%s' is not a valid package name", pkg)
+	}
+	_, source := migrationProgram()
+	sources := readMigrationSources(dir, source)
+	if len(sources) == 0 {
+		beeLogger.Log.Warnf("No migrations found in '%s'", dir)
+	}
+
+	if err := os.MkdirAll(pkgDir, 0777); err != nil {
+		beeLogger.Log.Fatalf("Could not create directory: %s", err)
+	}
+	removeEmbeddedFiles(pkgDir)
+	// every file is copied, as migrations may share helpers
+	files, _ := filepath.Glob(filepath.Join(dir, "*.go"))
+	for _, file := range files {
+		if filepath.Base(file) == source || strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		writeEmbeddedFile(filepath.Join(pkgDir, filepath.Base(file)), embeddedMigration(file, pkg))
+	}
+
+	writeEmbeddedFile(filepath.Join(pkgDir, "migrate.go"), []byte(embeddedSourceCode(pkg, driver, sources)))
+
+	beeLogger.Log.Infof("Embedded %d migration(s) in '%s'", len(sources), pkgDir)
+	beeLogger.Log.Hintf("Import \"%s\" and call %s.Main() first thing in main(), then run './app migrate [up|down|status|...]'",
+		packageImportPath(currpath, pkgDir), pkg)
+}
+
+// embeddedSourceCode renders the source of the package embedding the migrations
+func embeddedSourceCode(pkg, driver string, sources []migrationSource) string {
+	content := strings.Replace(MigrationEmbedTPL+MigrationRunnerTPL, "{{Package}}", pkg, -1)
+	content = strings.Replace(content, "{{DBDriver}}", sqlDriverName(driver), -1)
+	content = strings.Replace(content, "{{DriverRepo}}", driverImportStatement(driver), -1)
+	content = strings.Replace(content, "{{MySQLDDL}}", strconv.Quote(MYSQLMigrationDDL), -1)
+	content = strings.Replace(content, "{{PostgresDDL}}", strconv.Quote(POSTGRESMigrationDDL), -1)
+	content = strings.Replace(content, "{{SQLiteDDL}}", strconv.Quote(SQLiteMigrationDDL), -1)
+	content = strings.Replace(content, "{{SQLiteLockDDL}}", strconv.Quote(SQLiteLockDDL), -1)
+	content = strings.Replace(content, "{{LockName}}", lockName, -1)
+	content = strings.Replace(content, "{{LockTimeout}}", fmt.Sprintf("%d * time.Second", defaultLockTimeout/time.Second), -1)
+	content = strings.Replace(content, "{{Migrations}}", migrationSourcesCode(sources), -1)
+	content = strings.Replace(content, "{{Baselines}}", baselinesCode(sources), -1)
+	return content
+}
+
+// embeddedMigration returns the source of a migration file moved to package pkg
+func embeddedMigration(file, pkg string) []byte {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, file, nil, parser.ParseComments)
+	if err != nil {
+		beeLogger.Log.Fatalf("Could not parse migration file '%s': %s", file, err)
+	}
+	f.Name.Name = pkg
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, f); err != nil {
+		beeLogger.Log.Fatalf("Could not format migration file '%s': %s", file, err)
+	}
+	return buf.Bytes()
+}
+
+// writeEmbeddedFile writes a generated file of the embedding package
+func writeEmbeddedFile(file string, content []byte) {
+	content = append([]byte(embeddedHeader), content...)
+	if formatted, err := format.Source(content); err == nil {
+		content = formatted
+	}
+	if err := ioutil.WriteFile(file, content, 0666); err != nil {
+		beeLogger.Log.Fatalf("Could not write file '%s': %s", file, err)
+	}
+}
+
+// removeEmbeddedFiles removes the files generated in pkgDir before, so that deleted
+// migrations do not linger. Other files are left alone.
+func removeEmbeddedFiles(pkgDir string) {
+	files, _ := filepath.Glob(filepath.Join(pkgDir, "*.go"))
+	for _, file := range files {
+		content, err := ioutil.ReadFile(file)
+		if err == nil && bytes.HasPrefix(content, []byte(embeddedHeader)) {
+			if err := os.Remove(file); err != nil {
+				beeLogger.Log.Warnf("Could not remove '%s': %s", file, err)
+			}
+		}
+	}
+}
+
+// baselinesCode renders the migrations squashed into each baseline as entries of a map
+func baselinesCode(sources []migrationSource) string {
+	var buf bytes.Buffer
+	for _, s := range sources {
+		if len(s.Squashed) == 0 {
+			continue
+		}
+		names := make([]string, len(s.Squashed))
+		for i, name := range s.Squashed {
+			names[i] = strconv.Quote(name)
+		}
+		fmt.Fprintf(&buf, "\t%q: {%s},\n", s.Name, strings.Join(names, ", "))
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// packageImportPath returns the import path of dir according to the go.mod of the application,
+// or dir relative to the application when there is none
+func packageImportPath(currpath, dir string) string {
+	rel, err := filepath.Rel(currpath, dir)
+	if err != nil {
+		rel = dir
+	}
+	rel = filepath.ToSlash(rel)
+	if content, err := ioutil.ReadFile(filepath.Join(currpath, "go.mod")); err == nil {
+		if m := goModModuleRegex.FindSubmatch(content); m != nil {
+			return path.Join(string(m[1]), rel)
+		}
+	}
+	if gopath := utils.GetGOPATHs(); len(gopath) > 0 {
+		src := filepath.Join(gopath[0], "src") + string(filepath.Separator)
+		if strings.HasPrefix(dir, src) {
+			return filepath.ToSlash(strings.TrimPrefix(dir, src))
+		}
+	}
+	return rel
+}
+
+// MigrationEmbedTPL is the API of the package embedding the migrations
+const MigrationEmbedTPL = `// Package {{Package}} runs the migrations of the application from within its binary.
+//
+// Call Main first thing in the main function:
+//
+//	func main() {
+//		{{Package}}.Main()
+//		...
+//	}
+//
+// and use './app migrate [up|down|redo|rollback|reset|refresh|status] [-to=20060102_150405] [-steps=N] [-dry-run]'.
+// Migrations use the "default" database of the orm, unless -driver and -conn are given.
+// Runs take the migrations lock 'bee migrate' takes, so that instances of the application
+// starting at the same time do not apply the same migration twice.
+package {{Package}}
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/astaxie/beego/logs"
+	"github.com/astaxie/beego/migration"
+	"github.com/astaxie/beego/orm"
+
+	_ "{{DriverRepo}}"
+)
+
+// source builds one of the embedded migrations
+type source struct {
+	name string
+	new  func() migration.Migrationer
+}
+
+var sources = []source{
+{{Migrations}}
+}
+
+// baselines maps each baseline written by 'bee migrate squash' to the migrations it replaces
+var baselines = map[string][]string{
+{{Baselines}}
+}
+
+// dryRun prints the statements of each migration instead of executing them
+var dryRun bool
+
+// st holds the latest status of every migration, kept up to date while running
+var st = map[string]string{}
+
+// migrationsDDL creates the migrations table of each driver
+var migrationsDDL = map[orm.DriverType]string{
+	orm.DRMySQL:    {{MySQLDDL}},
+	orm.DRPostgres: {{PostgresDDL}},
+	orm.DRSqlite:   {{SQLiteDDL}},
+}
+
+// lockName is the name of the migrations lock, shared with 'bee migrate'
+const lockName = "{{LockName}}"
+
+// lockPollInterval is how often a lock that cannot block is retried
+const lockPollInterval = 500 * time.Millisecond
+
+// Main runs the migrate command and exits when it is the first argument of the program.
+// It returns otherwise.
+func Main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(Run(os.Args[2:]))
+	}
+}
+
+// Run runs a migrate command, i.e. the arguments following 'migrate', and returns the exit code.
+// Without a command it applies every outstanding migration.
+func Run(args []string) int {
+	task := "upgrade"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		task, args = args[0], args[1:]
+	}
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	driver := fs.String("driver", "{{DBDriver}}", "Database driver, used with -conn.")
+	conn := fs.String("conn", "", "Connection string of the database. Defaults to the \"default\" database of the orm.")
+	to := fs.String("to", "", "Timestamp (20060102_150405) of the migration up/down should stop at.")
+	steps := fs.Int("steps", 0, "Number of migrations up/down/redo should apply or roll back.")
+	fs.BoolVar(&dryRun, "dry-run", false, "Print the SQL each migration would execute instead of running it.")
+	lockTimeout := fs.Duration("lock-timeout", {{LockTimeout}}, "How long to wait for a concurrent migration run to finish. 0 waits indefinitely.")
+	lockNoWait := fs.Bool("lock-nowait", false, "Fail immediately when another migration run holds the lock.")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	var target int64
+	if *to != "" {
+		t, err := time.Parse(migration.DateFormat, *to)
+		if err != nil {
+			logs.Error("could not parse target:", err)
+			return 2
+		}
+		target = t.Unix()
+	}
+	if task == "redo" && *steps == 0 || task == "down" && target == 0 && *steps == 0 {
+		*steps = 1
+	}
+
+	if *conn != "" {
+		if err := orm.RegisterDataBase("default", *driver, *conn); err != nil {
+			logs.Error("could not connect to database:", err)
+			return 2
+		}
+	}
+	db, err := orm.GetDB("default")
+	if err != nil {
+		logs.Error(err, "- register it before calling Main, or use -driver and -conn")
+		return 2
+	}
+	if !dryRun {
+		timeout := *lockTimeout
+		if *lockNoWait {
+			timeout = 0
+		} else if timeout == 0 {
+			timeout = -1
+		}
+		unlock, err := lock(db, timeout)
+		if err != nil {
+			logs.Error("could not lock migrations:", err)
+			return 2
+		}
+		defer unlock()
+	}
+	noHistory := !hasMigrationsTable(db)
+	if noHistory && !dryRun {
+		ddl, ok := migrationsDDL[orm.NewOrm().Driver().Type()]
+		if !ok {
+			logs.Error("migrations are not supported for this database driver")
+			return 2
+		}
+		if _, err := db.Exec(ddl); err != nil {
+			logs.Error("could not create migrations table:", err)
+			return 2
+		}
+		noHistory = false
+	}
+	if err := loadStatuses(noHistory); err != nil {
+		return 2
+	}
+	if !noHistory && !dryRun {
+		if err := recordBaselines(); err != nil {
+			return 2
+		}
+	}
+	if task == "status" {
+		status()
+		return 0
+	}
+
+	latestName, latestTime, err := latest(db, noHistory)
+	if err != nil {
+		logs.Error("could not read migrations:", err)
+		return 2
+	}
+	if latestName == "" && (task == "rollback" || task == "down" || task == "redo") {
+		logs.Error("there is nothing to rollback")
+		return 2
+	}
+	if err := runTask(task, latestTime, latestName, target, *steps); err != nil {
+		return 2
+	}
+	return 0
+}
+
+// lock takes the migrations lock, waiting at most timeout for it, a negative timeout meaning
+// indefinitely. It returns the function releasing the lock.
+func lock(db *sql.DB, timeout time.Duration) (func(), error) {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var try func() (bool, error)
+	var unlock func() error
+	switch orm.NewOrm().Driver().Type() {
+	case orm.DRMySQL:
+		// GET_LOCK waits for the lock itself
+		seconds := int64(timeout / time.Second)
+		if timeout < 0 {
+			seconds = -1
+		}
+		try = func() (bool, error) {
+			var acquired sql.NullInt64
+			err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", lockName, seconds).Scan(&acquired)
+			return acquired.Int64 == 1, err
+		}
+		unlock = func() error {
+			_, err := conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", lockName)
+			return err
+		}
+	case orm.DRPostgres:
+		try = func() (bool, error) {
+			var acquired bool
+			err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock(hashtext($1))", lockName).Scan(&acquired)
+			return acquired, err
+		}
+		unlock = func() error {
+			_, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock(hashtext($1))", lockName)
+			return err
+		}
+	case orm.DRSqlite:
+		if _, err := conn.ExecContext(ctx, {{SQLiteLockDDL}}); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		host, _ := os.Hostname()
+		holder := fmt.Sprintf("%s:%d", host, os.Getpid())
+		try = func() (bool, error) {
+			if _, err := conn.ExecContext(ctx, "INSERT INTO migrations_lock(id, holder) VALUES(1, ?)", holder); err == nil {
+				return true, nil
+			}
+			var current string
+			if err := conn.QueryRowContext(ctx, "SELECT holder FROM migrations_lock WHERE id = 1").Scan(&current); err != nil {
+				return false, nil
+			}
+			if i := strings.LastIndex(current, ":"); i > 0 && current[:i] == host {
+				if pid, err := strconv.Atoi(current[i+1:]); err == nil && !processExists(pid) {
+					logs.Warn("removing migrations lock left behind by process", pid)
+					conn.ExecContext(ctx, "DELETE FROM migrations_lock WHERE id = 1 AND holder = ?", current)
+				}
+			}
+			return false, nil
+		}
+		unlock = func() error {
+			_, err := conn.ExecContext(ctx, "DELETE FROM migrations_lock WHERE id = 1")
+			return err
+		}
+	default:
+		conn.Close()
+		return nil, fmt.Errorf("migrations are not supported for this database driver")
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		acquired, err := try()
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		if acquired {
+			break
+		}
+		if timeout >= 0 && !time.Now().Before(deadline) {
+			conn.Close()
+			return nil, fmt.Errorf("another migration is running against this database, it did not finish within %s", timeout)
+		}
+		time.Sleep(lockPollInterval)
+	}
+	return func() {
+		if err := unlock(); err != nil {
+			logs.Warn("could not unlock migrations:", err)
+		}
+		conn.Close()
+	}, nil
+}
+
+// processExists reports whether a process with the given pid runs on this host
+func processExists(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		return true
+	}
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || err == syscall.EPERM
+}
+
+// hasMigrationsTable reports whether the migrations table exists
+func hasMigrationsTable(db *sql.DB) bool {
+	rows, err := db.Query("SELECT 1 FROM migrations WHERE 1 = 0")
+	if err != nil {
+		return false
+	}
+	rows.Close()
+	return true
+}
+
+// latest returns the name and creation time of the latest applied migration
+func latest(db *sql.DB, noHistory bool) (name string, created int64, err error) {
+	if noHistory {
+		return "", 0, nil
+	}
+	err = db.QueryRow("SELECT name FROM migrations WHERE status = 'update' ORDER BY id_migration DESC LIMIT 1").Scan(&name)
+	if err == sql.ErrNoRows || name == "" {
+		return "", 0, nil
+	} else if err != nil {
+		return "", 0, err
+	}
+	if len(name) >= len(migration.DateFormat) {
+		if t, err := time.Parse(migration.DateFormat, name[len(name)-len(migration.DateFormat):]); err == nil {
+			created = t.Unix()
+		}
+	}
+	return name, created, nil
+}
+
+// recordBaselines records the baselines as applied when every migration they replace is.
+// The record of the last of them is renamed, so that the order of the table is kept.
+func recordBaselines() error {
+	for name, squashed := range baselines {
+		applied := 0
+		for _, s := range squashed {
+			if st[s] == "update" {
+				applied++
+			}
+		}
+		if st[name] != "" || applied == 0 {
+			continue
+		}
+		if applied < len(squashed) {
+			err := fmt.Errorf("the database applied %d of the %d migrations squashed into %s", applied, len(squashed), name)
+			logs.Error(err)
+			return err
+		}
+		last := squashed[len(squashed)-1]
+		if _, err := orm.NewOrm().Raw("UPDATE migrations SET name = ? WHERE name = ?", name, last).Exec(); err != nil {
+			logs.Error("could not record baseline:", err)
+			return err
+		}
+		st[name] = st[last]
+		delete(st, last)
+		logs.Info("recorded baseline", name, "as applied")
+	}
+	return nil
+}
+
+// status prints the state of each embedded migration
+func status() {
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "MIGRATION\tSTATUS")
+	for _, e := range entries() {
+		s := "pending"
+		switch st[e.name] {
+		case "update":
+			s = "applied"
+		case "rollback":
+			s = "rolled back"
+		}
+		fmt.Fprintf(w, "%s\t%s\n", e.name, s)
+	}
+	w.Flush()
+}
+`
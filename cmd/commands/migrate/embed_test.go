@@ -0,0 +1,86 @@
+// Copyright 2013 bee authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package migrate
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEmbeddedSourceCode(t *testing.T) {
+	sources := []migrationSource{
+		{Name: "Baseline_20200102_120000", Type: "Baseline_20200102_120000", Created: "20200102_120000",
+			Squashed: []string{"CreateUsers_20200101_120000", "CreatePosts_20200102_120000"}},
+		{Name: "CreateTags_20200103_120000", Type: "CreateTags_20200103_120000"},
+	}
+	tests := []struct {
+		driver string
+		want   []string
+	}{
+		{"mysql", []string{`_ "github.com/go-sql-driver/mysql"`, `"SELECT GET_LOCK(?, ?)"`}},
+		{"postgres", []string{`_ "github.com/lib/pq"`, `"SELECT pg_try_advisory_lock(hashtext($1))"`}},
+		{"sqlite", []string{`_ "github.com/mattn/go-sqlite3"`, `CREATE TABLE IF NOT EXISTS migrations_lock`}},
+	}
+	for _, tt := range tests {
+		src := embeddedSourceCode("migrator", tt.driver, sources)
+		checkProgram(t, src)
+		want := append(tt.want,
+			"package migrator",
+			`const lockName = "`+lockName+`"`,
+			`"Baseline_20200102_120000": {"CreateUsers_20200101_120000", "CreatePosts_20200102_120000"},`,
+			`m := &CreateTags_20200103_120000{}`,
+			`m.Created = "20200102_120000"`,
+		)
+		for _, w := range want {
+			if !strings.Contains(src, w) {
+				t.Errorf("%s: source does not contain %s", tt.driver, w)
+			}
+		}
+	}
+}
+
+func TestEmbeddedMigration(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	file := writeFile(t, dir, "users.go", "package main\n\n// CreateUsers creates users\ntype CreateUsers struct{}\n")
+	got := string(embeddedMigration(file, "migrator"))
+	if !strings.HasPrefix(got, "package migrator\n") || !strings.Contains(got, "// CreateUsers creates users") {
+		t.Errorf("unexpected embedded migration:\n%s", got)
+	}
+}
+
+func TestPackageImportPath(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	withMod := filepath.Join(dir, "withmod")
+	if err := os.MkdirAll(withMod, 0777); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, withMod, "go.mod", "module example.com/app\n\ngo 1.13\n")
+
+	tests := []struct {
+		currpath, dir, want string
+	}{
+		{withMod, filepath.Join(withMod, "database", "migrator"), "example.com/app/database/migrator"},
+		{dir, filepath.Join(dir, "database", "migrator"), "database/migrator"},
+	}
+	for _, tt := range tests {
+		if got := packageImportPath(tt.currpath, tt.dir); got != tt.want {
+			t.Errorf("packageImportPath(%q, %q) = %q, want %q", tt.currpath, tt.dir, got, tt.want)
+		}
+	}
+}
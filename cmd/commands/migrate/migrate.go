@@ -14,10 +14,12 @@
 package migrate
 
 import (
+	"context"
 	"database/sql"
 	"os"
 	"os/exec"
 	"path"
+	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
@@ -40,6 +42,77 @@ var CmdMigrate = &commands.Command{
 
     $ bee migrate [-driver=mysql] [-conn="root:@tcp(127.0.0.1:3306)/test"] [-dir="path/to/migration"]
 
+  ▶ {{"To print the SQL a migration command would execute, without touching the database:"|bold}}
+
+    $ bee migrate [Command] -dry-run
+
+  ▶ {{"To show which migrations are applied, and whether their files changed since:"|bold}}
+
+    $ bee migrate status [-repair] [-driver=mysql] [-conn="root:@tcp(127.0.0.1:3306)/test"] [-dir="path/to/migration"]
+
+    bee stores a checksum of each migration file when it is applied, and refuses to run
+    when an applied file was edited. Use -repair to accept the edits as the new baseline, it takes
+    the same lock as 'bee migrate'.
+
+  ▶ {{"To write the database schema and the applied migrations to database/schema.sql:"|bold}}
+
+    $ bee migrate dump [-schema=database/schema.sql] [-driver=mysql] [-conn="root:@tcp(127.0.0.1:3306)/test"]
+
+    The tables, with their keys, indexes and defaults, are read as the database declares them: SHOW CREATE TABLE
+    on MySQL, sqlite_master on SQLite and pg_catalog on PostgreSQL. Views, triggers and custom types are not dumped.
+
+  ▶ {{"To create a fresh database from the schema file instead of running every migration:"|bold}}
+
+    $ bee migrate load [-schema=database/schema.sql] [-driver=mysql] [-conn="root:@tcp(127.0.0.1:3306)/test"] [-dir="path/to/migration"]
+
+  ▶ {{"To replace the migrations created up to a timestamp with a baseline creating the schema they result in:"|bold}}
+
+    $ bee migrate squash -before=20060102_150405 [-scratch="root:@tcp(127.0.0.1:3306)/scratch"] [-baseline-scratch="root:@tcp(127.0.0.1:3306)/baseline_scratch"] [-driver=mysql] [-conn="root:@tcp(127.0.0.1:3306)/test"]
+
+    The migrations are applied to the empty -scratch database, a temporary one for SQLite, to read the schema.
+    The baseline is then loaded into the empty -baseline-scratch database, and squash stops without changing any
+    file unless both databases have the same tables, columns, defaults, keys, indexes, views and triggers.
+    The squashed migrations are moved to the squashed directory of the migrations, excluded from the build.
+    Databases which applied them, the one of the environment included, record the baseline as applied instead.
+
+  ▶ {{"To generate a package running the migrations from within the application binary:"|bold}}
+
+    $ bee migrate embed [-pkg=database/migrator] [-driver=mysql] [-dir="path/to/migration"]
+
+    Call migrator.Main() first thing in main(), then use './app migrate [Command]' where Go is not installed.
+    Run it again after adding migrations, before 'bee pack'. Runs take the same lock as 'bee migrate'.
+
+  ▶ {{"To apply the seeds of database/seeds which were not applied yet:"|bold}}
+
+    $ bee migrate seed [-env=prod] [-driver=mysql] [-conn="root:@tcp(127.0.0.1:3306)/test"]
+
+    Seeds limited to other runmodes with a 'bee:runmodes' comment are skipped.
+
+  ▶ {{"To use the database settings of an environment, set under database.environments in the Beefile:"|bold}}
+
+    $ bee migrate [Command] -env=prod
+
+    The environment defaults to BEEGO_RUNMODE, then dev. With database.app_conf set, the
+    connection string may also come from the sqlconn or mysql* keys of conf/app.conf.
+
+  ▶ {{"To wait at most 30 seconds for another migration run against the same database to finish:"|bold}}
+
+    $ bee migrate [Command] -lock-timeout=30s
+
+    Use -lock-timeout=0 to wait indefinitely, or -lock-nowait to fail immediately.
+
+  ▶ {{"To run outstanding migrations up to a given one, or only the next N of them:"|bold}}
+
+    $ bee migrate up [-to=20060102_150405] [-steps=N] [-driver=mysql] [-conn="root:@tcp(127.0.0.1:3306)/test"] [-dir="path/to/migration"]
+
+  ▶ {{"To roll back the last N applied migrations, or every migration newer than a given one:"|bold}}
+
+    $ bee migrate down [-steps=N] [-to=20060102_150405] [-driver=mysql] [-conn="root:@tcp(127.0.0.1:3306)/test"] [-dir="path/to/migration"]
+
+  ▶ {{"To roll back the last N applied migrations and apply them again:"|bold}}
+
+    $ bee migrate redo [-steps=N] [-driver=mysql] [-conn="root:@tcp(127.0.0.1:3306)/test"] [-dir="path/to/migration"]
+
   ▶ {{"To rollback the last migration:"|bold}}
 
     $ bee migrate rollback [-driver=mysql] [-conn="root:@tcp(127.0.0.1:3306)/test"] [-dir="path/to/migration"]
@@ -59,11 +132,44 @@ var CmdMigrate = &commands.Command{
 var mDriver utils.DocValue
 var mConn utils.DocValue
 var mDir utils.DocValue
+var mTo utils.DocValue
+var mSteps utils.DocValue
+var mDryRun bool
+var mLockTimeout utils.DocValue
+var mLockNoWait bool
+var mRepair bool
+var mSchema utils.DocValue
+var mPkg utils.DocValue
+var mEnv utils.DocValue
+var mBefore utils.DocValue
+var mScratch utils.DocValue
+var mBaselineScratch utils.DocValue
+
+// defaultLockTimeout is how long a run waits for the migrations lock by default
+const defaultLockTimeout = time.Minute
+
+// connEnv is the environment variable passing the connection string to generated programs
+const connEnv = "BEE_MIGRATE_CONN"
+
+// releaseLock releases the migrations lock held by the current run
+var releaseLock = func() {}
 
 func init() {
 	CmdMigrate.Flag.Var(&mDriver, "driver", "Database driver. Either mysql, postgres or sqlite.")
 	CmdMigrate.Flag.Var(&mConn, "conn", "Connection string used by the driver to connect to a database instance.")
+	CmdMigrate.Flag.Var(&mEnv, "env", "Environment whose database settings are used. Defaults to BEEGO_RUNMODE, then dev.")
 	CmdMigrate.Flag.Var(&mDir, "dir", "The directory where the migration files are stored")
+	CmdMigrate.Flag.Var(&mTo, "to", "Timestamp (20060102_150405) of the migration up/down should stop at.")
+	CmdMigrate.Flag.Var(&mSteps, "steps", "Number of migrations up/down/redo should apply or roll back.")
+	CmdMigrate.Flag.BoolVar(&mDryRun, "dry-run", false, "Print the SQL each migration would execute instead of running it.")
+	CmdMigrate.Flag.Var(&mLockTimeout, "lock-timeout", "How long to wait for a concurrent migration run to finish, e.g. 30s. 0 waits indefinitely.")
+	CmdMigrate.Flag.Var(&mSchema, "schema", "The schema file written by dump and read by load. Defaults to database/schema.sql.")
+	CmdMigrate.Flag.Var(&mBefore, "before", "Timestamp (20060102_150405) of the last migration squash replaces with a baseline.")
+	CmdMigrate.Flag.Var(&mScratch, "scratch", "Connection string of an empty database squash applies the migrations to. Defaults to a temporary database for SQLite.")
+	CmdMigrate.Flag.Var(&mBaselineScratch, "baseline-scratch", "Connection string of a second empty database squash loads the baseline into to check it. Defaults to a temporary database for SQLite.")
+	CmdMigrate.Flag.Var(&mPkg, "pkg", "The directory of the package generated by embed. Defaults to database/migrator.")
+	CmdMigrate.Flag.BoolVar(&mRepair, "repair", false, "Record the current checksum of applied migrations that were modified since they were applied.")
+	CmdMigrate.Flag.BoolVar(&mLockNoWait, "lock-nowait", false, "Fail immediately when another migration run holds the lock.")
 	commands.AvailableCommands = append(commands.AvailableCommands, CmdMigrate)
 }
 
@@ -84,25 +190,13 @@ func RunMigration(cmd *commands.Command, args []string) int {
 	if len(args) != 0 {
 		cmd.Flag.Parse(args[1:])
 	}
-	if mDriver == "" {
-		mDriver = utils.DocValue(config.Conf.Database.Driver)
-		if mDriver == "" {
-			mDriver = "mysql"
-		}
-	}
-	if mConn == "" {
-		mConn = utils.DocValue(config.Conf.Database.Conn)
-		if mConn == "" {
-			mConn = "root:@tcp(127.0.0.1:3306)/test"
-		}
-	}
+	db := config.ResolveDatabase(currpath, mEnv.String(), mDriver.String(), mConn.String(), mDir.String())
+	mEnv, mDriver, mConn, mDir = utils.DocValue(db.Env), utils.DocValue(db.Driver), utils.DocValue(db.Conn), utils.DocValue(db.Dir)
 	if mDir == "" {
-		mDir = utils.DocValue(config.Conf.Database.Dir)
-		if mDir == "" {
-			mDir = utils.DocValue(path.Join(currpath, "database", "migrations"))
-		}
+		mDir = utils.DocValue(path.Join(currpath, "database", "migrations"))
 	}
 
+	beeLogger.Log.Infof("Using '%s' as 'env'", mEnv)
 	beeLogger.Log.Infof("Using '%s' as 'driver'", mDriver)
 	//Log sensitive connection information only when DEBUG is set to true.
 	beeLogger.Log.Debugf("Conn: %s", utils.FILE(), utils.LINE(), mConn)
@@ -122,6 +216,24 @@ func RunMigration(cmd *commands.Command, args []string) int {
 	} else {
 		mcmd := args[0]
 		switch mcmd {
+		case "up":
+			target, steps := parseTargetFlags()
+			beeLogger.Log.Info("Running outstanding migrations")
+			MigrateUp(currpath, driverStr, connStr, dirStr, target, steps)
+		case "down":
+			target, steps := parseTargetFlags()
+			if target == 0 && steps == 0 {
+				steps = 1
+			}
+			beeLogger.Log.Info("Rolling back migrations")
+			MigrateDown(currpath, driverStr, connStr, dirStr, target, steps)
+		case "redo":
+			_, steps := parseTargetFlags()
+			if steps == 0 {
+				steps = 1
+			}
+			beeLogger.Log.Infof("Redoing the last %d migration(s)", steps)
+			MigrateRedo(currpath, driverStr, connStr, dirStr, steps)
 		case "rollback":
 			beeLogger.Log.Info("Rolling back the last migration operation")
 			MigrateRollback(currpath, driverStr, connStr, dirStr)
@@ -131,194 +243,279 @@ func RunMigration(cmd *commands.Command, args []string) int {
 		case "refresh":
 			beeLogger.Log.Info("Refreshing all migrations")
 			MigrateRefresh(currpath, driverStr, connStr, dirStr)
+		case "dump":
+			MigrateDump(currpath, driverStr, connStr, schemaFile(currpath))
+			return 0
+		case "load":
+			beeLogger.Log.Info("Loading the database schema")
+			MigrateLoad(currpath, driverStr, connStr, dirStr, schemaFile(currpath))
+		case "embed":
+			MigrateEmbed(currpath, driverStr, dirStr, embedDir(currpath))
+			return 0
+		case "squash":
+			if mBefore == "" {
+				beeLogger.Log.Hint("Give the timestamp of the last migration to squash, i.e. -before=20060102_150405")
+				beeLogger.Log.Fatal("Wrong number of arguments. Run: bee help migrate")
+			}
+			beeLogger.Log.Infof("Squashing the migrations created at or before %s", mBefore)
+			MigrateSquash(currpath, driverStr, connStr, dirStr, mScratch.String(), mBaselineScratch.String(), mBefore.String())
+		case "seed":
+			beeLogger.Log.Info("Applying seeds")
+			MigrateSeed(currpath, driverStr, connStr, path.Join(currpath, "database", "seeds"))
+		case "status":
+			if MigrateStatus(currpath, driverStr, connStr, dirStr) > 0 {
+				return 1
+			}
+			return 0
 		default:
 			beeLogger.Log.Fatal("Command is missing")
 		}
 	}
-	beeLogger.Log.Success("Migration successful!")
+	if mDryRun {
+		beeLogger.Log.Success("Dry run finished. The database was not modified.")
+	} else {
+		beeLogger.Log.Success("Migration successful!")
+	}
 	return 0
 }
 
+// schemaFile returns the path of the schema file used by dump and load
+func schemaFile(currpath string) string {
+	if mSchema == "" {
+		return path.Join(currpath, "database", "schema.sql")
+	}
+	if filepath.IsAbs(mSchema.String()) {
+		return mSchema.String()
+	}
+	return path.Join(currpath, mSchema.String())
+}
+
+// embedDir returns the directory of the package generated by embed
+func embedDir(currpath string) string {
+	if mPkg == "" {
+		return path.Join(currpath, "database", "migrator")
+	}
+	if filepath.IsAbs(mPkg.String()) {
+		return mPkg.String()
+	}
+	return path.Join(currpath, mPkg.String())
+}
+
+// parseTargetFlags reads the -to and -steps flags.
+// A zero value means the corresponding bound is not set.
+func parseTargetFlags() (target int64, steps int) {
+	if mTo != "" {
+		t, err := time.Parse(migrationDateFormat, mTo.String())
+		if err != nil {
+			beeLogger.Log.Hint("Expecting the timestamp of a migration, i.e. -to=20060102_150405")
+			beeLogger.Log.Fatalf("Could not parse target '%s': %s", mTo, err)
+		}
+		target = t.Unix()
+	}
+	if mSteps != "" {
+		n, err := strconv.Atoi(mSteps.String())
+		if err != nil || n < 1 {
+			beeLogger.Log.Fatalf("Invalid steps '%s'. Must be a positive integer", mSteps)
+		}
+		steps = n
+	}
+	return
+}
+
+// runOptions holds the values a migration run passes to the migration binary
+type runOptions struct {
+	Target    int64 // unix time of the migration to stop at, 0 for no bound
+	Steps     int   // number of migrations to apply or roll back, 0 for no bound
+	DryRun    bool  // print the statements instead of executing them
+	NoHistory bool  // the migrations table does not exist yet
+}
+
 // migrate generates source code, build it, and invoke the binary who does the actual migration
-func migrate(goal, currpath, driver, connStr, dir string) {
+func migrate(goal, currpath, driver, connStr, dir string, target int64, steps int) {
 	if dir == "" {
 		dir = path.Join(currpath, "database", "migrations")
 	}
-	postfix := ""
-	if runtime.GOOS == "windows" {
-		postfix = ".exe"
-	}
-	binary := "m" + postfix
-	source := binary + ".go"
+	binary, source := migrationProgram()
 
+	dialect := getDialect(driver)
 	// Connect to database
-	db, err := sql.Open(driver, connStr)
+	db, err := sql.Open(sqlDriverName(driver), connStr)
 	if err != nil {
 		beeLogger.Log.Fatalf("Could not connect to database using '%s': %s", connStr, err)
 	}
 	defer db.Close()
-	checkForSchemaUpdateTable(db, driver)
-	latestName, latestTime := getLatestMigration(db, goal)
-	writeMigrationSourceFile(dir, source, driver, connStr, latestTime, latestName, goal)
-	buildMigrationBinary(dir, binary)
-	runMigrationBinary(dir, binary)
-	removeTempFile(dir, source)
-	removeTempFile(dir, binary)
-	removeTempFile(dir, "go.mod")
-	removeTempFile(dir, "go.sum")
+	if !mDryRun {
+		lockMigrations(db, dialect)
+		defer releaseLock()
+	}
+	opts := runOptions{Target: target, Steps: steps, DryRun: mDryRun}
+	if !mDryRun {
+		checkForSchemaUpdateTable(db, dialect)
+	} else if !dialect.TableExists(db, "migrations") {
+		// A dry run only reads the migrations table, it does not create nor alter any table
+		beeLogger.Log.Info("Table 'migrations' does not exist yet and would be created")
+		opts.NoHistory = true
+	}
+	sources := readMigrationSources(dir, source)
+	if !opts.NoHistory {
+		if !mDryRun {
+			recordBaselines(db, dialect, sources)
+		}
+		checkChecksums(db, dialect, sources)
+	}
+	var latestName string
+	var latestTime int64
+	if opts.NoHistory {
+		if goal == "rollback" || goal == "down" || goal == "redo" {
+			beeLogger.Log.Fatal("There is nothing to rollback")
+		}
+	} else {
+		latestName, latestTime = getLatestMigration(db, dialect, goal)
+	}
+	runner := compileRunner(dir, source, binary, migrationSourceCode(driver, sources))
+	runMigrationBinary(dir, runner, connStr, runnerArgs(goal, latestTime, latestName, opts)...)
+	if !mDryRun {
+		recordChecksums(db, dialect, sources)
+	}
 }
 
-// checkForSchemaUpdateTable checks the existence of migrations table.
-// It checks for the proper table structures and creates the table using MYSQL_MIGRATION_DDL if it does not exist.
-func checkForSchemaUpdateTable(db *sql.DB, driver string) {
-	showTableSQL := showMigrationsTableSQL(driver)
-	if rows, err := db.Query(showTableSQL); err != nil {
-		beeLogger.Log.Fatalf("Could not show migrations table: %s", err)
-	} else if !rows.Next() {
-		// No migrations table, create new ones
-		createTableSQL := createMigrationsTableSQL(driver)
-
-		beeLogger.Log.Infof("Creating 'migrations' table...")
+// migrationProgram returns the file names of the generated migration program and its source
+func migrationProgram() (binary, source string) {
+	return programFiles("m")
+}
 
-		if _, err := db.Query(createTableSQL); err != nil {
-			beeLogger.Log.Fatalf("Could not create migrations table: %s", err)
-		}
+// programFiles returns the file names of a generated program and its source
+func programFiles(name string) (binary, source string) {
+	postfix := ""
+	if runtime.GOOS == "windows" {
+		postfix = ".exe"
 	}
+	binary = name + postfix
+	return binary, binary + ".go"
+}
 
-	// Checking that migrations table schema are expected
-	selectTableSQL := selectMigrationsTableSQL(driver)
-	if rows, err := db.Query(selectTableSQL); err != nil {
-		beeLogger.Log.Fatalf("Could not show columns of migrations table: %s", err)
-	} else {
-		for rows.Next() {
-			var fieldBytes, typeBytes, nullBytes, keyBytes, defaultBytes, extraBytes []byte
-			if err := rows.Scan(&fieldBytes, &typeBytes, &nullBytes, &keyBytes, &defaultBytes, &extraBytes); err != nil {
-				beeLogger.Log.Fatalf("Could not read column information: %s", err)
-			}
-			fieldStr, typeStr, nullStr, keyStr, defaultStr, extraStr :=
-				string(fieldBytes), string(typeBytes), string(nullBytes), string(keyBytes), string(defaultBytes), string(extraBytes)
-			if fieldStr == "id_migration" {
-				if keyStr != "PRI" || extraStr != "auto_increment" {
-					beeLogger.Log.Hint("Expecting KEY: PRI, EXTRA: auto_increment")
-					beeLogger.Log.Fatalf("Column migration.id_migration type mismatch: KEY: %s, EXTRA: %s", keyStr, extraStr)
-				}
-			} else if fieldStr == "name" {
-				if !strings.HasPrefix(typeStr, "varchar") || nullStr != "YES" {
-					beeLogger.Log.Hint("Expecting TYPE: varchar, NULL: YES")
-					beeLogger.Log.Fatalf("Column migration.name type mismatch: TYPE: %s, NULL: %s", typeStr, nullStr)
-				}
-			} else if fieldStr == "created_at" {
-				if typeStr != "timestamp" || defaultStr != "CURRENT_TIMESTAMP" {
-					beeLogger.Log.Hint("Expecting TYPE: timestamp, DEFAULT: CURRENT_TIMESTAMP")
-					beeLogger.Log.Fatalf("Column migration.timestamp type mismatch: TYPE: %s, DEFAULT: %s", typeStr, defaultStr)
-				}
-			}
-		}
+// lockMigrations takes the migrations lock, so that concurrent runs do not read
+// the same latest migration and apply it twice. The lock is held until releaseLock is called.
+func lockMigrations(db *sql.DB, dialect MigrationDialect) {
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		beeLogger.Log.Fatalf("Could not connect to database: %s", err)
+	}
+	beeLogger.Log.Info("Acquiring migrations lock")
+	dialect.Lock(conn, lockWait())
+	releaseLock = func() {
+		dialect.Unlock(conn)
+		conn.Close()
+		releaseLock = func() {}
 	}
 }
 
-func driverImportStatement(driver string) string {
-	switch driver {
-	case "mysql":
-		return "github.com/go-sql-driver/mysql"
-	case "postgres":
-		return "github.com/lib/pq"
-	default:
-		return "github.com/go-sql-driver/mysql"
+// lockWait returns how long to wait for the migrations lock, a negative value meaning indefinitely
+func lockWait() time.Duration {
+	if mLockNoWait || config.Conf.Database.LockNoWait {
+		return 0
 	}
+	timeout := mLockTimeout.String()
+	if timeout == "" {
+		timeout = config.Conf.Database.LockTimeout
+	}
+	if timeout == "" {
+		return defaultLockTimeout
+	}
+	d, err := time.ParseDuration(timeout)
+	if err != nil {
+		beeLogger.Log.Hint("Expecting a duration, i.e. -lock-timeout=30s")
+		beeLogger.Log.Fatalf("Could not parse lock timeout '%s': %s", timeout, err)
+	}
+	if d == 0 {
+		return -1
+	}
+	return d
 }
 
-func showMigrationsTableSQL(driver string) string {
-	switch driver {
-	case "mysql":
-		return "SHOW TABLES LIKE 'migrations'"
-	case "postgres":
-		return "SELECT * FROM pg_catalog.pg_tables WHERE tablename = 'migrations';"
-	default:
-		return "SHOW TABLES LIKE 'migrations'"
+// checkForSchemaUpdateTable checks the existence of migrations table.
+// It checks for the proper table structures and creates the table using the driver's DDL if it does not exist.
+func checkForSchemaUpdateTable(db *sql.DB, dialect MigrationDialect) {
+	if !dialect.TableExists(db, "migrations") {
+		// No migrations table, create new ones
+		dialect.CreateTable(db)
 	}
+	// Checking that migrations table schema are expected
+	dialect.ValidateTable(db)
+	dialect.CreateChecksumTable(db)
 }
 
-func createMigrationsTableSQL(driver string) string {
-	switch driver {
-	case "mysql":
-		return MYSQLMigrationDDL
-	case "postgres":
-		return POSTGRESMigrationDDL
-	default:
-		return MYSQLMigrationDDL
+// sqlDriverName returns the name database/sql and the beego orm know a driver by
+func sqlDriverName(driver string) string {
+	if driver == "sqlite" {
+		return "sqlite3"
 	}
+	return driver
 }
 
-func selectMigrationsTableSQL(driver string) string {
+func driverImportStatement(driver string) string {
 	switch driver {
 	case "mysql":
-		return "DESC migrations"
+		return "github.com/go-sql-driver/mysql"
 	case "postgres":
-		return "SELECT * FROM migrations WHERE false ORDER BY id_migration;"
+		return "github.com/lib/pq"
+	case "sqlite", "sqlite3":
+		return "github.com/mattn/go-sqlite3"
 	default:
-		return "DESC migrations"
+		return "github.com/go-sql-driver/mysql"
 	}
 }
 
 // getLatestMigration retrives latest migration with status 'update'
-func getLatestMigration(db *sql.DB, goal string) (file string, createdAt int64) {
-	sql := "SELECT name FROM migrations where status = 'update' ORDER BY id_migration DESC LIMIT 1"
-	if rows, err := db.Query(sql); err != nil {
-		beeLogger.Log.Fatalf("Could not retrieve migrations: %s", err)
-	} else {
-		if rows.Next() {
-			if err := rows.Scan(&file); err != nil {
-				beeLogger.Log.Fatalf("Could not read migrations in database: %s", err)
-			}
-			createdAtStr := file[len(file)-15:]
-			if t, err := time.Parse("20060102_150405", createdAtStr); err != nil {
-				beeLogger.Log.Fatalf("Could not parse time: %s", err)
-			} else {
-				createdAt = t.Unix()
-			}
-		} else {
-			// migration table has no 'update' record, no point rolling back
-			if goal == "rollback" {
-				beeLogger.Log.Fatal("There is nothing to rollback")
-			}
-			file, createdAt = "", 0
+func getLatestMigration(db *sql.DB, dialect MigrationDialect, goal string) (file string, createdAt int64) {
+	file = dialect.LatestMigration(db)
+	if file == "" {
+		// migration table has no 'update' record, no point rolling back
+		if goal == "rollback" || goal == "down" || goal == "redo" {
+			releaseLock()
+			beeLogger.Log.Fatal("There is nothing to rollback")
 		}
+		return "", 0
 	}
-	return
+	createdAtStr := file[len(file)-15:]
+	t, err := time.Parse(migrationDateFormat, createdAtStr)
+	if err != nil {
+		beeLogger.Log.Fatalf("Could not parse time: %s", err)
+	}
+	return file, t.Unix()
 }
 
-// writeMigrationSourceFile create the source file based on MIGRATION_MAIN_TPL
-func writeMigrationSourceFile(dir, source, driver, connStr string, latestTime int64, latestName string, task string) {
-	changeDir(dir)
-	if f, err := os.OpenFile(source, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0666); err != nil {
-		beeLogger.Log.Fatalf("Could not create file: %s", err)
-		return
-	} else {
-		content := strings.Replace(MigrationMainTPL, "{{DBDriver}}", driver, -1)
-		content = strings.Replace(content, "{{DriverRepo}}", driverImportStatement(driver), -1)
-		content = strings.Replace(content, "{{ConnStr}}", connStr, -1)
-		content = strings.Replace(content, "{{LatestTime}}", strconv.FormatInt(latestTime, 10), -1)
-		content = strings.Replace(content, "{{LatestName}}", latestName, -1)
-		content = strings.Replace(content, "{{Task}}", task, -1)
-		if _, err := f.WriteString(content); err != nil {
-			beeLogger.Log.Fatalf("Could not write to file: %s", err)
-		}
-		utils.CloseFile(f)
+// migrationSourceCode renders the source of the migration program based on MigrationMainTPL
+func migrationSourceCode(driver string, sources []migrationSource) string {
+	content := strings.Replace(MigrationMainTPL+MigrationRunnerTPL, "{{DBDriver}}", sqlDriverName(driver), -1)
+	content = strings.Replace(content, "{{DriverRepo}}", driverImportStatement(driver), -1)
+	content = strings.Replace(content, "{{ConnEnv}}", connEnv, -1)
+	content = strings.Replace(content, "{{Migrations}}", migrationSourcesCode(sources), -1)
+	return content
+}
+
+// runnerArgs returns the arguments telling the migration program what to do
+func runnerArgs(task string, latestTime int64, latestName string, opts runOptions) []string {
+	return []string{
+		"-task=" + task,
+		"-latest-time=" + strconv.FormatInt(latestTime, 10),
+		"-latest-name=" + latestName,
+		"-target=" + strconv.FormatInt(opts.Target, 10),
+		"-steps=" + strconv.Itoa(opts.Steps),
+		"-dry-run=" + strconv.FormatBool(opts.DryRun),
+		"-no-history=" + strconv.FormatBool(opts.NoHistory),
 	}
 }
 
-// buildMigrationBinary changes directory to database/migrations folder and go-build the source
-func buildMigrationBinary(dir, binary string) {
+// buildMigrationBinary changes directory to the folder of source and go-builds it into binary
+func buildMigrationBinary(dir, source, binary string) {
 	changeDir(dir)
 	cmd := exec.Command("go", "mod", "init")
 	if out, err := cmd.CombinedOutput(); err != nil {
 		beeLogger.Log.Errorf("Could not go mod init: %s", err)
 		formatShellErrOutput(string(out))
-		removeTempFile(dir, binary)
-		removeTempFile(dir, binary+".go")
-		removeTempFile(dir, "go.mod")
-		removeTempFile(dir, "go.sum")
+		removeProgramSource(dir, source)
+		releaseLock()
 		os.Exit(2)
 	}
 
@@ -326,31 +523,38 @@ func buildMigrationBinary(dir, binary string) {
 	if out, err := cmd.CombinedOutput(); err != nil {
 		beeLogger.Log.Errorf("Could not build migration binary: %s", err)
 		formatShellErrOutput(string(out))
-		removeTempFile(dir, binary)
-		removeTempFile(dir, binary+".go")
-		removeTempFile(dir, "go.mod")
-		removeTempFile(dir, "go.sum")
+		removeProgramSource(dir, source)
+		os.Remove(binary)
+		releaseLock()
 		os.Exit(2)
 	}
 }
 
-// runMigrationBinary runs the migration program who does the actual work
-func runMigrationBinary(dir, binary string) {
+// runMigrationBinary runs the migration program who does the actual work, in dir.
+// The connection string is passed through the environment.
+func runMigrationBinary(dir, binary, connStr string, args ...string) {
 	changeDir(dir)
-	cmd := exec.Command("./" + binary)
+	cmd := exec.Command(binary, args...)
+	cmd.Env = append(os.Environ(), connEnv+"="+connStr)
 	if out, err := cmd.CombinedOutput(); err != nil {
 		formatShellOutput(string(out))
-		beeLogger.Log.Errorf("Could not run migration binary2: %s", err)
-		removeTempFile(dir, binary)
-		removeTempFile(dir, binary+".go")
-		removeTempFile(dir, "go.mod")
-		removeTempFile(dir, "go.sum")
+		beeLogger.Log.Errorf("Could not run migration binary: %s", err)
+		releaseLock()
 		os.Exit(2)
 	} else {
 		formatShellOutput(string(out))
 	}
 }
 
+// removeProgramSource removes the source of a generated program and the module files created to build it
+func removeProgramSource(dir, source string) {
+	for _, file := range []string{source, "go.mod", "go.sum"} {
+		if utils.IsExist(filepath.Join(dir, file)) {
+			removeTempFile(dir, file)
+		}
+	}
+}
+
 // changeDir changes working directory to dir.
 // It exits the system when encouter an error
 func changeDir(dir string) {
@@ -390,38 +594,271 @@ const (
 	MigrationMainTPL = `package main
 
 import(
+	"flag"
+	"fmt"
 	"os"
+	"reflect"
+	"sort"
 
+	"github.com/astaxie/beego/logs"
 	"github.com/astaxie/beego/orm"
 	"github.com/astaxie/beego/migration"
 
 	_ "{{DriverRepo}}"
 )
 
-func init(){
-	orm.RegisterDataBase("default", "{{DBDriver}}","{{ConnStr}}")
+// source builds one of the migrations found in this directory
+type source struct {
+	name string
+	new  func() migration.Migrationer
+}
+
+var sources = []source{
+{{Migrations}}
 }
 
+// dryRun prints the statements of each migration instead of executing them
+var dryRun bool
+
+// st holds the latest status of every migration, kept up to date while running
+var st = map[string]string{}
+
+// main runs the task given by bee. The program is cached between runs, so it
+// reads everything that may change from its arguments and environment.
 func main(){
-	task := "{{Task}}"
+	task := flag.String("task", "upgrade", "")
+	latestTime := flag.Int64("latest-time", 0, "")
+	latestName := flag.String("latest-name", "", "")
+	target := flag.Int64("target", 0, "")
+	steps := flag.Int("steps", 0, "")
+	noHistory := flag.Bool("no-history", false, "")
+	flag.BoolVar(&dryRun, "dry-run", false, "")
+	flag.Parse()
+
+	if err := orm.RegisterDataBase("default", "{{DBDriver}}", os.Getenv("{{ConnEnv}}")); err != nil {
+		logs.Error("could not connect to database:", err)
+		os.Exit(2)
+	}
+	if dryRun || *task == "up" || *task == "down" || *task == "redo" {
+		if err := loadStatuses(*noHistory); err != nil {
+			os.Exit(2)
+		}
+	}
+	if err := runTask(*task, *latestTime, *latestName, *target, *steps); err != nil {
+		os.Exit(2)
+	}
+}
+`
+
+	// MigrationRunnerTPL is shared by the migration main and the package embedding the migrations.
+	// It expects the sources, dryRun and st variables to be declared.
+	MigrationRunnerTPL = `
+// runTask runs a migration task. latestTime and latestName identify the latest applied migration.
+func runTask(task string, latestTime int64, latestName string, target int64, steps int) (err error) {
 	switch task {
 	case "upgrade":
-		if err := migration.Upgrade({{LatestTime}}); err != nil {
-			os.Exit(2)
+		if dryRun {
+			// migration.Upgrade skips every migration that has a record
+			_, err = up(0, 0, hasRecord)
+		} else {
+			err = migration.Upgrade(latestTime)
 		}
 	case "rollback":
-		if err := migration.Rollback("{{LatestName}}"); err != nil {
-			os.Exit(2)
+		if dryRun {
+			err = rollback(latestName)
+		} else {
+			err = migration.Rollback(latestName)
 		}
 	case "reset":
-		if err := migration.Reset(); err != nil {
-			os.Exit(2)
+		if dryRun {
+			// migration.Reset rolls back every migration not rolled back yet
+			_, err = down(0, 0, notRolledBack)
+		} else {
+			err = migration.Reset()
 		}
 	case "refresh":
-		if err := migration.Refresh(); err != nil {
-			os.Exit(2)
+		if dryRun {
+			if _, err = down(0, 0, notRolledBack); err == nil {
+				_, err = up(0, 0, isApplied)
+			}
+		} else {
+			err = migration.Refresh()
+		}
+	case "up":
+		_, err = up(target, steps, isApplied)
+	case "down":
+		_, err = down(target, steps, isApplied)
+	case "redo":
+		err = redo(steps)
+	default:
+		err = fmt.Errorf("unknown migrate command: %s", task)
+		logs.Error(err)
+	}
+	return
+}
+
+type entry struct {
+	name string
+	m    migration.Migrationer
+}
+
+// entries returns the migrations sorted by creation time, built the same way
+// their init functions build them
+func entries() []entry {
+	l := make([]entry, 0, len(sources))
+	for _, s := range sources {
+		m := s.new()
+		if d, ok := m.(interface{ ddlSpec() }); ok {
+			d.ddlSpec()
+		}
+		l = append(l, entry{s.name, m})
+	}
+	sort.SliceStable(l, func(i, j int) bool {
+		return l[i].m.GetCreated() < l[j].m.GetCreated()
+	})
+	return l
+}
+
+// loadStatuses reads the latest status recorded for every migration
+func loadStatuses(noHistory bool) error {
+	if noHistory {
+		return nil
+	}
+	var maps []orm.Params
+	if _, err := orm.NewOrm().Raw("SELECT name, status FROM migrations ORDER BY id_migration").Values(&maps); err != nil {
+		logs.Error("could not read migrations:", err)
+		return err
+	}
+	for _, v := range maps {
+		name, _ := v["name"].(string)
+		status, _ := v["status"].(string)
+		st[name] = status
+	}
+	return nil
+}
+
+func isApplied(status string) bool {
+	return status == "update"
+}
+
+func hasRecord(status string) bool {
+	return status != ""
+}
+
+func notRolledBack(status string) bool {
+	return status != "rollback"
+}
+
+// statements returns the SQL queued on a migration. The migration package
+// keeps it unexported, so it is read through reflection.
+func statements(m migration.Migrationer) []string {
+	v := reflect.Indirect(reflect.ValueOf(m)).FieldByName("sqls")
+	l := make([]string, 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		l = append(l, v.Index(i).String())
+	}
+	return l
+}
+
+func run(e entry, direction string) error {
+	e.m.Reset()
+	if direction == "up" {
+		e.m.Up()
+	} else {
+		e.m.Down()
+	}
+	if dryRun {
+		fmt.Printf("-- %s %s\n", direction, e.name)
+		for _, s := range statements(e.m) {
+			fmt.Printf("%s;\n", s)
+		}
+	} else {
+		logs.Info("start", direction, e.name)
+		if err := e.m.Exec(e.name, direction); err != nil {
+			logs.Error("execute error:", err)
+			return err
+		}
+		logs.Info("end", direction, e.name)
+	}
+	if direction == "up" {
+		st[e.name] = "update"
+	} else {
+		st[e.name] = "rollback"
+	}
+	return nil
+}
+
+// up applies the migrations not marked done, created at or before target,
+// stopping after steps of them. Zero disables either bound.
+func up(target int64, steps int, done func(status string) bool) ([]entry, error) {
+	var l []entry
+	for _, e := range entries() {
+		if target > 0 && e.m.GetCreated() > target {
+			break
+		}
+		if steps > 0 && len(l) >= steps {
+			break
+		}
+		if done(st[e.name]) {
+			continue
+		}
+		if err := run(e, "up"); err != nil {
+			return l, err
+		}
+		l = append(l, e)
+	}
+	logs.Info("total success upgrade:", len(l), " migration")
+	return l, nil
+}
+
+// down rolls back the applied migrations created after target, newest first,
+// stopping after steps of them. Zero disables either bound.
+func down(target int64, steps int, applied func(status string) bool) ([]entry, error) {
+	all := entries()
+	var l []entry
+	for i := len(all) - 1; i >= 0; i-- {
+		e := all[i]
+		if target > 0 && e.m.GetCreated() <= target {
+			break
+		}
+		if steps > 0 && len(l) >= steps {
+			break
+		}
+		if !applied(st[e.name]) {
+			continue
+		}
+		if err := run(e, "down"); err != nil {
+			return l, err
+		}
+		l = append(l, e)
+	}
+	logs.Info("total success rollback:", len(l), " migration")
+	return l, nil
+}
+
+// redo rolls back the last steps applied migrations and applies them again
+func redo(steps int) error {
+	l, err := down(0, steps, isApplied)
+	if err != nil {
+		return err
+	}
+	for i := len(l) - 1; i >= 0; i-- {
+		if err := run(l[i], "up"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rollback rolls back a single migration by name
+func rollback(name string) error {
+	for _, e := range entries() {
+		if e.name == name {
+			return run(e, "down")
 		}
 	}
+	logs.Error("not exist the migration name:", name)
+	return fmt.Errorf("not exist the migration name: %s", name)
 }
 
 `
@@ -448,25 +885,102 @@ CREATE TABLE migrations (
 	statements text,
 	rollback_statements text,
 	status migrations_status
+)`
+	// SQLiteMigrationDDL SQLite migration SQL
+	SQLiteMigrationDDL = `
+CREATE TABLE migrations (
+	id_migration INTEGER PRIMARY KEY AUTOINCREMENT,
+	name varchar(255) DEFAULT NULL,
+	created_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	statements text,
+	rollback_statements text,
+	status varchar(8) CHECK (status IN ('update', 'rollback'))
+)`
+	// MYSQLChecksumDDL MySQL migration checksums SQL
+	MYSQLChecksumDDL = `
+CREATE TABLE IF NOT EXISTS migration_checksums (
+	name varchar(255) NOT NULL COMMENT 'migration name',
+	checksum char(64) NOT NULL COMMENT 'SHA-256 of the migration source when it was applied',
+	created_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT 'date recorded',
+	PRIMARY KEY (name)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8
+`
+	// POSTGRESChecksumDDL Postgres migration checksums SQL
+	POSTGRESChecksumDDL = `
+CREATE TABLE IF NOT EXISTS migration_checksums (
+	name varchar(255) PRIMARY KEY,
+	checksum char(64) NOT NULL,
+	created_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`
+	// SQLiteChecksumDDL SQLite migration checksums SQL
+	SQLiteChecksumDDL = `
+CREATE TABLE IF NOT EXISTS migration_checksums (
+	name varchar(255) PRIMARY KEY,
+	checksum char(64) NOT NULL,
+	created_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`
+	// MYSQLSeedsDDL MySQL seeds SQL
+	MYSQLSeedsDDL = `
+CREATE TABLE IF NOT EXISTS seeds (
+	name varchar(255) NOT NULL COMMENT 'seed file name, without extension',
+	applied_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT 'date applied',
+	PRIMARY KEY (name)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8
+`
+	// POSTGRESSeedsDDL Postgres seeds SQL
+	POSTGRESSeedsDDL = `
+CREATE TABLE IF NOT EXISTS seeds (
+	name varchar(255) PRIMARY KEY,
+	applied_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`
+	// SQLiteSeedsDDL SQLite seeds SQL
+	SQLiteSeedsDDL = `
+CREATE TABLE IF NOT EXISTS seeds (
+	name varchar(255) PRIMARY KEY,
+	applied_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`
+	// SQLiteLockDDL SQLite table holding the migrations lock
+	SQLiteLockDDL = `
+CREATE TABLE IF NOT EXISTS migrations_lock (
+	id INTEGER PRIMARY KEY CHECK (id = 1),
+	holder varchar(255) NOT NULL,
+	locked_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP
 )`
 )
 
 // MigrateUpdate does the schema update
 func MigrateUpdate(currpath, driver, connStr, dir string) {
-	migrate("upgrade", currpath, driver, connStr, dir)
+	migrate("upgrade", currpath, driver, connStr, dir, 0, 0)
+}
+
+// MigrateUp applies outstanding migrations up to the one created at target,
+// or only the next steps of them. A zero target or steps lifts that bound.
+func MigrateUp(currpath, driver, connStr, dir string, target int64, steps int) {
+	migrate("up", currpath, driver, connStr, dir, target, steps)
+}
+
+// MigrateDown rolls back the applied migrations created after target,
+// or only the last steps of them. A zero target or steps lifts that bound.
+func MigrateDown(currpath, driver, connStr, dir string, target int64, steps int) {
+	migrate("down", currpath, driver, connStr, dir, target, steps)
+}
+
+// MigrateRedo rolls back the last steps applied migrations and applies them again
+func MigrateRedo(currpath, driver, connStr, dir string, steps int) {
+	migrate("redo", currpath, driver, connStr, dir, 0, steps)
 }
 
 // MigrateRollback rolls back the latest migration
 func MigrateRollback(currpath, driver, connStr, dir string) {
-	migrate("rollback", currpath, driver, connStr, dir)
+	migrate("rollback", currpath, driver, connStr, dir, 0, 0)
 }
 
 // MigrateReset rolls back all migrations
 func MigrateReset(currpath, driver, connStr, dir string) {
-	migrate("reset", currpath, driver, connStr, dir)
+	migrate("reset", currpath, driver, connStr, dir, 0, 0)
 }
 
 // MigrateRefresh rolls back all migrations and start over again
 func MigrateRefresh(currpath, driver, connStr, dir string) {
-	migrate("refresh", currpath, driver, connStr, dir)
+	migrate("refresh", currpath, driver, connStr, dir, 0, 0)
 }
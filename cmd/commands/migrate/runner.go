@@ -0,0 +1,93 @@
+// Copyright 2013 bee authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+package migrate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	beeLogger "github.com/beego/bee/logger"
+	"github.com/beego/bee/utils"
+)
+
+// compileRunner returns the path of the program built from content and the Go files of dir.
+// Programs are cached, keyed by a hash of their sources, so that repeat runs skip compilation.
+func compileRunner(dir, source, binary, content string) string {
+	cacheDir := runnerCacheDir(dir)
+	runner := filepath.Join(cacheDir, runnerKey(dir, source, content), binary)
+	if utils.IsExist(runner) {
+		beeLogger.Log.Info("Using cached migration binary")
+		return runner
+	}
+
+	beeLogger.Log.Info("Compiling migrations")
+	// only the binary of the current sources is kept
+	if err := os.RemoveAll(cacheDir); err != nil {
+		beeLogger.Log.Warnf("Could not clear migration binary cache: %s", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(runner), 0777); err != nil {
+		beeLogger.Log.Fatalf("Could not create migration binary cache: %s", err)
+	}
+	changeDir(dir)
+	if err := ioutil.WriteFile(source, []byte(content), 0666); err != nil {
+		beeLogger.Log.Fatalf("Could not write to file: %s", err)
+	}
+	buildMigrationBinary(dir, source, runner)
+	removeProgramSource(dir, source)
+	return runner
+}
+
+// runnerCacheDir returns the directory caching the programs built in dir
+func runnerCacheDir(dir string) string {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		base = os.TempDir()
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		abs = dir
+	}
+	sum := sha256.Sum256([]byte(abs))
+	return filepath.Join(base, "bee", "migrate", hex.EncodeToString(sum[:8]))
+}
+
+// runnerKey hashes what a program built in dir depends on: its generated source,
+// which includes bee's template, and the other Go files of dir
+func runnerKey(dir, source, content string) string {
+	h := sha256.New()
+	h.Write([]byte(content + "\x00"))
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		beeLogger.Log.Fatalf("Could not list files: %s", err)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		if filepath.Base(file) == source || strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			beeLogger.Log.Fatalf("Could not read file '%s': %s", file, err)
+		}
+		h.Write([]byte(filepath.Base(file) + "\x00"))
+		h.Write(b)
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,258 @@
+// Copyright 2013 bee authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+package migrate
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/beego/bee/config"
+	beeLogger "github.com/beego/bee/logger"
+)
+
+// seedRunmodesRegex matches the comment limiting a seed to some runmodes, e.g. // bee:runmodes dev,test
+var seedRunmodesRegex = regexp.MustCompile(`(?m)^\s*(?://|--)\s*bee:runmodes\s+(.+)$`)
+
+// seedSource describes a file of the seeds directory
+type seedSource struct {
+	Name     string // file name without extension, recorded in the seeds table
+	File     string
+	Func     string   // seed function of a Go seed, empty for SQL seeds
+	Runmodes []string // runmodes the seed is limited to, empty for all
+}
+
+// readSeedSources returns the SQL and Go seeds in dir, sorted by file name
+func readSeedSources(dir, skip string) (seeds []seedSource) {
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		beeLogger.Log.Fatalf("Could not list seed files: %s", err)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		ext := filepath.Ext(file)
+		if filepath.Base(file) == skip || (ext != ".sql" && ext != ".go") || strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			beeLogger.Log.Fatalf("Could not read seed file '%s': %s", file, err)
+		}
+		seed := seedSource{Name: strings.TrimSuffix(filepath.Base(file), ext), File: file}
+		if m := seedRunmodesRegex.FindSubmatch(content); m != nil {
+			for _, mode := range strings.Split(string(m[1]), ",") {
+				if mode = strings.TrimSpace(mode); mode != "" {
+					seed.Runmodes = append(seed.Runmodes, mode)
+				}
+			}
+		}
+		if ext == ".go" {
+			if seed.Func = seedFunc(file, content); seed.Func == "" {
+				beeLogger.Log.Warnf("No func(orm.Ormer) error found in '%s'. It will be ignored", file)
+				continue
+			}
+		}
+		seeds = append(seeds, seed)
+	}
+	return
+}
+
+// seedFunc returns the name of the function of a Go seed, i.e. func SeedX(o orm.Ormer) error
+func seedFunc(file string, content []byte) string {
+	f, err := parser.ParseFile(token.NewFileSet(), file, content, 0)
+	if err != nil {
+		beeLogger.Log.Fatalf("Could not parse seed file '%s': %s", file, err)
+	}
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv != nil || !fn.Name.IsExported() {
+			continue
+		}
+		params, results := fn.Type.Params.List, fn.Type.Results
+		if len(params) == 1 && len(params[0].Names) <= 1 && isSelector(params[0].Type, "orm", "Ormer") &&
+			results != nil && len(results.List) == 1 {
+			return fn.Name.Name
+		}
+	}
+	return ""
+}
+
+// appliesTo reports whether a seed runs in the given runmode
+func (s seedSource) appliesTo(runmode string) bool {
+	if len(s.Runmodes) == 0 {
+		return true
+	}
+	for _, mode := range s.Runmodes {
+		if mode == runmode {
+			return true
+		}
+	}
+	return false
+}
+
+// MigrateSeed applies the seeds of dir that were not applied yet and are not limited to
+// other runmodes. Each seed runs in a transaction which also records it in the seeds table.
+func MigrateSeed(currpath, driver, connStr, dir string) {
+	binary, source := programFiles("s")
+	seeds := readSeedSources(dir, source)
+	runmode := config.Runmode(currpath, mEnv.String())
+	beeLogger.Log.Infof("Using '%s' as 'runmode'", runmode)
+
+	dialect := getDialect(driver)
+	db, err := sql.Open(sqlDriverName(driver), connStr)
+	if err != nil {
+		beeLogger.Log.Fatalf("Could not connect to database using '%s': %s", connStr, err)
+	}
+	defer db.Close()
+	if !mDryRun {
+		lockMigrations(db, dialect)
+		defer releaseLock()
+		dialect.CreateSeedsTable(db)
+	}
+	applied := map[string]bool{}
+	if dialect.TableExists(db, "seeds") {
+		applied = dialect.AppliedSeeds(db)
+	}
+
+	var pending []seedSource
+	hasGo := false
+	for _, s := range seeds {
+		if applied[s.Name] || !s.appliesTo(runmode) {
+			continue
+		}
+		pending = append(pending, s)
+		hasGo = hasGo || s.Func != ""
+	}
+	if len(pending) == 0 {
+		beeLogger.Log.Info("No seeds to apply")
+		return
+	}
+	if mDryRun {
+		for _, s := range pending {
+			beeLogger.Log.Infof("Would apply seed '%s'", s.Name)
+		}
+		return
+	}
+
+	var runner string
+	if hasGo {
+		runner = compileRunner(dir, source, binary, seedSourceCode(driver, seeds))
+	}
+	for _, s := range pending {
+		beeLogger.Log.Infof("Applying seed '%s'", s.Name)
+		if s.Func != "" {
+			runMigrationBinary(dir, runner, connStr, s.Name)
+			continue
+		}
+		applySQLSeed(db, dialect, s)
+	}
+}
+
+// applySQLSeed runs the statements of a SQL seed and records it in a single transaction
+func applySQLSeed(db *sql.DB, dialect MigrationDialect, s seedSource) {
+	content, err := ioutil.ReadFile(s.File)
+	if err != nil {
+		beeLogger.Log.Fatalf("Could not read seed file '%s': %s", s.File, err)
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		beeLogger.Log.Fatalf("Could not begin transaction: %s", err)
+	}
+	for _, stmt := range splitStatements(string(content)) {
+		if _, err := tx.Exec(stmt); err != nil {
+			tx.Rollback()
+			releaseLock()
+			beeLogger.Log.Fatalf("Could not apply seed '%s': %s", s.Name, err)
+		}
+	}
+	dialect.InsertSeed(tx, s.Name)
+	if err := tx.Commit(); err != nil {
+		releaseLock()
+		beeLogger.Log.Fatalf("Could not apply seed '%s': %s", s.Name, err)
+	}
+}
+
+// seedSourceCode renders the source of the program running the Go seeds based on SeedMainTPL.
+// It lists every Go seed so that the program is only built again when the seeds change.
+func seedSourceCode(driver string, seeds []seedSource) string {
+	var entries bytes.Buffer
+	for _, s := range seeds {
+		if s.Func != "" {
+			fmt.Fprintf(&entries, "\t%q: %s,\n", s.Name, s.Func)
+		}
+	}
+	content := strings.Replace(SeedMainTPL, "{{DBDriver}}", sqlDriverName(driver), -1)
+	content = strings.Replace(content, "{{DriverRepo}}", driverImportStatement(driver), -1)
+	content = strings.Replace(content, "{{ConnEnv}}", connEnv, -1)
+	content = strings.Replace(content, "{{Seeds}}", strings.TrimSuffix(entries.String(), "\n"), -1)
+	return content
+}
+
+// SeedMainTPL seed runner template
+const SeedMainTPL = `package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/astaxie/beego/orm"
+	_ "{{DriverRepo}}"
+)
+
+var seeds = map[string]func(orm.Ormer) error{
+{{Seeds}}
+}
+
+func main() {
+	if err := orm.RegisterDataBase("default", "{{DBDriver}}", os.Getenv("{{ConnEnv}}")); err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	o := orm.NewOrm()
+	for _, name := range os.Args[1:] {
+		seed, ok := seeds[name]
+		if !ok {
+			fmt.Println("unknown seed:", name)
+			os.Exit(2)
+		}
+		if err := o.Begin(); err != nil {
+			fmt.Println(err)
+			os.Exit(2)
+		}
+		if err := seed(o); err != nil {
+			o.Rollback()
+			fmt.Printf("seed %s failed: %s\n", name, err)
+			os.Exit(2)
+		}
+		if _, err := o.Raw("INSERT INTO seeds(name) VALUES(?)", name).Exec(); err != nil {
+			o.Rollback()
+			fmt.Printf("could not record seed %s: %s\n", name, err)
+			os.Exit(2)
+		}
+		if err := o.Commit(); err != nil {
+			fmt.Println(err)
+			os.Exit(2)
+		}
+		fmt.Println("applied seed", name)
+	}
+}
+`
@@ -0,0 +1,101 @@
+// Copyright 2013 bee authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package migrate
+
+import (
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"path"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// checkProgram fails t if the rendered src is not valid Go, has placeholders left,
+// uses a package it does not import or imports a package it does not use
+func checkProgram(t *testing.T, src string) {
+	t.Helper()
+	if strings.Contains(src, "{{") {
+		t.Errorf("placeholder left in:\n%s", src)
+	}
+	if _, err := format.Source([]byte(src)); err != nil {
+		t.Fatalf("invalid source: %s\n%s", err, src)
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "main.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	used := make(map[string]bool)
+	for _, imp := range f.Imports {
+		p, _ := strconv.Unquote(imp.Path.Value)
+		name := path.Base(p)
+		if imp.Name != nil {
+			name = imp.Name.Name
+		}
+		if name != "_" {
+			used[name] = false
+		}
+	}
+	ast.Inspect(f, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		if id, ok := sel.X.(*ast.Ident); ok && id.Obj == nil {
+			if _, ok := used[id.Name]; !ok {
+				t.Errorf("%s: undefined: %s", fset.Position(id.Pos()), id.Name)
+			}
+			used[id.Name] = true
+		}
+		return true
+	})
+	for name, ok := range used {
+		if !ok {
+			t.Errorf("%q imported and not used", name)
+		}
+	}
+}
+
+func TestSeedSourceCode(t *testing.T) {
+	seeds := []seedSource{
+		{Name: "20200101_users", Func: "SeedUsers"},
+		{Name: "20200102_roles"},
+		{Name: "20200103_posts", Func: "SeedPosts"},
+	}
+	tests := []struct {
+		driver string
+		seeds  []seedSource
+		want   []string
+	}{
+		{"mysql", seeds, []string{`"github.com/go-sql-driver/mysql"`, `"20200101_users": SeedUsers,`, `"20200103_posts": SeedPosts,`}},
+		{"postgres", seeds, []string{`"github.com/lib/pq"`, `"postgres"`}},
+		{"sqlite", seeds[1:2], []string{`"github.com/mattn/go-sqlite3"`, `"sqlite3"`}},
+	}
+	for _, tt := range tests {
+		src := seedSourceCode(tt.driver, tt.seeds)
+		checkProgram(t, src)
+		for _, want := range tt.want {
+			if !strings.Contains(src, want) {
+				t.Errorf("%s: source does not contain %s", tt.driver, want)
+			}
+		}
+		if strings.Contains(src, "20200102_roles") {
+			t.Errorf("%s: SQL seed listed in the Go seeds", tt.driver)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright 2013 bee authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+package migrate
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	beeLogger "github.com/beego/bee/logger"
+)
+
+// migrationDateFormat is the timestamp format used in migration names
+const migrationDateFormat = "20060102_150405"
+
+// migrationSource describes a migration registered by a file of the migrations directory
+type migrationSource struct {
+	Name     string // name given to migration.Register
+	Type     string // struct type embedding migration.Migration
+	Created  string // value assigned to the Created field
+	File     string
+	Squashed []string // migrations replaced by a baseline, in the order they were applied
+}
+
+// squashedRegex matches the comments of a baseline naming the migrations it replaces
+var squashedRegex = regexp.MustCompile(`(?m)^//\s*bee:squashed\s+(\S+)\s*$`)
+
+// readMigrationSources parses the Go files in dir, except skip, and returns
+// the migrations they register, sorted by file name.
+func readMigrationSources(dir, skip string) (sources []migrationSource) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		beeLogger.Log.Fatalf("Could not list migration files: %s", err)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		if filepath.Base(file) == skip || strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			beeLogger.Log.Fatalf("Could not read migration file '%s': %s", file, err)
+		}
+		f, err := parser.ParseFile(token.NewFileSet(), file, content, 0)
+		if err != nil {
+			beeLogger.Log.Fatalf("Could not parse migration file '%s': %s", file, err)
+		}
+		src := migrationSource{File: file}
+		for _, m := range squashedRegex.FindAllSubmatch(content, -1) {
+			src.Squashed = append(src.Squashed, string(m[1]))
+		}
+		ast.Inspect(f, func(n ast.Node) bool {
+			switch x := n.(type) {
+			case *ast.TypeSpec:
+				if st, ok := x.Type.(*ast.StructType); ok && embedsMigration(st) {
+					src.Type = x.Name.Name
+				}
+			case *ast.CallExpr:
+				if isSelector(x.Fun, "migration", "Register") && len(x.Args) == 2 {
+					src.Name = stringLit(x.Args[0])
+				}
+			case *ast.AssignStmt:
+				if len(x.Lhs) == 1 && len(x.Rhs) == 1 {
+					if sel, ok := x.Lhs[0].(*ast.SelectorExpr); ok && sel.Sel.Name == "Created" {
+						src.Created = stringLit(x.Rhs[0])
+					}
+				}
+			}
+			return true
+		})
+		if src.Name == "" || src.Type == "" {
+			beeLogger.Log.Warnf("No migration registered in '%s'. It will be ignored by up, down and redo", file)
+			continue
+		}
+		sources = append(sources, src)
+	}
+	return
+}
+
+// migrationSourcesCode renders the sources as entries of the migration main's source list
+func migrationSourcesCode(sources []migrationSource) string {
+	var buf bytes.Buffer
+	for _, s := range sources {
+		fmt.Fprintf(&buf, "\t{%q, func() migration.Migrationer {\n\t\tm := &%s{}\n", s.Name, s.Type)
+		if s.Created != "" {
+			fmt.Fprintf(&buf, "\t\tm.Created = %q\n", s.Created)
+		}
+		buf.WriteString("\t\treturn m\n\t}},\n")
+	}
+	return buf.String()
+}
+
+func embedsMigration(st *ast.StructType) bool {
+	for _, field := range st.Fields.List {
+		if len(field.Names) == 0 && isSelector(field.Type, "migration", "Migration") {
+			return true
+		}
+	}
+	return false
+}
+
+func isSelector(expr ast.Expr, pkg, name string) bool {
+	sel, ok := expr.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != name {
+		return false
+	}
+	ident, ok := sel.X.(*ast.Ident)
+	return ok && ident.Name == pkg
+}
+
+func stringLit(expr ast.Expr) string {
+	lit, ok := expr.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return ""
+	}
+	s, err := strconv.Unquote(lit.Value)
+	if err != nil {
+		return ""
+	}
+	return s
+}
@@ -0,0 +1,99 @@
+// Copyright 2013 bee authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package migrate
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const usersMigration = `package main
+
+import "github.com/astaxie/beego/migration"
+
+// CreateUsers_20200101_120000 creates the users table
+type CreateUsers_20200101_120000 struct {
+	migration.Migration
+}
+
+func init() {
+	m := &CreateUsers_20200101_120000{}
+	m.Created = "20200101_120000"
+	migration.Register("CreateUsers_20200101_120000", m)
+}
+`
+
+func TestReadMigrationSources(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	writeFile(t, dir, "20200101_120000_create_users.go", usersMigration)
+	writeFile(t, dir, "20200102_120000_create_posts.go", strings.Replace(usersMigration, "CreateUsers_20200101_120000", "CreatePosts_20200102_120000", -1))
+	writeFile(t, dir, "helpers.go", "package main\n\nfunc helper() {}\n")
+	writeFile(t, dir, "migrations_test.go", usersMigration)
+	writeFile(t, dir, "m.go", usersMigration)
+
+	tests := []struct {
+		skip string
+		want []string
+	}{
+		{"", []string{"CreateUsers_20200101_120000", "CreatePosts_20200102_120000", "CreateUsers_20200101_120000"}},
+		{"m.go", []string{"CreateUsers_20200101_120000", "CreatePosts_20200102_120000"}},
+	}
+	for _, tt := range tests {
+		var names []string
+		for _, s := range readMigrationSources(dir, tt.skip) {
+			names = append(names, s.Name)
+		}
+		if !reflect.DeepEqual(names, tt.want) {
+			t.Errorf("skip %q: read %v, want %v", tt.skip, names, tt.want)
+		}
+	}
+
+	sources := readMigrationSources(dir, "m.go")
+	want := migrationSource{
+		Name:    "CreateUsers_20200101_120000",
+		Type:    "CreateUsers_20200101_120000",
+		Created: "20200101_120000",
+		File:    filepath.Join(dir, "20200101_120000_create_users.go"),
+	}
+	if !reflect.DeepEqual(sources[0], want) {
+		t.Errorf("read %+v, want %+v", sources[0], want)
+	}
+}
+
+func TestMigrationSourceCode(t *testing.T) {
+	sources := []migrationSource{
+		{Name: "CreateUsers_20200101_120000", Type: "CreateUsers_20200101_120000", Created: "20200101_120000"},
+		{Name: "create_posts", Type: "CreatePosts"},
+	}
+	want := "\t{\"CreateUsers_20200101_120000\", func() migration.Migrationer {\n" +
+		"\t\tm := &CreateUsers_20200101_120000{}\n" +
+		"\t\tm.Created = \"20200101_120000\"\n" +
+		"\t\treturn m\n" +
+		"\t}},\n" +
+		"\t{\"create_posts\", func() migration.Migrationer {\n" +
+		"\t\tm := &CreatePosts{}\n" +
+		"\t\treturn m\n" +
+		"\t}},\n"
+	if got := migrationSourcesCode(sources); got != want {
+		t.Errorf("migrationSourcesCode:\n%s\nwant\n%s", got, want)
+	}
+
+	for _, driver := range []string{"mysql", "postgres", "sqlite"} {
+		checkProgram(t, migrationSourceCode(driver, sources))
+	}
+}
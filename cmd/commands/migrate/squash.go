@@ -0,0 +1,361 @@
+// Copyright 2013 bee authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+package migrate
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	beeLogger "github.com/beego/bee/logger"
+	"github.com/beego/bee/utils"
+)
+
+var createTableRegex = regexp.MustCompile(`^CREATE TABLE\s+(\S+)`)
+
+// squashedDir is the directory of the migrations directory the squashed migrations are moved to
+const squashedDir = "squashed"
+
+// ignoreConstraint excludes the squashed migrations from the build. Their directory would
+// otherwise be a main package without a main function.
+const ignoreConstraint = "//go:build ignore\n// +build ignore\n\n"
+
+// MigrateSquash replaces the migrations created at or before the given timestamp with a
+// baseline migration creating the schema they result in. The schema is read from a scratch
+// database the migrations are applied to, and checked by loading it into a second one.
+// The squashed migrations are moved to the squashed directory. When the database of the
+// environment applied them, it records the baseline as applied in their place.
+func MigrateSquash(currpath, driver, connStr, dir, scratchConn, baselineConn, before string) {
+	if SchemaDumper == nil || SchemaCatalog == nil {
+		beeLogger.Log.Fatal("Squashing migrations is not available in this build")
+	}
+	t, err := time.Parse(migrationDateFormat, before)
+	if err != nil {
+		beeLogger.Log.Hint("Expecting the timestamp of a migration, i.e. -before=20060102_150405")
+		beeLogger.Log.Fatalf("Could not parse '%s': %s", before, err)
+	}
+	binary, source := migrationProgram()
+	sources := readMigrationSources(dir, source)
+	var squashed []migrationSource
+	for _, s := range sources {
+		if created, err := time.Parse(migrationDateFormat, sourceCreated(s)); err == nil && !created.After(t) {
+			squashed = append(squashed, s)
+		}
+	}
+	if len(squashed) == 0 {
+		beeLogger.Log.Fatalf("No migrations created at or before %s", before)
+	}
+	sort.SliceStable(squashed, func(i, j int) bool { return sourceCreated(squashed[i]) < sourceCreated(squashed[j]) })
+	var names []string
+	for _, s := range squashed {
+		names = append(names, s.Name)
+	}
+	created := sourceCreated(squashed[len(squashed)-1])
+	name := "Baseline_" + created
+	file := filepath.Join(dir, created+"_baseline.go")
+	if utils.IsExist(file) && !isSquashed(file, squashed) {
+		beeLogger.Log.Fatalf("Baseline '%s' already exists", file)
+	}
+	for _, s := range squashed {
+		if moved := squashedFile(dir, s); utils.IsExist(moved) {
+			beeLogger.Log.Fatalf("Squashed migration '%s' already exists", moved)
+		}
+	}
+
+	dialect := getDialect(driver)
+	db, err := sql.Open(sqlDriverName(driver), connStr)
+	if err != nil {
+		beeLogger.Log.Fatalf("Could not connect to database using '%s': %s", connStr, err)
+	}
+	defer db.Close()
+	applied := 0
+	if dialect.TableExists(db, "migrations") {
+		applied = appliedCount(latestRecords(db, dialect), names)
+	}
+	if applied > 0 && applied < len(names) {
+		beeLogger.Log.Hint("Apply the outstanding migrations with 'bee migrate up', or squash fewer of them")
+		beeLogger.Log.Fatalf("The database applied %d of the %d migrations to squash", applied, len(names))
+	}
+	if mDryRun {
+		for _, s := range squashed {
+			beeLogger.Log.Infof("Would squash '%s' and move it to '%s'", s.Name, squashedFile(dir, s))
+		}
+		beeLogger.Log.Infof("Would write baseline '%s'", file)
+		return
+	}
+
+	statements := scratchSchema(driver, dir, source, binary, scratchConn, baselineConn, sources, t)
+	moveSquashed(dir, squashed)
+	writeBaseline(file, name, created, driver, names, statements)
+	beeLogger.Log.Infof("Squashed %d migration(s) into '%s'", len(squashed), file)
+	beeLogger.Log.Infof("The squashed migrations were moved to '%s', out of the build", filepath.Join(dir, squashedDir))
+
+	if applied > 0 {
+		lockMigrations(db, dialect)
+		defer releaseLock()
+		checkForSchemaUpdateTable(db, dialect)
+		recordBaselines(db, dialect, readMigrationSources(dir, source))
+	}
+}
+
+// sourceCreated returns the timestamp a migration was created at
+func sourceCreated(s migrationSource) string {
+	if s.Created != "" {
+		return s.Created
+	}
+	return migrationTimestamp(s.Name)
+}
+
+// isSquashed reports whether file is the source of one of the migrations
+func isSquashed(file string, sources []migrationSource) bool {
+	for _, s := range sources {
+		if s.File == file {
+			return true
+		}
+	}
+	return false
+}
+
+// appliedCount returns how many of the migrations are applied
+func appliedCount(latest map[string]MigrationRecord, names []string) (n int) {
+	for _, name := range names {
+		if latest[name].Status == "update" {
+			n++
+		}
+	}
+	return
+}
+
+// scratchSchema applies the migrations created at or before t to an empty scratch
+// database and returns its schema. The schema is loaded into a second empty scratch
+// database, whose dump and catalog must match the ones of the first, so that nothing
+// the dump leaves out, i.e. a view or a trigger, is lost by the baseline.
+func scratchSchema(driver, dir, source, binary, scratchConn, baselineConn string, sources []migrationSource, t time.Time) []string {
+	if scratchConn != "" && scratchConn == baselineConn {
+		beeLogger.Log.Fatal("The scratch databases must be different")
+	}
+	dialect := getDialect(driver)
+	db, scratchConn, cleanup := openScratch(driver, scratchConn, "scratch")
+	defer cleanup()
+	loaded, _, cleanupLoaded := openScratch(driver, baselineConn, "baseline-scratch")
+	defer cleanupLoaded()
+	checkForSchemaUpdateTable(db, dialect)
+
+	beeLogger.Log.Info("Applying the migrations to the scratch database")
+	runner := compileRunner(dir, source, binary, migrationSourceCode(driver, sources))
+	runMigrationBinary(dir, runner, scratchConn, runnerArgs("up", 0, "", runOptions{Target: t.Unix()})...)
+	statements := SchemaDumper(driver, db)
+
+	beeLogger.Log.Info("Loading the baseline into the second scratch database")
+	for _, stmt := range statements {
+		if _, err := loaded.Exec(stmt); err != nil {
+			beeLogger.Log.Fatalf("Could not load the baseline: %s\n%s", err, stmt)
+		}
+	}
+	missing, extra := schemaDifference(SchemaDumper(driver, db), SchemaDumper(driver, loaded))
+	m, e := schemaDifference(SchemaCatalog(driver, db), SchemaCatalog(driver, loaded))
+	missing, extra = append(missing, m...), append(extra, e...)
+	if len(missing) > 0 || len(extra) > 0 {
+		for _, line := range missing {
+			beeLogger.Log.Errorf("The baseline misses: %s", line)
+		}
+		for _, line := range extra {
+			beeLogger.Log.Errorf("The baseline adds: %s", line)
+		}
+		beeLogger.Log.Hint("No file was written or moved. Squash fewer migrations, so that the ones creating what is missed are kept.")
+		beeLogger.Log.Fatal("The baseline does not recreate the schema of the squashed migrations")
+	}
+	if driver != "sqlite" {
+		beeLogger.Log.Info("The scratch databases may be dropped now")
+	}
+	return statements
+}
+
+// openScratch opens an empty scratch database, a temporary one for SQLite when conn is
+// empty, and returns its connection string. The returned function closes it.
+func openScratch(driver, conn, flag string) (*sql.DB, string, func()) {
+	temporary := conn == ""
+	if temporary {
+		if driver != "sqlite" {
+			beeLogger.Log.Hintf("Give an empty database, i.e. -%s=\"root:@tcp(127.0.0.1:3306)/%s\"", flag, strings.Replace(flag, "-", "_", -1))
+			beeLogger.Log.Fatalf("Scratch database -%s is missing", flag)
+		}
+		f, err := ioutil.TempFile("", "bee-squash-*.db")
+		if err != nil {
+			beeLogger.Log.Fatalf("Could not create scratch database: %s", err)
+		}
+		f.Close()
+		conn = f.Name()
+	}
+	db, err := sql.Open(sqlDriverName(driver), conn)
+	if err != nil {
+		beeLogger.Log.Fatalf("Could not connect to scratch database using '%s': %s", conn, err)
+	}
+	if getDialect(driver).TableExists(db, "migrations") || len(SchemaCatalog(driver, db)) > 0 {
+		beeLogger.Log.Fatalf("The -%s database must be empty", flag)
+	}
+	return db, conn, func() {
+		db.Close()
+		if temporary {
+			os.Remove(conn)
+		}
+	}
+}
+
+// schemaDifference returns the lines of want which got lacks, and the ones got adds
+func schemaDifference(want, got []string) (missing, extra []string) {
+	count := make(map[string]int)
+	for _, line := range got {
+		count[line]++
+	}
+	for _, line := range want {
+		if count[line] > 0 {
+			count[line]--
+		} else {
+			missing = append(missing, line)
+		}
+	}
+	for _, line := range got {
+		if count[line] > 0 {
+			count[line]--
+			extra = append(extra, line)
+		}
+	}
+	return
+}
+
+// squashedFile returns the path a squashed migration is moved to
+func squashedFile(dir string, s migrationSource) string {
+	return filepath.Join(dir, squashedDir, filepath.Base(s.File))
+}
+
+// moveSquashed moves the squashed migrations to the squashed directory, where a build
+// constraint excludes them from the build. They are kept for reference until the
+// databases applying them are gone.
+func moveSquashed(dir string, squashed []migrationSource) {
+	if err := os.MkdirAll(filepath.Join(dir, squashedDir), 0755); err != nil {
+		beeLogger.Log.Fatalf("Could not create directory: %s", err)
+	}
+	for _, s := range squashed {
+		content, err := ioutil.ReadFile(s.File)
+		if err != nil {
+			beeLogger.Log.Fatalf("Could not read migration file '%s': %s", s.File, err)
+		}
+		if err := ioutil.WriteFile(squashedFile(dir, s), append([]byte(ignoreConstraint), content...), 0666); err != nil {
+			beeLogger.Log.Fatalf("Could not move migration file: %s", err)
+		}
+		if err := os.Remove(s.File); err != nil {
+			beeLogger.Log.Fatalf("Could not move migration file: %s", err)
+		}
+	}
+}
+
+// writeBaseline writes the baseline migration creating the schema. Its comments name the
+// migrations it replaces, so that databases which applied them record it as applied.
+func writeBaseline(file, name, created, driver string, squashed, statements []string) {
+	var list, up, down bytes.Buffer
+	for _, s := range squashed {
+		fmt.Fprintf(&list, "// bee:squashed %s\n", s)
+	}
+	for _, stmt := range statements {
+		fmt.Fprintf(&up, "\tm.SQL(%s)\n", strconv.Quote(stmt))
+	}
+	for i := len(statements) - 1; i >= 0; i-- {
+		if m := createTableRegex.FindStringSubmatch(statements[i]); m != nil {
+			drop := "DROP TABLE " + m[1]
+			if driver == "postgres" {
+				drop += " CASCADE"
+			}
+			fmt.Fprintf(&down, "\tm.SQL(%s)\n", strconv.Quote(drop))
+		}
+	}
+	content := strings.Replace(BaselineTPL, "{{StructName}}", name, -1)
+	content = strings.Replace(content, "{{CurrTime}}", created, -1)
+	content = strings.Replace(content, "{{Squashed}}", strings.TrimSuffix(list.String(), "\n"), -1)
+	content = strings.Replace(content, "{{UpSQL}}", strings.TrimSuffix(up.String(), "\n"), -1)
+	content = strings.Replace(content, "{{DownSQL}}", strings.TrimSuffix(down.String(), "\n"), -1)
+	formatted, err := format.Source([]byte(content))
+	if err != nil {
+		beeLogger.Log.Fatalf("Could not format baseline: %s", err)
+	}
+	if err := ioutil.WriteFile(file, formatted, 0666); err != nil {
+		beeLogger.Log.Fatalf("Could not write baseline: %s", err)
+	}
+}
+
+// recordBaselines records the baselines as applied in a database which applied every
+// migration they squashed. The record of the last of them is renamed, so that the
+// order of the migrations table is kept for rollback.
+func recordBaselines(db *sql.DB, dialect MigrationDialect, sources []migrationSource) {
+	latest := latestRecords(db, dialect)
+	for _, s := range sources {
+		if len(s.Squashed) == 0 || latest[s.Name].Status != "" {
+			continue
+		}
+		switch applied := appliedCount(latest, s.Squashed); {
+		case applied == 0:
+			continue
+		case applied < len(s.Squashed):
+			releaseLock()
+			beeLogger.Log.Hint("Check out the squashed migrations from version control and apply them before this baseline")
+			beeLogger.Log.Fatalf("The database applied %d of the %d migrations squashed into '%s'", applied, len(s.Squashed), s.Name)
+		}
+		last := s.Squashed[len(s.Squashed)-1]
+		dialect.RenameMigration(db, last, s.Name)
+		dialect.SaveChecksum(db, last, "")
+		dialect.SaveChecksum(db, s.Name, sourceChecksum(s.File))
+		beeLogger.Log.Infof("Recorded baseline '%s' as applied", s.Name)
+	}
+}
+
+// BaselineTPL is the migration written by squash
+const BaselineTPL = `package main
+
+import (
+	"github.com/astaxie/beego/migration"
+)
+
+// {{StructName}} creates the schema of the migrations it replaces. Generated by 'bee migrate squash'.
+//
+{{Squashed}}
+type {{StructName}} struct {
+	migration.Migration
+}
+
+// DO NOT MODIFY
+func init() {
+	m := &{{StructName}}{}
+	m.Created = "{{CurrTime}}"
+	migration.Register("{{StructName}}", m)
+}
+
+// Run the migrations
+func (m *{{StructName}}) Up() {
+{{UpSQL}}
+}
+
+// Reverse the migrations
+func (m *{{StructName}}) Down() {
+{{DownSQL}}
+}
+`
@@ -0,0 +1,156 @@
+// Copyright 2013 bee authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package migrate
+
+import (
+	"go/build"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWriteBaseline(t *testing.T) {
+	statements := []string{
+		"CREATE TABLE users (id integer PRIMARY KEY, name varchar(255))",
+		"CREATE INDEX users_name ON users (name)",
+		"CREATE TABLE posts (id integer PRIMARY KEY, title text)",
+	}
+	tests := []struct {
+		driver   string
+		wantDown []string
+	}{
+		{"sqlite", []string{`m.SQL("DROP TABLE posts")`, `m.SQL("DROP TABLE users")`}},
+		{"postgres", []string{`m.SQL("DROP TABLE posts CASCADE")`, `m.SQL("DROP TABLE users CASCADE")`}},
+	}
+	for _, tt := range tests {
+		dir, cleanup := tempDir(t)
+		file := filepath.Join(dir, "20200102_120000_baseline.go")
+		squashed := []string{"CreateUsers_20200101_120000", "CreatePosts_20200102_120000"}
+		writeBaseline(file, "Baseline_20200102_120000", "20200102_120000", tt.driver, squashed, statements)
+
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkProgram(t, string(content))
+		down := string(content[strings.Index(string(content), "Down()"):])
+		first, second := strings.Index(down, tt.wantDown[0]), strings.Index(down, tt.wantDown[1])
+		if first < 0 || second < first {
+			t.Errorf("%s: Down does not drop the tables in reverse order:\n%s", tt.driver, down)
+		}
+
+		sources := readMigrationSources(dir, "")
+		want := []migrationSource{{
+			Name:     "Baseline_20200102_120000",
+			Type:     "Baseline_20200102_120000",
+			Created:  "20200102_120000",
+			File:     file,
+			Squashed: squashed,
+		}}
+		if !reflect.DeepEqual(sources, want) {
+			t.Errorf("%s: read %+v, want %+v", tt.driver, sources, want)
+		}
+		cleanup()
+	}
+}
+
+func TestRecordBaselines(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	db := sqliteDB(t, dir)
+	defer db.Close()
+	dialect := getDialect("sqlite")
+	checkForSchemaUpdateTable(db, dialect)
+
+	baseline := migrationSource{
+		Name:     "Baseline_20200102_120000",
+		File:     writeFile(t, dir, "baseline.go", "package main\n"),
+		Squashed: []string{"CreateUsers_20200101_120000", "CreatePosts_20200102_120000"},
+	}
+	later := "CreateTags_20200103_120000"
+	for _, name := range append(baseline.Squashed, later) {
+		dialect.InsertMigration(db, name, "update", "")
+	}
+
+	recordBaselines(db, dialect, []migrationSource{baseline})
+	var names []string
+	for _, r := range dialect.Migrations(db) {
+		names = append(names, r.Name)
+	}
+	want := []string{"CreateUsers_20200101_120000", baseline.Name, later}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("migrations %v, want %v", names, want)
+	}
+	if sums := dialect.Checksums(db); sums[baseline.Name] != sourceChecksum(baseline.File) {
+		t.Errorf("checksum of the baseline not recorded: %v", sums)
+	}
+
+	// a second run finds the baseline recorded already
+	recordBaselines(db, dialect, []migrationSource{baseline})
+	if n := len(dialect.Migrations(db)); n != 3 {
+		t.Errorf("%d migrations recorded after a second run, want 3", n)
+	}
+}
+
+func TestMoveSquashed(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	migration := "package main\n\nfunc init() {}\n"
+	squashed := []migrationSource{
+		{Name: "CreateUsers_20200101_120000", File: writeFile(t, dir, "20200101_120000_create_users.go", migration)},
+		{Name: "CreatePosts_20200102_120000", File: writeFile(t, dir, "20200102_120000_create_posts.go", migration)},
+	}
+	kept := writeFile(t, dir, "20200103_120000_create_tags.go", migration)
+
+	moveSquashed(dir, squashed)
+	for _, s := range squashed {
+		if _, err := os.Stat(s.File); !os.IsNotExist(err) {
+			t.Errorf("%s is left in place", s.File)
+		}
+		content, err := ioutil.ReadFile(squashedFile(dir, s))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != ignoreConstraint+migration {
+			t.Errorf("moved %s:\n%s", s.Name, content)
+		}
+	}
+	// the build and the migration runs only see the migrations left
+	pkg, err := build.ImportDir(filepath.Join(dir, squashedDir), 0)
+	if _, ok := err.(*build.NoGoError); !ok {
+		t.Errorf("squashed directory is built: %+v, %v", pkg, err)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*.go")); !reflect.DeepEqual(files, []string{kept}) {
+		t.Errorf("migrations %v, want only %s", files, kept)
+	}
+}
+
+func TestSchemaDifference(t *testing.T) {
+	want := []string{"column users id", "column users name", "index users name", "index users name"}
+	got := []string{"column users id", "index users name", "object users_view view"}
+	missing, extra := schemaDifference(want, got)
+	if wantMissing := []string{"column users name", "index users name"}; !reflect.DeepEqual(missing, wantMissing) {
+		t.Errorf("missing %q, want %q", missing, wantMissing)
+	}
+	if wantExtra := []string{"object users_view view"}; !reflect.DeepEqual(extra, wantExtra) {
+		t.Errorf("extra %q, want %q", extra, wantExtra)
+	}
+	if missing, extra := schemaDifference(want, want); missing != nil || extra != nil {
+		t.Errorf("same schema differs: %q, %q", missing, extra)
+	}
+}
@@ -75,9 +75,15 @@ type bale struct {
 
 // database holds the database connection information
 type database struct {
-	Driver string
-	Conn   string
-	Dir    string
+	Driver      string
+	Conn        string
+	Dir         string
+	LockTimeout string `json:"lock_timeout" yaml:"lock_timeout"`
+	LockNoWait  bool   `json:"lock_nowait" yaml:"lock_nowait"`
+	// Environments holds settings overriding the ones above for a runmode
+	Environments map[string]dbEnvironment `json:"environments" yaml:"environments"`
+	// AppConf reads the connection string from conf/app.conf when none is configured
+	AppConf bool `json:"app_conf" yaml:"app_conf"`
 }
 
 // LoadConfig loads the bee tool configuration.
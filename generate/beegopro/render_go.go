@@ -122,6 +122,19 @@ func (c *RenderGo) write(filename string, buf string) (err error) {
 	name := path.Base(filename)
 
 	if utils.IsExist(filename) {
+		old, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return errors.New("write read file " + err.Error())
+		}
+		var orphans []string
+		buf, orphans, err = utils.KeepCustomRegions(buf, string(old))
+		if err != nil {
+			return errors.New("write custom regions " + err.Error())
+		}
+		for _, name := range orphans {
+			beeLogger.Log.Warnf("Custom region '%s' has no place in the new '%s': appended it at the end of the file", name, filename)
+		}
+
 		bakName := fmt.Sprintf("%s/%s.%s.bak", filePathBak, name, time.Now().Format("2006.01.02.15.04.05"))
 		beeLogger.Log.Infof("bak file '%s'", bakName)
 		if err := os.Rename(filename, bakName); err != nil {
@@ -22,3 +22,8 @@ var Level utils.DocValue
 var Tables utils.DocValue
 var Fields utils.DocValue
 var DDL utils.DocValue
+var Diff bool
+var SeedType utils.DocValue
+var Runmodes utils.DocValue
+var Env utils.DocValue
+var Lang utils.DocValue
@@ -17,10 +17,13 @@ package generate
 import (
 	"database/sql"
 	"fmt"
+	"go/token"
+	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 
 	beeLogger "github.com/beego/bee/logger"
@@ -28,6 +31,7 @@ import (
 	"github.com/beego/bee/utils"
 	_ "github.com/go-sql-driver/mysql"
 	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
 )
 
 const (
@@ -52,10 +56,15 @@ type MysqlDB struct {
 type PostgresDB struct {
 }
 
+// SQLiteDB is the SQLite version of DbTransformer
+type SQLiteDB struct {
+}
+
 // dbDriver maps a DBMS name to its version of DbTransformer
 var dbDriver = map[string]DbTransformer{
 	"mysql":    &MysqlDB{},
 	"postgres": &PostgresDB{},
+	"sqlite":   &SQLiteDB{},
 }
 
 type MvcPath struct {
@@ -102,6 +111,7 @@ var typeMappingMysql = map[string]string{
 	"binary":             "string", // binary
 	"varbinary":          "string",
 	"year":               "int16",
+	"json":               "string", // json
 }
 
 // typeMappingPostgres maps SQL data type to corresponding Go data type
@@ -122,6 +132,8 @@ var typeMappingPostgres = map[string]string{
 	"timestamp":                   "time.Time",
 	"timestamp without time zone": "time.Time",
 	"timestamp with time zone":    "time.Time",
+	"time without time zone":      "time.Time",
+	"time with time zone":         "time.Time",
 	"interval":                    "string",  // time interval, string for now
 	"real":                        "float32", // float & decimal
 	"double precision":            "float64",
@@ -138,21 +150,63 @@ var typeMappingPostgres = map[string]string{
 	"inet":                        "string",  // ip address
 }
 
+// typeMappingSQLite maps SQLite declared types to corresponding Go data type.
+// Types missing from it are resolved by SQLite's type affinity rules.
+var typeMappingSQLite = map[string]string{
+	"integer":           "int", // int
+	"int":               "int",
+	"tinyint":           "int8",
+	"smallint":          "int16",
+	"mediumint":         "int32",
+	"bigint":            "int64",
+	"int2":              "int16",
+	"int8":              "int64",
+	"unsigned big int":  "uint64",
+	"boolean":           "bool", // bool
+	"bool":              "bool",
+	"character":         "string", // string & text
+	"varchar":           "string",
+	"varying character": "string",
+	"nchar":             "string",
+	"native character":  "string",
+	"nvarchar":          "string",
+	"char":              "string",
+	"text":              "string",
+	"clob":              "string",
+	"blob":              "string",    // blob
+	"":                  "string",    // no declared type
+	"date":              "time.Time", // time
+	"datetime":          "time.Time",
+	"timestamp":         "time.Time",
+	"time":              "time.Time",
+	"real":              "float64", // float & decimal
+	"double":            "float64",
+	"double precision":  "float64",
+	"float":             "float64",
+	"numeric":           "float64",
+	"decimal":           "float64",
+}
+
 // Table represent a table in a database
 type Table struct {
 	Name          string
 	Pk            string
+	Pks           []string // columns of the primary key, in key order
 	Uk            []string
 	Fk            map[string]*ForeignKey
 	Columns       []*Column
 	ImportTimePkg bool
+	JoinTable     bool      // a join table without pk, used by the orm through rel_table
+	Model         string    // name of the model struct, for tables parsed from models
+	Relations     []*Column // m2m and reverse fields of the parsed models, which have no column
 }
 
 // Column reprsents a column for a table
 type Column struct {
-	Name string
-	Type string
-	Tag  *OrmTag
+	Name    string
+	Type    string
+	Tag     *OrmTag
+	KeyType string // for relation columns, the type of the primary key they hold, when known
 }
 
 // ForeignKey represents a foreign key column for a table
@@ -183,6 +237,8 @@ type OrmTag struct {
 	RelFk       bool
 	ReverseMany bool
 	RelM2M      bool
+	RelTable    string
+	RelThrough  string
 	Comment     string //column comment
 }
 
@@ -196,6 +252,12 @@ func (tb *Table) String() string {
 	return rv
 }
 
+// customString returns the source code string for the Table struct, with a
+// custom region for the fields the generated models keep on regeneration
+func (tb *Table) customString() string {
+	return strings.TrimSuffix(tb.String(), "}\n") + "\n// bee:custom begin fields\n// bee:custom end\n}\n"
+}
+
 // String returns the source code string of a field in Table struct
 // It maps to a column in database table. e.g. Id int `orm:"column(id);auto"`
 func (col *Column) String() string {
@@ -244,12 +306,21 @@ func (tag *OrmTag) String() string {
 	if tag.RelM2M {
 		ormOptions = append(ormOptions, "rel(m2m)")
 	}
+	if tag.RelTable != "" {
+		ormOptions = append(ormOptions, fmt.Sprintf("rel_table(%s)", tag.RelTable))
+	}
+	if tag.RelThrough != "" {
+		ormOptions = append(ormOptions, fmt.Sprintf("rel_through(%s)", tag.RelThrough))
+	}
 	if tag.Pk {
 		ormOptions = append(ormOptions, "pk")
 	}
 	if tag.Unique {
 		ormOptions = append(ormOptions, "unique")
 	}
+	if tag.Index {
+		ormOptions = append(ormOptions, "index")
+	}
 	if tag.Default != "" {
 		ormOptions = append(ormOptions, fmt.Sprintf("default(%s)", tag.Default))
 	}
@@ -286,44 +357,53 @@ func GenerateAppcode(driver, connStr, level, tables, currpath string) {
 	case "mysql":
 	case "postgres":
 	case "sqlite":
-		beeLogger.Log.Fatal("Generating app code from SQLite database is not supported yet.")
 	default:
 		beeLogger.Log.Fatal("Unknown database driver. Must be either \"mysql\", \"postgres\" or \"sqlite\"")
 	}
+	if DDL != "" {
+		genFromDDL(driver, DDL.String(), mode, selectedTables, currpath)
+		return
+	}
 	gen(driver, connStr, mode, selectedTables, currpath)
 }
 
 // Generate takes table, column and foreign key information from database connection
 // and generate corresponding golang source files
 func gen(dbms, connStr string, mode byte, selectedTableNames map[string]bool, apppath string) {
-	db, err := sql.Open(dbms, connStr)
+	db, err := sql.Open(sqlDriverName(dbms), connStr)
 	if err != nil {
 		beeLogger.Log.Fatalf("Could not connect to '%s' database using '%s': %s", dbms, connStr, err)
 	}
 	defer db.Close()
 	if trans, ok := dbDriver[dbms]; ok {
 		beeLogger.Log.Info("Analyzing database tables...")
-		var tableNames []string
-		if len(selectedTableNames) != 0 {
-			for tableName := range selectedTableNames {
-				tableNames = append(tableNames, tableName)
-			}
-		} else {
-			tableNames = trans.GetTableNames(db)
-		}
-		tables := getTableObjects(tableNames, db, trans)
-		mvcPath := new(MvcPath)
-		mvcPath.ModelPath = path.Join(apppath, "models")
-		mvcPath.ControllerPath = path.Join(apppath, "controllers")
-		mvcPath.RouterPath = path.Join(apppath, "routers")
-		createPaths(mode, mvcPath)
-		pkgPath := getPackagePath(apppath)
-		writeSourceFiles(pkgPath, tables, mode, mvcPath)
+		genTables(db, trans, mode, selectedTableNames, apppath)
 	} else {
 		beeLogger.Log.Fatalf("Generating app code from '%s' database is not supported yet.", dbms)
 	}
 }
 
+// genTables generates the app code of the tables a DbTransformer reads
+func genTables(db *sql.DB, trans DbTransformer, mode byte, selectedTableNames map[string]bool, apppath string) {
+	var tableNames []string
+	if len(selectedTableNames) != 0 {
+		for tableName := range selectedTableNames {
+			tableNames = append(tableNames, tableName)
+		}
+	} else {
+		tableNames = trans.GetTableNames(db)
+	}
+	pkgPath := getPackagePath(apppath)
+	tables := getTableObjects(tableNames, db, trans)
+	addRelations(tables, pkgPath)
+	mvcPath := new(MvcPath)
+	mvcPath.ModelPath = path.Join(apppath, "models")
+	mvcPath.ControllerPath = path.Join(apppath, "controllers")
+	mvcPath.RouterPath = path.Join(apppath, "routers")
+	createPaths(mode, mvcPath)
+	writeSourceFiles(pkgPath, tables, mode, mvcPath)
+}
+
 // GetTableNames returns a slice of table names in the current database
 func (*MysqlDB) GetTableNames(db *sql.DB) (tables []string) {
 	rows, err := db.Query("SHOW TABLES")
@@ -360,9 +440,149 @@ func getTableObjects(tableNames []string, db *sql.DB, dbTransformer DbTransforme
 	for _, tb := range tables {
 		dbTransformer.GetColumns(db, tb, blackList)
 	}
+	for _, tb := range tables {
+		for _, col := range tb.Columns {
+			if fk, ok := tb.Fk[col.Tag.Column]; ok && blackList[fk.RefTable] && !blackList[tb.Name] {
+				beeLogger.Log.Warnf("Column '%s.%s' references '%s', which has a composite primary key the orm cannot relate to: generated a plain field instead",
+					tb.Name, col.Tag.Column, fk.RefTable)
+			}
+		}
+	}
 	return
 }
 
+// addPkColumn records a primary key column at its position in the key.
+// A table with a composite key is put into the blacklist
+func addPkColumn(table *Table, column string, pos int, blackList map[string]bool) {
+	if pos < 1 {
+		return
+	}
+	for len(table.Pks) < pos {
+		table.Pks = append(table.Pks, "")
+	}
+	table.Pks[pos-1] = column
+	if len(table.Pks) == 1 {
+		table.Pk = column
+	} else {
+		table.Pk = ""
+		blackList[table.Name] = true
+	}
+}
+
+// addRelations adds the reverse side of every foreign key to the referenced
+// table, and turns join tables into m2m fields on the two tables they link
+func addRelations(tables []*Table, pkgPath string) {
+	byName := make(map[string]*Table)
+	for _, tb := range tables {
+		byName[tb.Name] = tb
+	}
+	for _, tb := range tables {
+		if left, right, ok := joinColumns(tb); ok && addM2M(tb, left, right, byName, pkgPath) {
+			continue
+		}
+		if tb.Pk == "" {
+			continue
+		}
+		referenced := make(map[string]bool)
+		for _, col := range tb.Columns {
+			if !col.Tag.RelFk {
+				continue
+			}
+			ref, ok := byName[tb.Fk[col.Tag.Column].RefTable]
+			if !ok || ref.Pk == "" {
+				continue
+			}
+			// the orm binds a reverse field to the first foreign key only
+			if referenced[ref.Name] {
+				beeLogger.Log.Warnf("Table '%s' references '%s' more than once, only the first foreign key gets a reverse field", tb.Name, ref.Name)
+				continue
+			}
+			referenced[ref.Name] = true
+			addRelField(ref, &Column{
+				Name: relFieldName(tb.Name),
+				Type: "[]*" + utils.CamelCase(tb.Name),
+				Tag:  &OrmTag{ReverseMany: true},
+			})
+		}
+	}
+}
+
+// joinColumns returns the two foreign key columns of a join table, that is
+// a table made of nothing but them and an optional primary key
+func joinColumns(tb *Table) (left, right *Column, ok bool) {
+	var fks []*Column
+	for _, col := range tb.Columns {
+		if _, ok := tb.Fk[col.Tag.Column]; ok {
+			fks = append(fks, col)
+		} else if tb.Pk == "" || col.Tag.Column != tb.Pk {
+			return nil, nil, false
+		}
+	}
+	if len(fks) != 2 {
+		return nil, nil, false
+	}
+	return fks[0], fks[1], true
+}
+
+// addM2M adds the rel(m2m) field to the left table of a join table and the
+// matching reverse(many) field to the right one
+func addM2M(tb *Table, left, right *Column, byName map[string]*Table, pkgPath string) bool {
+	lfk, rfk := tb.Fk[left.Tag.Column], tb.Fk[right.Tag.Column]
+	lt, lok := byName[lfk.RefTable]
+	rt, rok := byName[rfk.RefTable]
+	if !lok || !rok || lt.Pk == "" || rt.Pk == "" || lfk.RefColumn != lt.Pk || rfk.RefColumn != rt.Pk {
+		return false
+	}
+	if lt == rt {
+		beeLogger.Log.Warnf("Join table '%s' links '%s' to itself, which is not supported yet", tb.Name, lt.Name)
+		return false
+	}
+	var relTable, relThrough string
+	if tb.Pk != "" {
+		relThrough = pkgPath + "/models." + utils.CamelCase(tb.Name)
+	} else if lfk.Name == lt.Name+"_id" && rfk.Name == rt.Name+"_id" {
+		relTable = tb.Name
+		tb.JoinTable = true
+		beeLogger.Log.Hintf("The orm needs an 'id' column in join table '%s' to Remove or Clear relations", tb.Name)
+	} else {
+		beeLogger.Log.Warnf("Join table '%s' needs the columns '%s_id' and '%s_id' or a primary key to be used by the orm",
+			tb.Name, lt.Name, rt.Name)
+		return false
+	}
+	addRelField(lt, &Column{
+		Name: relFieldName(rt.Name),
+		Type: "[]*" + utils.CamelCase(rt.Name),
+		Tag:  &OrmTag{RelM2M: true, RelTable: relTable, RelThrough: relThrough},
+	})
+	addRelField(rt, &Column{
+		Name: relFieldName(lt.Name),
+		Type: "[]*" + utils.CamelCase(lt.Name),
+		Tag:  &OrmTag{ReverseMany: true, RelTable: relTable, RelThrough: relThrough},
+	})
+	return true
+}
+
+// addRelField appends a relation field to a table unless the name is taken
+func addRelField(tb *Table, col *Column) {
+	for _, c := range tb.Columns {
+		if c.Name == col.Name {
+			beeLogger.Log.Warnf("Table '%s' already has a field '%s', skipped the relation to '%s'",
+				tb.Name, col.Name, strings.TrimPrefix(col.Type, "[]*"))
+			return
+		}
+	}
+	tb.Columns = append(tb.Columns, col)
+}
+
+// relFieldName returns the field name for a slice of related rows
+func relFieldName(table string) string {
+	name := utils.CamelCase(table)
+	if strings.HasSuffix(name, "s") {
+		return name
+	}
+	return plural(name)
+}
+
 // GetConstraints gets primary key, unique key and foreign keys of a table from
 // information_schema and fill in the Table struct
 func (*MysqlDB) GetConstraints(db *sql.DB, table *Table, blackList map[string]bool) {
@@ -388,14 +608,8 @@ func (*MysqlDB) GetConstraints(db *sql.DB, table *Table, blackList map[string]bo
 			string(constraintTypeBytes), string(columnNameBytes), string(refTableSchemaBytes),
 			string(refTableNameBytes), string(refColumnNameBytes), string(refOrdinalPosBytes)
 		if constraintType == "PRIMARY KEY" {
-			if refOrdinalPos == "1" {
-				table.Pk = columnName
-			} else {
-				table.Pk = ""
-				// Add table to blacklist so that other struct will not reference it, because we are not
-				// registering blacklisted tables
-				blackList[table.Name] = true
-			}
+			pos, _ := strconv.Atoi(refOrdinalPos)
+			addPkColumn(table, columnName, pos, blackList)
 		} else if constraintType == "UNIQUE" {
 			table.Uk = append(table.Uk, columnName)
 		} else if constraintType == "FOREIGN KEY" {
@@ -419,7 +633,9 @@ func (mysqlDB *MysqlDB) GetColumns(db *sql.DB, table *Table, blackList map[strin
 		FROM
 			information_schema.columns
 		WHERE
-			table_schema = database() AND table_name = ?`,
+			table_schema = database() AND table_name = ?
+		ORDER BY
+			ordinal_position`,
 		table.Name)
 	if err != nil {
 		beeLogger.Log.Fatalf("Could not query the database: %s", err)
@@ -435,83 +651,126 @@ func (mysqlDB *MysqlDB) GetColumns(db *sql.DB, table *Table, blackList map[strin
 		colName, dataType, columnType, isNullable, columnDefault, extra, columnComment :=
 			string(colNameBytes), string(dataTypeBytes), string(columnTypeBytes), string(isNullableBytes), string(columnDefaultBytes), string(extraBytes), string(columnCommentBytes)
 
-		// create a column
-		col := new(Column)
-		col.Name = utils.CamelCase(colName)
-		col.Type, err = mysqlDB.GetGoDataType(dataType)
-		if err != nil {
-			beeLogger.Log.Fatalf("%s", err)
+		c := &columnInfo{
+			name:     colName,
+			dataType: dataType,
+			args:     columnArgs(columnType),
+			unsigned: strings.Contains(columnType, "unsigned"),
+			notNull:  isNullable == "NO",
+			auto:     strings.Contains(extra, "auto_increment"),
+			dflt:     columnDefault,
+			comment:  columnComment,
 		}
+		if i := strings.Index(extra, "on update "); i >= 0 {
+			c.onUpdate = extra[i+len("on update "):]
+		}
+		table.Columns = append(table.Columns, newColumn("mysql", table, c, blackList))
+	}
+}
 
-		// Tag info
-		tag := new(OrmTag)
-		tag.Column = colName
-		tag.Comment = columnComment
-		if table.Pk == colName {
-			col.Name = "Id"
-			col.Type = "int"
-			if extra == "auto_increment" {
-				tag.Auto = true
-			} else {
-				tag.Pk = true
-			}
+// columnInfo is the definition of a column, as read from information_schema or a schema file
+type columnInfo struct {
+	name     string
+	dataType string   // the data_type information_schema reports for the column
+	args     []string // e.g. the size of varchar(255)
+	unsigned bool
+	notNull  bool
+	auto     bool
+	dflt     string
+	onUpdate string
+	comment  string
+}
+
+// newColumn builds the struct field of a column and its orm tag
+func newColumn(dbms string, table *Table, c *columnInfo, blackList map[string]bool) *Column {
+	col := new(Column)
+	col.Name = utils.CamelCase(c.name)
+	var err error
+	col.Type, err = dbDriver[dbms].GetGoDataType(c.dataType)
+	if err != nil {
+		beeLogger.Log.Fatalf("Column '%s.%s': %s", table.Name, c.name, err)
+	}
+
+	// Tag info
+	tag := new(OrmTag)
+	tag.Column = c.name
+	tag.Comment = c.comment
+	if table.Pk == c.name {
+		col.Name = "Id"
+		col.Type = "int"
+		if c.auto {
+			tag.Auto = true
 		} else {
-			fkCol, isFk := table.Fk[colName]
-			isBl := false
-			if isFk {
-				_, isBl = blackList[fkCol.RefTable]
+			tag.Pk = true
+		}
+	} else {
+		fkCol, isFk := table.Fk[c.name]
+		isBl := false
+		if isFk {
+			// blacklisted tables are not registered, so they can neither
+			// be referenced nor reference other tables
+			isBl = blackList[fkCol.RefTable] || blackList[table.Name]
+		}
+		// check if the current column is a foreign key
+		if isFk && !isBl {
+			tag.RelFk = true
+			tag.Null = !c.notNull
+			col.KeyType = col.Type
+			col.Type = "*" + utils.CamelCase(fkCol.RefTable)
+		} else {
+			// if the name of column is Id, and it's not primary key
+			if c.name == "id" {
+				col.Name = "Id_RENAME"
 			}
-			// check if the current column is a foreign key
-			if isFk && !isBl {
-				tag.RelFk = true
-				refStructName := fkCol.RefTable
-				col.Name = utils.CamelCase(colName)
-				col.Type = "*" + utils.CamelCase(refStructName)
-			} else {
-				// if the name of column is Id, and it's not primary key
-				if colName == "id" {
-					col.Name = "Id_RENAME"
-				}
-				if isNullable == "YES" {
-					tag.Null = true
-				}
-				if isSQLSignedIntType(dataType) {
-					sign := extractIntSignness(columnType)
-					if sign == "unsigned" && extra != "auto_increment" {
-						col.Type, err = mysqlDB.GetGoDataType(dataType + " " + sign)
-						if err != nil {
-							beeLogger.Log.Fatalf("%s", err)
-						}
-					}
-				}
-				if isSQLStringType(dataType) {
-					tag.Size = extractColSize(columnType)
-				}
-				if isSQLTemporalType(dataType) {
-					tag.Type = dataType
-					//check auto_now, auto_now_add
-					if columnDefault == "CURRENT_TIMESTAMP" && extra == "on update CURRENT_TIMESTAMP" {
-						tag.AutoNow = true
-					} else if columnDefault == "CURRENT_TIMESTAMP" {
-						tag.AutoNowAdd = true
-					}
-					// need to import time package
-					table.ImportTimePkg = true
-				}
-				if isSQLDecimal(dataType) {
-					tag.Digits, tag.Decimals = extractDecimal(columnType)
-				}
-				if isSQLBinaryType(dataType) {
-					tag.Size = extractColSize(columnType)
+			tag.Null = !c.notNull
+			if c.unsigned && !c.auto && isSQLSignedIntType(c.dataType) {
+				col.Type, err = dbDriver[dbms].GetGoDataType(c.dataType + " unsigned")
+				if err != nil {
+					beeLogger.Log.Fatalf("%s", err)
 				}
-				if isSQLBitType(dataType) {
-					tag.Size = extractColSize(columnType)
+			}
+			if isSQLStringType(c.dataType) && len(c.args) > 0 {
+				tag.Size = c.args[0]
+			}
+			if isSQLTemporalType(c.dataType) || strings.HasPrefix(c.dataType, "time") {
+				tag.Type = c.dataType
+				//check auto_now, auto_now_add
+				if isCurrentTimestamp(c.dflt) && isCurrentTimestamp(c.onUpdate) {
+					tag.AutoNow = true
+				} else if isCurrentTimestamp(c.dflt) {
+					tag.AutoNowAdd = true
 				}
+				// need to import time package
+				table.ImportTimePkg = true
+			}
+			if isSQLDecimal(c.dataType) && len(c.args) == 2 {
+				tag.Digits, tag.Decimals = c.args[0], c.args[1]
+			}
+			if (isSQLBinaryType(c.dataType) || isSQLBitType(c.dataType)) && len(c.args) > 0 {
+				tag.Size = c.args[0]
+			}
+			if dbms == "postgres" && isSQLStrangeType(c.dataType) {
+				tag.Type = c.dataType
 			}
 		}
-		col.Tag = tag
-		table.Columns = append(table.Columns, col)
 	}
+	col.Tag = tag
+	return col
+}
+
+// columnArgs returns the arguments of a column type, e.g. 10 and 2 for decimal(10,2)
+func columnArgs(columnType string) []string {
+	open, end := strings.Index(columnType, "("), strings.Index(columnType, ")")
+	if open < 0 || end < open {
+		return nil
+	}
+	return strings.Split(columnType[open+1:end], ",")
+}
+
+// isCurrentTimestamp reports whether a default value is the current time
+func isCurrentTimestamp(value string) bool {
+	value = strings.ToUpper(strings.Replace(value, " ", "", -1))
+	return strings.HasPrefix(value, "CURRENT_TIMESTAMP") || value == "NOW()"
 }
 
 // GetGoDataType maps an SQL data type to Golang data type
@@ -579,14 +838,8 @@ func (*PostgresDB) GetConstraints(db *sql.DB, table *Table, blackList map[string
 			string(constraintTypeBytes), string(columnNameBytes), string(refTableSchemaBytes),
 			string(refTableNameBytes), string(refColumnNameBytes), string(refOrdinalPosBytes)
 		if constraintType == "PRIMARY KEY" {
-			if refOrdinalPos == "1" {
-				table.Pk = columnName
-			} else {
-				table.Pk = ""
-				// add table to blacklist so that other struct will not reference it, because we are not
-				// registering blacklisted tables
-				blackList[table.Name] = true
-			}
+			pos, _ := strconv.Atoi(refOrdinalPos)
+			addPkColumn(table, columnName, pos, blackList)
 		} else if constraintType == "UNIQUE" {
 			table.Uk = append(table.Uk, columnName)
 		} else if constraintType == "FOREIGN KEY" {
@@ -615,12 +868,14 @@ func (postgresDB *PostgresDB) GetColumns(db *sql.DB, table *Table, blackList map
 			END AS column_type,
 			is_nullable,
 			column_default,
-			'' AS extra
+			CASE WHEN column_default LIKE 'nextval(%' THEN 'auto_increment' ELSE '' END AS extra
 		FROM
 			information_schema.columns
 		WHERE
 			table_catalog = current_database() AND table_schema NOT IN ('pg_catalog', 'information_schema')
-			 AND table_name = $1`,
+			 AND table_name = $1
+		ORDER BY
+			ordinal_position`,
 		table.Name)
 	if err != nil {
 		beeLogger.Log.Fatalf("Could not query INFORMATION_SCHEMA for column information: %s", err)
@@ -658,13 +913,17 @@ func (postgresDB *PostgresDB) GetColumns(db *sql.DB, table *Table, blackList map
 			fkCol, isFk := table.Fk[colName]
 			isBl := false
 			if isFk {
-				_, isBl = blackList[fkCol.RefTable]
+				// blacklisted tables are not registered, so they can neither
+				// be referenced nor reference other tables
+				isBl = blackList[fkCol.RefTable] || blackList[table.Name]
 			}
 			// check if the current column is a foreign key
 			if isFk && !isBl {
 				tag.RelFk = true
+				tag.Null = isNullable == "YES"
 				refStructName := fkCol.RefTable
 				col.Name = utils.CamelCase(colName)
+				col.KeyType = col.Type
 				col.Type = "*" + utils.CamelCase(refStructName)
 			} else {
 				// if the name of column is Id, and it's not primary key
@@ -677,7 +936,7 @@ func (postgresDB *PostgresDB) GetColumns(db *sql.DB, table *Table, blackList map
 				if isSQLStringType(dataType) {
 					tag.Size = extractColSize(columnType)
 				}
-				if isSQLTemporalType(dataType) || strings.HasPrefix(dataType, "timestamp") {
+				if isSQLTemporalType(dataType) || strings.HasPrefix(dataType, "time") {
 					tag.Type = dataType
 					//check auto_now, auto_now_add
 					if columnDefault == "CURRENT_TIMESTAMP" && extra == "on update CURRENT_TIMESTAMP" {
@@ -712,6 +971,220 @@ func (*PostgresDB) GetGoDataType(sqlType string) (string, error) {
 	return "", fmt.Errorf("data type '%s' not found", sqlType)
 }
 
+// GetTableNames for SQLite
+func (*SQLiteDB) GetTableNames(db *sql.DB) (tables []string) {
+	rows, err := db.Query(`
+		SELECT name FROM sqlite_master
+		WHERE type = 'table' AND name NOT LIKE 'sqlite_%'
+		ORDER BY name`)
+	if err != nil {
+		beeLogger.Log.Fatalf("Could not show tables: %s", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			beeLogger.Log.Fatalf("Could not show tables: %s", err)
+		}
+		tables = append(tables, name)
+	}
+	return
+}
+
+// sqliteColumn is a row of PRAGMA table_info
+type sqliteColumn struct {
+	Name    string
+	Type    string
+	NotNull bool
+	Default string
+	Pk      int // position in the primary key, 0 if not part of it
+}
+
+// sqliteColumns reads the column definitions of a table using PRAGMA table_info
+func sqliteColumns(db *sql.DB, tableName string) (columns []sqliteColumn) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", sqliteQuote(tableName)))
+	if err != nil {
+		beeLogger.Log.Fatalf("Could not query PRAGMA table_info for column information: %s", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, typ string
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &dflt, &pk); err != nil {
+			beeLogger.Log.Fatalf("Could not read PRAGMA table_info for column information: %s", err)
+		}
+		columns = append(columns, sqliteColumn{name, typ, notNull == 1, dflt.String, pk})
+	}
+	return
+}
+
+// GetConstraints for SQLite reads the primary key from PRAGMA table_info,
+// unique keys from PRAGMA index_list and foreign keys from PRAGMA foreign_key_list
+func (*SQLiteDB) GetConstraints(db *sql.DB, table *Table, blackList map[string]bool) {
+	for _, col := range sqliteColumns(db, table.Name) {
+		if col.Pk > 0 {
+			addPkColumn(table, col.Name, col.Pk, blackList)
+		}
+	}
+
+	indexRows, err := db.Query(fmt.Sprintf("PRAGMA index_list(%s)", sqliteQuote(table.Name)))
+	if err != nil {
+		beeLogger.Log.Fatalf("Could not query PRAGMA index_list for UK information: %s", err)
+	}
+	var uniqueIndexes []string
+	for indexRows.Next() {
+		// seq, name, unique, origin, partial
+		var seq, unique int
+		var name, origin string
+		var partial int
+		if err := indexRows.Scan(&seq, &name, &unique, &origin, &partial); err != nil {
+			beeLogger.Log.Fatalf("Could not read PRAGMA index_list for UK information: %s", err)
+		}
+		if unique == 1 && origin != "pk" {
+			uniqueIndexes = append(uniqueIndexes, name)
+		}
+	}
+	indexRows.Close()
+	for _, index := range uniqueIndexes {
+		infoRows, err := db.Query(fmt.Sprintf("PRAGMA index_info(%s)", sqliteQuote(index)))
+		if err != nil {
+			beeLogger.Log.Fatalf("Could not query PRAGMA index_info for UK information: %s", err)
+		}
+		for infoRows.Next() {
+			var seqno, cid int
+			var name sql.NullString
+			if err := infoRows.Scan(&seqno, &cid, &name); err != nil {
+				beeLogger.Log.Fatalf("Could not read PRAGMA index_info for UK information: %s", err)
+			}
+			table.Uk = append(table.Uk, name.String)
+		}
+		infoRows.Close()
+	}
+
+	fkRows, err := db.Query(fmt.Sprintf("PRAGMA foreign_key_list(%s)", sqliteQuote(table.Name)))
+	if err != nil {
+		beeLogger.Log.Fatalf("Could not query PRAGMA foreign_key_list for FK information: %s", err)
+	}
+	defer fkRows.Close()
+	for fkRows.Next() {
+		// id, seq, table, from, to, on_update, on_delete, match
+		var id, seq int
+		var refTable, from, onUpdate, onDelete, match string
+		var to sql.NullString
+		if err := fkRows.Scan(&id, &seq, &refTable, &from, &to, &onUpdate, &onDelete, &match); err != nil {
+			beeLogger.Log.Fatalf("Could not read PRAGMA foreign_key_list for FK information: %s", err)
+		}
+		fk := new(ForeignKey)
+		fk.Name = from
+		fk.RefSchema = "main"
+		fk.RefTable = refTable
+		fk.RefColumn = to.String
+		table.Fk[from] = fk
+	}
+}
+
+// GetColumns for SQLite
+func (sqliteDB *SQLiteDB) GetColumns(db *sql.DB, table *Table, blackList map[string]bool) {
+	for _, c := range sqliteColumns(db, table.Name) {
+		colName := c.Name
+		columnType := strings.ToLower(strings.TrimSpace(c.Type))
+		dataType := columnType
+		if i := strings.Index(dataType, "("); i >= 0 {
+			dataType = strings.TrimSpace(dataType[:i])
+		}
+
+		col := new(Column)
+		col.Name = utils.CamelCase(colName)
+		col.Type, _ = sqliteDB.GetGoDataType(dataType)
+
+		// Tag info
+		tag := new(OrmTag)
+		tag.Column = colName
+		if table.Pk == colName {
+			col.Name = "Id"
+			col.Type = "int"
+			// an INTEGER PRIMARY KEY is an alias of the rowid
+			if dataType == "integer" {
+				tag.Auto = true
+			} else {
+				tag.Pk = true
+			}
+		} else {
+			fkCol, isFk := table.Fk[colName]
+			isBl := false
+			if isFk {
+				// blacklisted tables are not registered, so they can neither
+				// be referenced nor reference other tables
+				isBl = blackList[fkCol.RefTable] || blackList[table.Name]
+			}
+			// check if the current column is a foreign key
+			if isFk && !isBl {
+				tag.RelFk = true
+				tag.Null = !c.NotNull
+				refStructName := fkCol.RefTable
+				col.Name = utils.CamelCase(colName)
+				col.KeyType = col.Type
+				col.Type = "*" + utils.CamelCase(refStructName)
+			} else {
+				// if the name of column is Id, and it's not primary key
+				if colName == "id" {
+					col.Name = "Id_RENAME"
+				}
+				if !c.NotNull {
+					tag.Null = true
+				}
+				if isSQLStringType(dataType) && strings.Contains(columnType, "(") {
+					tag.Size = extractColSize(strings.Replace(columnType, " ", "", -1))
+				}
+				if isSQLTemporalType(dataType) {
+					tag.Type = dataType
+					if strings.ToUpper(c.Default) == "CURRENT_TIMESTAMP" {
+						tag.AutoNowAdd = true
+					}
+					// need to import time package
+					table.ImportTimePkg = true
+				}
+				if isSQLDecimal(dataType) && strings.Contains(columnType, ",") {
+					tag.Digits, tag.Decimals = extractDecimal(strings.Replace(columnType, " ", "", -1))
+				}
+			}
+		}
+		col.Tag = tag
+		table.Columns = append(table.Columns, col)
+	}
+}
+
+// GetGoDataType returns the Go type from the mapped SQLite type,
+// falling back to SQLite's type affinity rules
+func (*SQLiteDB) GetGoDataType(sqlType string) (string, error) {
+	if v, ok := typeMappingSQLite[sqlType]; ok {
+		return v, nil
+	}
+	switch {
+	case strings.Contains(sqlType, "int"):
+		return "int64", nil
+	case strings.Contains(sqlType, "char"), strings.Contains(sqlType, "clob"),
+		strings.Contains(sqlType, "text"), strings.Contains(sqlType, "blob"):
+		return "string", nil
+	}
+	return "float64", nil
+}
+
+// sqliteQuote quotes an identifier for use in a PRAGMA statement
+func sqliteQuote(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+// sqlDriverName returns the name database/sql knows a driver by
+func sqlDriverName(driver string) string {
+	if driver == "sqlite" {
+		return "sqlite3"
+	}
+	return driver
+}
+
 // deleteAndRecreatePaths removes several directories completely
 func createPaths(mode byte, paths *MvcPath) {
 	if (mode & OModel) == OModel {
@@ -733,13 +1206,17 @@ func writeSourceFiles(pkgPath string, tables []*Table, mode byte, paths *MvcPath
 		beeLogger.Log.Info("Creating model files...")
 		writeModelFiles(tables, paths.ModelPath)
 	}
+	var ctrlTables []*Table
+	if (OController|ORouter)&mode != 0 {
+		ctrlTables = controllerTables(tables)
+	}
 	if (OController & mode) == OController {
 		beeLogger.Log.Info("Creating controller files...")
-		writeControllerFiles(tables, paths.ControllerPath, pkgPath)
+		writeControllerFiles(ctrlTables, paths.ControllerPath, pkgPath)
 	}
 	if (ORouter & mode) == ORouter {
 		beeLogger.Log.Info("Creating router files...")
-		writeRouterFile(tables, paths.RouterPath, pkgPath)
+		writeRouterFile(ctrlTables, paths.RouterPath, pkgPath)
 	}
 }
 
@@ -748,38 +1225,26 @@ func writeModelFiles(tables []*Table, mPath string) {
 	w := colors.NewColorWriter(os.Stdout)
 
 	for _, tb := range tables {
-		filename := getFileName(tb.Name)
-		fpath := path.Join(mPath, filename+".go")
-		var f *os.File
-		var err error
-		if utils.IsExist(fpath) {
-			beeLogger.Log.Warnf("'%s' already exists. Do you want to overwrite it? [Yes|No] ", fpath)
-			if utils.AskForConfirmation() {
-				f, err = os.OpenFile(fpath, os.O_RDWR|os.O_TRUNC, 0666)
-				if err != nil {
-					beeLogger.Log.Warnf("%s", err)
-					continue
-				}
-			} else {
-				beeLogger.Log.Warnf("Skipped create file '%s'", fpath)
-				continue
-			}
-		} else {
-			f, err = os.OpenFile(fpath, os.O_CREATE|os.O_RDWR, 0666)
-			if err != nil {
-				beeLogger.Log.Warnf("%s", err)
-				continue
-			}
+		// join tables are managed by the orm through rel_table
+		if tb.JoinTable {
+			continue
 		}
 		var template string
-		if tb.Pk == "" {
+		switch {
+		case len(tb.Pks) > 1:
+			beeLogger.Log.Warnf("Table '%s' has a composite primary key, which the orm cannot register: generated raw SQL functions without relations", tb.Name)
+			template = compositeModel(tb)
+		case tb.Pk == "":
+			beeLogger.Log.Warnf("Table '%s' has no primary key, which the orm requires: generated the struct only", tb.Name)
 			template = StructModelTPL
-		} else {
+		default:
 			template = ModelTPL
 		}
-		fileStr := strings.Replace(template, "{{modelStruct}}", tb.String(), 1)
+		fileStr := strings.Replace(template, "{{modelStruct}}", tb.customString(), 1)
 		fileStr = strings.Replace(fileStr, "{{modelName}}", utils.CamelCase(tb.Name), -1)
 		fileStr = strings.Replace(fileStr, "{{tableName}}", tb.Name, -1)
+		fileStr = strings.Replace(fileStr, "{{packageName}}", "models", -1)
+		fileStr = strings.Replace(fileStr, "{{pkType}}", "int", -1)
 
 		// If table contains time field, import time.Time package
 		timePkg := ""
@@ -790,6 +1255,34 @@ func writeModelFiles(tables []*Table, mPath string) {
 		}
 		fileStr = strings.Replace(fileStr, "{{timePkg}}", timePkg, -1)
 		fileStr = strings.Replace(fileStr, "{{importTimePkg}}", importTimePkg, -1)
+
+		filename := getFileName(tb.Name)
+		fpath := path.Join(mPath, filename+".go")
+		var f *os.File
+		var err error
+		if utils.IsExist(fpath) {
+			beeLogger.Log.Warnf("'%s' already exists. Do you want to overwrite it? [Yes|No] ", fpath)
+			if utils.AskForConfirmation() {
+				var ok bool
+				if fileStr, ok = keepCustomRegions(fpath, fileStr); !ok {
+					continue
+				}
+				f, err = os.OpenFile(fpath, os.O_RDWR|os.O_TRUNC, 0666)
+				if err != nil {
+					beeLogger.Log.Warnf("%s", err)
+					continue
+				}
+			} else {
+				beeLogger.Log.Warnf("Skipped create file '%s'", fpath)
+				continue
+			}
+		} else {
+			f, err = os.OpenFile(fpath, os.O_CREATE|os.O_RDWR, 0666)
+			if err != nil {
+				beeLogger.Log.Warnf("%s", err)
+				continue
+			}
+		}
 		if _, err := f.WriteString(fileStr); err != nil {
 			beeLogger.Log.Fatalf("Could not write model file to '%s': %s", fpath, err)
 		}
@@ -804,9 +1297,13 @@ func writeControllerFiles(tables []*Table, cPath string, pkgPath string) {
 	w := colors.NewColorWriter(os.Stdout)
 
 	for _, tb := range tables {
-		if tb.Pk == "" {
-			continue
+		var fileStr string
+		if len(tb.Pks) > 1 {
+			fileStr = compositeController(tb)
+		} else {
+			fileStr = strings.Replace(CtrlTPL, "{{ctrlName}}", utils.CamelCase(tb.Name), -1)
 		}
+		fileStr = strings.Replace(fileStr, "{{pkgPath}}", pkgPath, -1)
 		filename := getFileName(tb.Name)
 		fpath := path.Join(cPath, filename+".go")
 		var f *os.File
@@ -814,6 +1311,10 @@ func writeControllerFiles(tables []*Table, cPath string, pkgPath string) {
 		if utils.IsExist(fpath) {
 			beeLogger.Log.Warnf("'%s' already exists. Do you want to overwrite it? [Yes|No] ", fpath)
 			if utils.AskForConfirmation() {
+				var ok bool
+				if fileStr, ok = keepCustomRegions(fpath, fileStr); !ok {
+					continue
+				}
 				f, err = os.OpenFile(fpath, os.O_RDWR|os.O_TRUNC, 0666)
 				if err != nil {
 					beeLogger.Log.Warnf("%s", err)
@@ -830,8 +1331,6 @@ func writeControllerFiles(tables []*Table, cPath string, pkgPath string) {
 				continue
 			}
 		}
-		fileStr := strings.Replace(CtrlTPL, "{{ctrlName}}", utils.CamelCase(tb.Name), -1)
-		fileStr = strings.Replace(fileStr, "{{pkgPath}}", pkgPath, -1)
 		if _, err := f.WriteString(fileStr); err != nil {
 			beeLogger.Log.Fatalf("Could not write controller file to '%s': %s", fpath, err)
 		}
@@ -841,51 +1340,201 @@ func writeControllerFiles(tables []*Table, cPath string, pkgPath string) {
 	}
 }
 
-// writeRouterFile generates router file
-func writeRouterFile(tables []*Table, rPath string, pkgPath string) {
+// keepCustomRegions returns fileStr with the custom regions of the file at
+// fpath it replaces, or false if they could not be read
+func keepCustomRegions(fpath, fileStr string) (string, bool) {
+	old, err := ioutil.ReadFile(fpath)
+	if err != nil {
+		beeLogger.Log.Warnf("%s", err)
+		return "", false
+	}
+	fileStr, orphans, err := utils.KeepCustomRegions(fileStr, string(old))
+	if err != nil {
+		beeLogger.Log.Warnf("Skipped '%s' as its custom regions could not be read: %s", fpath, err)
+		return "", false
+	}
+	for _, name := range orphans {
+		beeLogger.Log.Warnf("Custom region '%s' has no place in the new '%s': appended it at the end of the file", name, fpath)
+	}
+	return fileStr, true
+}
+
+// writeGeneratedFile writes a generated file, asking before overwriting it and keeping its custom regions
+func writeGeneratedFile(fpath, fileStr string) {
 	w := colors.NewColorWriter(os.Stdout)
+	if utils.IsExist(fpath) {
+		beeLogger.Log.Warnf("'%s' already exists. Do you want to overwrite it? [Yes|No] ", fpath)
+		if !utils.AskForConfirmation() {
+			beeLogger.Log.Warnf("Skipped create file '%s'", fpath)
+			return
+		}
+		var ok bool
+		if fileStr, ok = keepCustomRegions(fpath, fileStr); !ok {
+			return
+		}
+	}
+	f, err := os.OpenFile(fpath, os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0666)
+	if err != nil {
+		beeLogger.Log.Fatalf("Could not create file '%s': %s", fpath, err)
+	}
+	if _, err := f.WriteString(fileStr); err != nil {
+		beeLogger.Log.Fatalf("Could not write file '%s': %s", fpath, err)
+	}
+	utils.CloseFile(f)
+	fmt.Fprintf(w, "\t%s%screate%s\t %s%s\n", "\x1b[32m", "\x1b[1m", "\x1b[21m", fpath, "\x1b[0m")
+	if strings.HasSuffix(fpath, ".go") {
+		utils.FormatSourceCode(fpath)
+	}
+}
 
+// writeRouterFile generates router file
+func writeRouterFile(tables []*Table, rPath string, pkgPath string) {
 	var nameSpaces []string
 	for _, tb := range tables {
-		if tb.Pk == "" {
-			continue
-		}
 		// Add namespaces
 		nameSpace := strings.Replace(NamespaceTPL, "{{nameSpace}}", tb.Name, -1)
 		nameSpace = strings.Replace(nameSpace, "{{ctrlName}}", utils.CamelCase(tb.Name), -1)
 		nameSpaces = append(nameSpaces, nameSpace)
 	}
 	// Add export controller
-	fpath := filepath.Join(rPath, "router.go")
 	routerStr := strings.Replace(RouterTPL, "{{nameSpaces}}", strings.Join(nameSpaces, ""), 1)
 	routerStr = strings.Replace(routerStr, "{{pkgPath}}", pkgPath, 1)
-	var f *os.File
-	var err error
-	if utils.IsExist(fpath) {
-		beeLogger.Log.Warnf("'%s' already exists. Do you want to overwrite it? [Yes|No] ", fpath)
-		if utils.AskForConfirmation() {
-			f, err = os.OpenFile(fpath, os.O_RDWR|os.O_TRUNC, 0666)
-			if err != nil {
-				beeLogger.Log.Warnf("%s", err)
-				return
+	writeGeneratedFile(filepath.Join(rPath, "router.go"), routerStr)
+}
+
+// controllerTables returns the tables a controller and a route are generated for: those with
+// a primary key, except join tables and tables with a key part a route param can't hold
+func controllerTables(tables []*Table) (ctrlTables []*Table) {
+	for _, tb := range tables {
+		switch {
+		case tb.JoinTable:
+		case len(tb.Pks) > 1 && !parsableKey(tb):
+			beeLogger.Log.Warnf("Table '%s' has a primary key part that can't be read from a route param: skipped its controller", tb.Name)
+		case len(tb.Pks) > 1 || tb.Pk != "":
+			ctrlTables = append(ctrlTables, tb)
+		}
+	}
+	return
+}
+
+// keyColumns returns the columns of a composite primary key, in key order
+func (tb *Table) keyColumns() (keys []*Column) {
+	for _, pk := range tb.Pks {
+		for _, col := range tb.Columns {
+			if col.Tag.Column == pk {
+				keys = append(keys, col)
 			}
-		} else {
-			beeLogger.Log.Warnf("Skipped create file '%s'", fpath)
-			return
 		}
-	} else {
-		f, err = os.OpenFile(fpath, os.O_CREATE|os.O_RDWR, 0666)
-		if err != nil {
-			beeLogger.Log.Warnf("%s", err)
-			return
+	}
+	return
+}
+
+// isKey reports whether the column is part of the primary key
+func (tb *Table) isKey(col *Column) bool {
+	for _, pk := range tb.Pks {
+		if col.Tag.Column == pk {
+			return true
 		}
 	}
-	if _, err := f.WriteString(routerStr); err != nil {
-		beeLogger.Log.Fatalf("Could not write router file to '%s': %s", fpath, err)
+	return false
+}
+
+// keyVar returns the name of the variable holding a part of the primary key
+func keyVar(col *Column) string {
+	name := strings.ToLower(col.Name[:1]) + col.Name[1:]
+	// avoid keywords and the names used by the generated code
+	if token.Lookup(name).IsKeyword() || name == "c" || name == "v" || name == "m" || name == "o" || name == "err" {
+		name += "Key"
 	}
-	utils.CloseFile(f)
-	fmt.Fprintf(w, "\t%s%screate%s\t %s%s\n", "\x1b[32m", "\x1b[1m", "\x1b[21m", fpath, "\x1b[0m")
-	utils.FormatSourceCode(fpath)
+	return name
+}
+
+// parsableKey reports whether every part of the primary key can be read from
+// a route param
+func parsableKey(tb *Table) bool {
+	for _, col := range tb.keyColumns() {
+		switch strings.TrimRight(col.Type, "0123456789") {
+		case "string", "bool", "int", "uint", "float":
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// compositeModel renders the model of a table with a composite primary key
+func compositeModel(tb *Table) string {
+	var columns, marks, fieldArgs, sets, setArgs []string
+	for _, col := range tb.Columns {
+		columns = append(columns, col.Tag.Column)
+		marks = append(marks, "?")
+		fieldArgs = append(fieldArgs, "m."+col.Name)
+		if !tb.isKey(col) {
+			sets = append(sets, col.Tag.Column+" = ?")
+			setArgs = append(setArgs, "m."+col.Name)
+		}
+	}
+	var keyParams, keyWhere, keyArgs, modelKeyArgs []string
+	for _, col := range tb.keyColumns() {
+		keyParams = append(keyParams, keyVar(col)+" "+col.Type)
+		keyWhere = append(keyWhere, col.Tag.Column+" = ?")
+		keyArgs = append(keyArgs, keyVar(col))
+		modelKeyArgs = append(modelKeyArgs, "m."+col.Name)
+	}
+	// a table made of its key only has nothing to update
+	updateFunc := ""
+	if len(sets) > 0 {
+		updateFunc = strings.Replace(CompositeUpdateTPL, "{{setColumns}}", strings.Join(sets, ", "), -1)
+		updateFunc = strings.Replace(updateFunc, "{{updateArgs}}", strings.Join(append(setArgs, modelKeyArgs...), ", "), -1)
+		updateFunc = strings.Replace(updateFunc, "{{modelKeyArgs}}", strings.Join(modelKeyArgs, ", "), -1)
+	}
+	fileStr := strings.Replace(CompositeModelTPL, "{{updateFunc}}", updateFunc, -1)
+	fileStr = strings.Replace(fileStr, "{{columns}}", strings.Join(columns, ", "), -1)
+	fileStr = strings.Replace(fileStr, "{{marks}}", strings.Join(marks, ", "), -1)
+	fileStr = strings.Replace(fileStr, "{{fieldArgs}}", strings.Join(fieldArgs, ", "), -1)
+	fileStr = strings.Replace(fileStr, "{{keyColumns}}", strings.Join(tb.Pks, ", "), -1)
+	fileStr = strings.Replace(fileStr, "{{keyParams}}", strings.Join(keyParams, ", "), -1)
+	fileStr = strings.Replace(fileStr, "{{keyWhere}}", strings.Join(keyWhere, " AND "), -1)
+	fileStr = strings.Replace(fileStr, "{{keyArgs}}", strings.Join(keyArgs, ", "), -1)
+	return fileStr
+}
+
+// compositeController renders the controller of a table with a composite
+// primary key, taking each part of the key as a route param
+func compositeController(tb *Table) string {
+	var keyResults, keyVars, keyRoute []string
+	var keyParse, keyDocs, keyAssign string
+	fmtPkg := ""
+	for _, col := range tb.keyColumns() {
+		v := keyVar(col)
+		keyResults = append(keyResults, v+" "+col.Type)
+		keyVars = append(keyVars, v)
+		keyRoute = append(keyRoute, ":"+col.Tag.Column)
+		if col.Type == "string" {
+			keyParse += fmt.Sprintf("\t%s = c.Ctx.Input.Param(\":%s\")\n", v, col.Tag.Column)
+		} else {
+			keyParse += fmt.Sprintf("\tif _, err = fmt.Sscan(c.Ctx.Input.Param(\":%s\"), &%s); err != nil {\n\t\treturn\n\t}\n", col.Tag.Column, v)
+			fmtPkg = "\"fmt\"\n"
+		}
+		keyDocs += fmt.Sprintf("// @Param\t%s\t\tpath \t%s\ttrue\t\t\"Part of the primary key\"\n", col.Tag.Column, col.Type)
+		keyAssign += fmt.Sprintf("\t\tv.%s = %s\n", col.Name, v)
+	}
+	putMapping, putFunc := "", ""
+	if len(tb.keyColumns()) < len(tb.Columns) {
+		putMapping = "\n\tc.Mapping(\"Put\", c.Put)"
+		putFunc = strings.Replace(CompositeCtrlPutTPL, "{{keyAssign}}", keyAssign, -1)
+	}
+	fileStr := strings.Replace(CompositeCtrlTPL, "{{putFunc}}", putFunc, -1)
+	fileStr = strings.Replace(fileStr, "{{putMapping}}", putMapping, -1)
+	fileStr = strings.Replace(fileStr, "{{ctrlName}}", utils.CamelCase(tb.Name), -1)
+	fileStr = strings.Replace(fileStr, "{{fmtPkg}}", fmtPkg, -1)
+	fileStr = strings.Replace(fileStr, "{{keyColumns}}", strings.Join(tb.Pks, ", "), -1)
+	fileStr = strings.Replace(fileStr, "{{keyResults}}", strings.Join(keyResults, ", "), -1)
+	fileStr = strings.Replace(fileStr, "{{keyParse}}", keyParse, -1)
+	fileStr = strings.Replace(fileStr, "{{keyVars}}", strings.Join(keyVars, ", "), -1)
+	fileStr = strings.Replace(fileStr, "{{keyRoute}}", strings.Join(keyRoute, "/"), -1)
+	fileStr = strings.Replace(fileStr, "{{keyDocs}}", keyDocs, -1)
+	return fileStr
 }
 
 func isSQLTemporalType(t string) bool {
@@ -922,12 +1571,6 @@ func extractColSize(colType string) string {
 	return size[1]
 }
 
-func extractIntSignness(colType string) string {
-	regex := regexp.MustCompile(`(int|smallint|mediumint|bigint)\([0-9]+\)(.*)`)
-	signRegex := regex.FindStringSubmatch(colType)
-	return strings.Trim(signRegex[2], " ")
-}
-
 func extractDecimal(colType string) (digits string, decimals string) {
 	decimalRegex := regexp.MustCompile(`decimal\(([0-9]+),([0-9]+)\)`)
 	decimal := decimalRegex.FindStringSubmatch(colType)
@@ -982,9 +1625,11 @@ const (
 	StructModelTPL = `package models
 {{importTimePkg}}
 {{modelStruct}}
+// bee:custom begin functions
+// bee:custom end
 `
 
-	ModelTPL = `package models
+	ModelTPL = `package {{packageName}}
 
 import (
 	"errors"
@@ -995,6 +1640,9 @@ import (
 	"github.com/astaxie/beego/orm"
 )
 
+// bee:custom begin imports
+// bee:custom end
+
 {{modelStruct}}
 
 func (t *{{modelName}}) TableName() string {
@@ -1015,7 +1663,7 @@ func Add{{modelName}}(m *{{modelName}}) (id int64, err error) {
 
 // Get{{modelName}}ById retrieves {{modelName}} by Id. Returns error if
 // Id doesn't exist
-func Get{{modelName}}ById(id int) (v *{{modelName}}, err error) {
+func Get{{modelName}}ById(id {{pkType}}) (v *{{modelName}}, err error) {
 	o := orm.NewOrm()
 	v = &{{modelName}}{Id: id}
 	if err = o.Read(v); err == nil {
@@ -1119,7 +1767,7 @@ func Update{{modelName}}ById(m *{{modelName}}) (err error) {
 
 // Delete{{modelName}} deletes {{modelName}} by Id and returns error if
 // the record to be deleted doesn't exist
-func Delete{{modelName}}(id int) (err error) {
+func Delete{{modelName}}(id {{pkType}}) (err error) {
 	o := orm.NewOrm()
 	v := {{modelName}}{Id: id}
 	// ascertain id exists in the database
@@ -1131,6 +1779,9 @@ func Delete{{modelName}}(id int) (err error) {
 	}
 	return
 }
+
+// bee:custom begin functions
+// bee:custom end
 `
 	CtrlTPL = `package controllers
 
@@ -1144,6 +1795,9 @@ import (
 	"github.com/astaxie/beego"
 )
 
+// bee:custom begin imports
+// bee:custom end
+
 // {{ctrlName}}Controller operations for {{ctrlName}}
 type {{ctrlName}}Controller struct {
 	beego.Controller
@@ -1156,6 +1810,8 @@ func (c *{{ctrlName}}Controller) URLMapping() {
 	c.Mapping("GetAll", c.GetAll)
 	c.Mapping("Put", c.Put)
 	c.Mapping("Delete", c.Delete)
+	// bee:custom begin mappings
+	// bee:custom end
 }
 
 // Post ...
@@ -1303,6 +1959,246 @@ func (c *{{ctrlName}}Controller) Delete() {
 	}
 	c.ServeJSON()
 }
+
+// bee:custom begin methods
+// bee:custom end
+`
+	CompositeModelTPL = `package models
+
+import (
+	"database/sql"
+	"fmt"
+	{{timePkg}}
+	"github.com/astaxie/beego/orm"
+)
+
+// bee:custom begin imports
+// bee:custom end
+
+{{modelStruct}}
+
+func (t *{{modelName}}) TableName() string {
+	return "{{tableName}}"
+}
+
+// The orm can't register a model with the composite primary key
+// ({{keyColumns}}), so {{modelName}} is read and written with raw SQL.
+
+// Add{{modelName}} insert a new {{modelName}} into database
+func Add{{modelName}}(m *{{modelName}}) (err error) {
+	o := orm.NewOrm()
+	_, err = o.Raw("INSERT INTO {{tableName}} ({{columns}}) VALUES ({{marks}})", {{fieldArgs}}).Exec()
+	return
+}
+
+// Get{{modelName}}ByKey retrieves {{modelName}} by its primary key. Returns error if
+// the key doesn't exist
+func Get{{modelName}}ByKey({{keyParams}}) (v *{{modelName}}, err error) {
+	o := orm.NewOrm()
+	v = &{{modelName}}{}
+	if err = o.Raw("SELECT {{columns}} FROM {{tableName}} WHERE {{keyWhere}}", {{keyArgs}}).QueryRow(v); err == nil {
+		return v, nil
+	}
+	return nil, err
+}
+
+// GetAll{{modelName}} retrieves {{modelName}} ordered by primary key. Returns empty list if
+// no records exist
+func GetAll{{modelName}}(offset int64, limit int64) (ml []{{modelName}}, err error) {
+	o := orm.NewOrm()
+	_, err = o.Raw("SELECT {{columns}} FROM {{tableName}} ORDER BY {{keyColumns}} LIMIT ? OFFSET ?", limit, offset).QueryRows(&ml)
+	return
+}
+{{updateFunc}}
+// Delete{{modelName}} deletes {{modelName}} by its primary key and returns error if
+// the record to be deleted doesn't exist
+func Delete{{modelName}}({{keyParams}}) (err error) {
+	o := orm.NewOrm()
+	// ascertain the key exists in the database
+	if _, err = Get{{modelName}}ByKey({{keyArgs}}); err == nil {
+		var res sql.Result
+		if res, err = o.Raw("DELETE FROM {{tableName}} WHERE {{keyWhere}}", {{keyArgs}}).Exec(); err == nil {
+			num, _ := res.RowsAffected()
+			fmt.Println("Number of records deleted in database:", num)
+		}
+	}
+	return
+}
+
+// bee:custom begin functions
+// bee:custom end
+`
+	CompositeUpdateTPL = `
+// Update{{modelName}}ByKey updates {{modelName}} by its primary key and returns error if
+// the record to be updated doesn't exist
+func Update{{modelName}}ByKey(m *{{modelName}}) (err error) {
+	o := orm.NewOrm()
+	// ascertain the key exists in the database
+	if _, err = Get{{modelName}}ByKey({{modelKeyArgs}}); err == nil {
+		var res sql.Result
+		if res, err = o.Raw("UPDATE {{tableName}} SET {{setColumns}} WHERE {{keyWhere}}", {{updateArgs}}).Exec(); err == nil {
+			num, _ := res.RowsAffected()
+			fmt.Println("Number of records updated in database:", num)
+		}
+	}
+	return
+}
+`
+	CompositeCtrlTPL = `package controllers
+
+import (
+	"{{pkgPath}}/models"
+	"encoding/json"
+	{{fmtPkg}}
+	"github.com/astaxie/beego"
+)
+
+// bee:custom begin imports
+// bee:custom end
+
+// {{ctrlName}}Controller operations for {{ctrlName}}, whose primary key is ({{keyColumns}})
+type {{ctrlName}}Controller struct {
+	beego.Controller
+}
+
+// URLMapping ...
+func (c *{{ctrlName}}Controller) URLMapping() {
+	c.Mapping("Post", c.Post)
+	c.Mapping("GetOne", c.GetOne)
+	c.Mapping("GetAll", c.GetAll){{putMapping}}
+	c.Mapping("Delete", c.Delete)
+	// bee:custom begin mappings
+	// bee:custom end
+}
+
+// key parses the parts of the primary key from the route params
+func (c *{{ctrlName}}Controller) key() ({{keyResults}}, err error) {
+{{keyParse}}	return
+}
+
+// Post ...
+// @Title Post
+// @Description create {{ctrlName}}
+// @Param	body		body 	models.{{ctrlName}}	true		"body for {{ctrlName}} content"
+// @Success 201 {int} models.{{ctrlName}}
+// @Failure 403 body is empty
+// @router / [post]
+func (c *{{ctrlName}}Controller) Post() {
+	var v models.{{ctrlName}}
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &v); err == nil {
+		if err := models.Add{{ctrlName}}(&v); err == nil {
+			c.Ctx.Output.SetStatus(201)
+			c.Data["json"] = v
+		} else {
+			c.Data["json"] = err.Error()
+		}
+	} else {
+		c.Data["json"] = err.Error()
+	}
+	c.ServeJSON()
+}
+
+// GetOne ...
+// @Title Get One
+// @Description get {{ctrlName}} by primary key
+{{keyDocs}}// @Success 200 {object} models.{{ctrlName}}
+// @Failure 403 the key is invalid
+// @router /{{keyRoute}} [get]
+func (c *{{ctrlName}}Controller) GetOne() {
+	{{keyVars}}, err := c.key()
+	if err != nil {
+		c.Data["json"] = err.Error()
+		c.ServeJSON()
+		return
+	}
+	v, err := models.Get{{ctrlName}}ByKey({{keyVars}})
+	if err != nil {
+		c.Data["json"] = err.Error()
+	} else {
+		c.Data["json"] = v
+	}
+	c.ServeJSON()
+}
+
+// GetAll ...
+// @Title Get All
+// @Description get {{ctrlName}}
+// @Param	limit	query	string	false	"Limit the size of result set. Must be an integer"
+// @Param	offset	query	string	false	"Start position of result set. Must be an integer"
+// @Success 200 {object} models.{{ctrlName}}
+// @Failure 403
+// @router / [get]
+func (c *{{ctrlName}}Controller) GetAll() {
+	var limit int64 = 10
+	var offset int64
+
+	// limit: 10 (default is 10)
+	if v, err := c.GetInt64("limit"); err == nil {
+		limit = v
+	}
+	// offset: 0 (default is 0)
+	if v, err := c.GetInt64("offset"); err == nil {
+		offset = v
+	}
+
+	l, err := models.GetAll{{ctrlName}}(offset, limit)
+	if err != nil {
+		c.Data["json"] = err.Error()
+	} else {
+		c.Data["json"] = l
+	}
+	c.ServeJSON()
+}
+{{putFunc}}
+// Delete ...
+// @Title Delete
+// @Description delete the {{ctrlName}}
+{{keyDocs}}// @Success 200 {string} delete success!
+// @Failure 403 the key is invalid
+// @router /{{keyRoute}} [delete]
+func (c *{{ctrlName}}Controller) Delete() {
+	{{keyVars}}, err := c.key()
+	if err == nil {
+		err = models.Delete{{ctrlName}}({{keyVars}})
+	}
+	if err == nil {
+		c.Data["json"] = "OK"
+	} else {
+		c.Data["json"] = err.Error()
+	}
+	c.ServeJSON()
+}
+
+// bee:custom begin methods
+// bee:custom end
+`
+	CompositeCtrlPutTPL = `
+// Put ...
+// @Title Put
+// @Description update the {{ctrlName}}
+{{keyDocs}}// @Param	body		body 	models.{{ctrlName}}	true		"body for {{ctrlName}} content"
+// @Success 200 {object} models.{{ctrlName}}
+// @Failure 403 the key is invalid
+// @router /{{keyRoute}} [put]
+func (c *{{ctrlName}}Controller) Put() {
+	{{keyVars}}, err := c.key()
+	if err != nil {
+		c.Data["json"] = err.Error()
+		c.ServeJSON()
+		return
+	}
+	var v models.{{ctrlName}}
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &v); err == nil {
+{{keyAssign}}		if err := models.Update{{ctrlName}}ByKey(&v); err == nil {
+			c.Data["json"] = "OK"
+		} else {
+			c.Data["json"] = err.Error()
+		}
+	} else {
+		c.Data["json"] = err.Error()
+	}
+	c.ServeJSON()
+}
 `
 	RouterTPL = `// @APIVersion 1.0.0
 // @Title beego Test API
@@ -1319,11 +2215,19 @@ import (
 	"github.com/astaxie/beego"
 )
 
+// bee:custom begin imports
+// bee:custom end
+
 func init() {
 	ns := beego.NewNamespace("/v1",
 		{{nameSpaces}}
+		// bee:custom begin namespaces
+		// bee:custom end
 	)
 	beego.AddNamespace(ns)
+
+	// bee:custom begin routes
+	// bee:custom end
 }
 `
 	NamespaceTPL = `
@@ -0,0 +1,728 @@
+// Copyright 2013 bee authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package generate
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	beeLogger "github.com/beego/bee/logger"
+)
+
+// DDLTransformer is the version of DbTransformer reading the tables declared
+// by the statements of a schema file instead of a live database
+type DDLTransformer struct {
+	dbms   string
+	names  []string
+	tables map[string]*ddlFileTable
+}
+
+// ddlFileTable is a table as declared in a schema file
+type ddlFileTable struct {
+	columns []*columnInfo
+	pks     []string
+	uks     []string
+	fks     []*ForeignKey
+}
+
+// ddlToken is a token of a schema file
+type ddlToken struct {
+	text string
+	kind byte
+}
+
+const (
+	ddlWord   byte = iota // keywords, names and numbers
+	ddlIdent              // quoted identifiers
+	ddlString             // string literals
+	ddlPunct              // anything else, one character at a time
+)
+
+// ddlTypeWords are the words continuing a type name, e.g. character varying
+var ddlTypeWords = map[string]bool{
+	"varying": true, "precision": true, "with": true, "without": true, "time": true, "zone": true,
+	"unsigned": true, "signed": true, "zerofill": true,
+}
+
+// ddlModifiers are the keywords ending the default value of a column
+var ddlModifiers = map[string]bool{
+	"NOT": true, "NULL": true, "DEFAULT": true, "PRIMARY": true, "KEY": true, "UNIQUE": true,
+	"AUTO_INCREMENT": true, "AUTOINCREMENT": true, "COMMENT": true, "REFERENCES": true, "ON": true,
+	"GENERATED": true, "CHECK": true, "COLLATE": true, "CHARACTER": true, "CONSTRAINT": true,
+	"VISIBLE": true, "INVISIBLE": true, "COLUMN_FORMAT": true, "STORAGE": true,
+}
+
+// ddlConstraints are the keywords starting a table constraint rather than a column
+var ddlConstraints = map[string]bool{
+	"CONSTRAINT": true, "PRIMARY": true, "UNIQUE": true, "FOREIGN": true, "KEY": true, "INDEX": true,
+	"FULLTEXT": true, "SPATIAL": true, "CHECK": true, "EXCLUDE": true, "LIKE": true,
+}
+
+// ddlTypeAliasesMysql maps the type names a MySQL schema may use to their data_type
+var ddlTypeAliasesMysql = map[string]string{
+	"integer":           "int",
+	"int1":              "tinyint",
+	"int2":              "smallint",
+	"int3":              "mediumint",
+	"middleint":         "mediumint",
+	"int4":              "int",
+	"int8":              "bigint",
+	"bool":              "tinyint",
+	"boolean":           "tinyint",
+	"real":              "double",
+	"double precision":  "double",
+	"float4":            "float",
+	"float8":            "double",
+	"numeric":           "decimal",
+	"dec":               "decimal",
+	"fixed":             "decimal",
+	"character":         "char",
+	"character varying": "varchar",
+	"char varying":      "varchar",
+	"nchar":             "char",
+	"nvarchar":          "varchar",
+	"long":              "mediumtext",
+	"long varchar":      "mediumtext",
+}
+
+// ddlTypeAliasesPostgres maps the type names a PostgreSQL schema may use to their data_type
+var ddlTypeAliasesPostgres = map[string]string{
+	"int":          "integer",
+	"int4":         "integer",
+	"int2":         "smallint",
+	"int8":         "bigint",
+	"bool":         "boolean",
+	"varchar":      "character varying",
+	"char varying": "character varying",
+	"char":         "character",
+	"bpchar":       "character",
+	"float":        "double precision",
+	"float8":       "double precision",
+	"float4":       "real",
+	"decimal":      "numeric",
+	"timestamp":    "timestamp without time zone",
+	"timestamptz":  "timestamp with time zone",
+	"time":         "time without time zone",
+	"timetz":       "time with time zone",
+}
+
+// ddlSerialTypes maps the PostgreSQL serial types to the integer backing them
+var ddlSerialTypes = map[string]string{
+	"serial":      "integer",
+	"serial4":     "integer",
+	"bigserial":   "bigint",
+	"serial8":     "bigint",
+	"smallserial": "smallint",
+	"serial2":     "smallint",
+}
+
+// genFromDDL generates the app code from the tables declared in a schema file
+func genFromDDL(dbms, ddlFile string, mode byte, selectedTableNames map[string]bool, apppath string) {
+	if dbms != "mysql" && dbms != "postgres" {
+		beeLogger.Log.Fatalf("Generating app code from a '%s' DDL file is not supported yet. Use either mysql or postgres.", dbms)
+	}
+	src, err := ioutil.ReadFile(ddlFile)
+	if err != nil {
+		beeLogger.Log.Fatalf("Could not read DDL file '%s': %s", ddlFile, err)
+	}
+	trans, err := parseDDL(dbms, string(src))
+	if err != nil {
+		beeLogger.Log.Fatalf("Could not parse DDL file '%s': %s", ddlFile, err)
+	}
+	if len(trans.names) == 0 {
+		beeLogger.Log.Fatalf("No CREATE TABLE statement found in '%s'", ddlFile)
+	}
+	beeLogger.Log.Infof("Analyzing tables of '%s'...", ddlFile)
+	genTables(nil, trans, mode, selectedTableNames, apppath)
+}
+
+// parseDDL reads the tables declared by the CREATE TABLE, ALTER TABLE and
+// COMMENT ON COLUMN statements of a schema file
+func parseDDL(dbms, src string) (*DDLTransformer, error) {
+	tokens, err := lexDDL(dbms, src)
+	if err != nil {
+		return nil, err
+	}
+	t := &DDLTransformer{dbms: dbms, tables: make(map[string]*ddlFileTable)}
+	var stmt []ddlToken
+	for _, tok := range tokens {
+		if tok.kind == ddlPunct && tok.text == ";" {
+			t.parseStatement(stmt)
+			stmt = nil
+		} else {
+			stmt = append(stmt, tok)
+		}
+	}
+	t.parseStatement(stmt)
+	return t, nil
+}
+
+// lexDDL splits a schema file into tokens, dropping the comments
+func lexDDL(dbms, src string) (tokens []ddlToken, err error) {
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			i++
+		case strings.HasPrefix(src[i:], "--") || (c == '#' && dbms == "mysql"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				return nil, errors.New("unterminated comment")
+			}
+			i += end + 4
+		case c == '\'' || (c == '"' && dbms == "mysql"):
+			text, n, err := lexQuoted(src[i:], c, dbms == "mysql")
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, ddlToken{text, ddlString})
+			i += n
+		case c == '`' || c == '"':
+			text, n, err := lexQuoted(src[i:], c, false)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, ddlToken{text, ddlIdent})
+			i += n
+		case c == '$' && dbms == "postgres" && dollarTag(src[i:]) != "":
+			// a dollar-quoted string, e.g. the body of a function
+			tag := dollarTag(src[i:])
+			end := strings.Index(src[i+len(tag):], tag)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string %s", tag)
+			}
+			tokens = append(tokens, ddlToken{src[i+len(tag) : i+len(tag)+end], ddlString})
+			i += 2*len(tag) + end
+		case isDDLWordChar(c):
+			j := i
+			for j < len(src) && (isDDLWordChar(src[j]) || (src[j] == '.' && src[i] >= '0' && src[i] <= '9')) {
+				j++
+			}
+			tokens = append(tokens, ddlToken{src[i:j], ddlWord})
+			i = j
+		default:
+			tokens = append(tokens, ddlToken{string(c), ddlPunct})
+			i++
+		}
+	}
+	return
+}
+
+// lexQuoted reads the quoted text s starts with, returning it unquoted along
+// with the length it had in s
+func lexQuoted(s string, quote byte, backslash bool) (string, int, error) {
+	var text []byte
+	for i := 1; i < len(s); i++ {
+		switch {
+		case backslash && s[i] == '\\' && i+1 < len(s):
+			i++
+			text = append(text, s[i])
+		case s[i] == quote && i+1 < len(s) && s[i+1] == quote:
+			i++
+			text = append(text, quote)
+		case s[i] == quote:
+			return string(text), i + 1, nil
+		default:
+			text = append(text, s[i])
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated quote %c", quote)
+}
+
+// dollarTag returns the $tag$ starting a dollar-quoted string, if any
+func dollarTag(s string) string {
+	for i := 1; i < len(s); i++ {
+		if s[i] == '$' {
+			return s[:i+1]
+		}
+		if !isDDLWordChar(s[i]) || s[i] == '$' {
+			break
+		}
+	}
+	return ""
+}
+
+func isDDLWordChar(c byte) bool {
+	return c == '_' || c == '$' || c >= 0x80 ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// is reports whether the token is the keyword
+func (tok ddlToken) is(keyword string) bool {
+	return tok.kind == ddlWord && strings.EqualFold(tok.text, keyword)
+}
+
+// ident returns the name an identifier token refers to. PostgreSQL folds
+// unquoted names to lower case
+func (t *DDLTransformer) ident(tok ddlToken) string {
+	if tok.kind == ddlWord && t.dbms == "postgres" {
+		return strings.ToLower(tok.text)
+	}
+	return tok.text
+}
+
+// name reads a possibly schema qualified name starting at i and returns its
+// parts along with the index following it
+func (t *DDLTransformer) name(toks []ddlToken, i int) (parts []string, next int) {
+	for i < len(toks) {
+		parts = append(parts, t.ident(toks[i]))
+		i++
+		if i+1 >= len(toks) || toks[i].kind != ddlPunct || toks[i].text != "." {
+			break
+		}
+		i++
+	}
+	return parts, i
+}
+
+// tableName reads a table name starting at i, dropping its schema
+func (t *DDLTransformer) tableName(toks []ddlToken, i int) (string, int) {
+	parts, next := t.name(toks, i)
+	if len(parts) == 0 {
+		return "", next
+	}
+	return parts[len(parts)-1], next
+}
+
+// ddlList splits the parenthesized list starting at i into its comma separated
+// items, and returns them along with the index following the list
+func ddlList(toks []ddlToken, i int) (items [][]ddlToken, next int) {
+	depth := 0
+	start := i + 1
+	for j := i; j < len(toks); j++ {
+		if toks[j].kind != ddlPunct {
+			continue
+		}
+		switch toks[j].text {
+		case "(":
+			depth++
+		case ")":
+			depth--
+			if depth == 0 {
+				return append(items, toks[start:j]), j + 1
+			}
+		case ",":
+			if depth == 1 {
+				items = append(items, toks[start:j])
+				start = j + 1
+			}
+		}
+	}
+	return items, len(toks)
+}
+
+// ddlSplit splits tokens on the commas outside of parentheses
+func ddlSplit(toks []ddlToken) (items [][]ddlToken) {
+	depth := 0
+	start := 0
+	for j, tok := range toks {
+		if tok.kind != ddlPunct {
+			continue
+		}
+		switch tok.text {
+		case "(":
+			depth++
+		case ")":
+			depth--
+		case ",":
+			if depth == 0 {
+				items = append(items, toks[start:j])
+				start = j + 1
+			}
+		}
+	}
+	return append(items, toks[start:])
+}
+
+// ddlExpr reads an expression starting at i up to the next column modifier,
+// and returns its text along with the index following it
+func ddlExpr(toks []ddlToken, i int) (string, int) {
+	var text []string
+	depth := 0
+	for j := i; j < len(toks); j++ {
+		tok := toks[j]
+		if depth == 0 && j > i && tok.kind == ddlWord && ddlModifiers[strings.ToUpper(tok.text)] {
+			return strings.Join(text, " "), j
+		}
+		if tok.kind == ddlPunct && tok.text == "(" {
+			depth++
+		} else if tok.kind == ddlPunct && tok.text == ")" {
+			depth--
+		}
+		text = append(text, tok.text)
+	}
+	return strings.Join(text, " "), len(toks)
+}
+
+// columnNames returns the column names of a key's column list, e.g. (a, b(10))
+func (t *DDLTransformer) columnNames(items [][]ddlToken) (names []string) {
+	for _, item := range items {
+		if len(item) > 0 {
+			names = append(names, t.ident(item[0]))
+		}
+	}
+	return
+}
+
+// parseStatement reads the tables and constraints declared by a statement,
+// ignoring the statements that declare neither
+func (t *DDLTransformer) parseStatement(stmt []ddlToken) {
+	switch {
+	case len(stmt) == 0:
+	case stmt[0].is("CREATE"):
+		t.parseCreateTable(stmt)
+	case stmt[0].is("ALTER") && len(stmt) > 1 && stmt[1].is("TABLE"):
+		t.parseAlterTable(stmt[2:])
+	case stmt[0].is("COMMENT") && len(stmt) > 2 && stmt[1].is("ON") && stmt[2].is("COLUMN"):
+		t.parseColumnComment(stmt[3:])
+	}
+}
+
+// parseCreateTable reads a CREATE TABLE statement
+func (t *DDLTransformer) parseCreateTable(stmt []ddlToken) {
+	i := 1
+	for i < len(stmt) && !stmt[i].is("TABLE") {
+		switch strings.ToUpper(stmt[i].text) {
+		case "OR", "REPLACE", "GLOBAL", "LOCAL", "TEMPORARY", "TEMP", "UNLOGGED":
+			i++
+		default:
+			// CREATE INDEX, CREATE VIEW...
+			return
+		}
+	}
+	i++
+	if i+2 < len(stmt) && stmt[i].is("IF") && stmt[i+1].is("NOT") && stmt[i+2].is("EXISTS") {
+		i += 3
+	}
+	name, i := t.tableName(stmt, i)
+	if i >= len(stmt) || stmt[i].text != "(" {
+		beeLogger.Log.Warnf("Skipped table '%s', which is not declared with its columns", name)
+		return
+	}
+	tb := new(ddlFileTable)
+	defs, _ := ddlList(stmt, i)
+	for _, def := range defs {
+		if len(def) == 0 {
+			continue
+		}
+		if def[0].kind == ddlWord && ddlConstraints[strings.ToUpper(def[0].text)] {
+			t.addConstraint(tb, def)
+		} else {
+			t.addColumn(tb, def)
+		}
+	}
+	if _, ok := t.tables[name]; !ok {
+		t.names = append(t.names, name)
+	}
+	t.tables[name] = tb
+}
+
+// parseAlterTable reads the columns and constraints added by an ALTER TABLE
+// statement, such as the ones pg_dump writes after the CREATE TABLE statements
+func (t *DDLTransformer) parseAlterTable(stmt []ddlToken) {
+	i := 0
+	for i < len(stmt) && (stmt[i].is("ONLY") || stmt[i].is("IF") || stmt[i].is("EXISTS")) {
+		i++
+	}
+	name, i := t.tableName(stmt, i)
+	tb, ok := t.tables[name]
+	if !ok {
+		return
+	}
+	for _, action := range ddlSplit(stmt[i:]) {
+		switch {
+		case len(action) < 2:
+		case action[0].is("ADD"):
+			def := action[1:]
+			if def[0].is("COLUMN") {
+				def = def[1:]
+			}
+			if len(def) > 2 && def[0].is("IF") && def[1].is("NOT") && def[2].is("EXISTS") {
+				def = def[3:]
+			}
+			if len(def) == 0 {
+				continue
+			}
+			if def[0].kind == ddlWord && ddlConstraints[strings.ToUpper(def[0].text)] {
+				t.addConstraint(tb, def)
+			} else {
+				t.addColumn(tb, def)
+			}
+		case action[0].is("ALTER"):
+			def := action[1:]
+			if def[0].is("COLUMN") {
+				def = def[1:]
+			}
+			if len(def) == 0 {
+				continue
+			}
+			col := tb.column(t.ident(def[0]))
+			if col == nil {
+				continue
+			}
+			for j, tok := range def {
+				switch {
+				case tok.is("IDENTITY"):
+					col.auto = true
+				case tok.is("DEFAULT") && j+1 < len(def) && def[j+1].is("nextval"):
+					col.auto = true
+				case tok.is("NOT") && j+1 < len(def) && def[j+1].is("NULL") && j > 0 && def[j-1].is("SET"):
+					col.notNull = true
+				}
+			}
+		}
+	}
+}
+
+// parseColumnComment reads a COMMENT ON COLUMN statement
+func (t *DDLTransformer) parseColumnComment(stmt []ddlToken) {
+	parts, i := t.name(stmt, 0)
+	if len(parts) < 2 || i+1 >= len(stmt) || !stmt[i].is("IS") {
+		return
+	}
+	if tb, ok := t.tables[parts[len(parts)-2]]; ok {
+		if col := tb.column(parts[len(parts)-1]); col != nil && stmt[i+1].kind == ddlString {
+			col.comment = stmt[i+1].text
+		}
+	}
+}
+
+// addConstraint reads a table constraint, ignoring the ones other than
+// primary, unique and foreign keys
+func (t *DDLTransformer) addConstraint(tb *ddlFileTable, def []ddlToken) {
+	i := 0
+	if def[0].is("CONSTRAINT") {
+		i = 2
+	}
+	if i >= len(def) {
+		return
+	}
+	open := i
+	for open < len(def) && def[open].text != "(" {
+		open++
+	}
+	if open == len(def) {
+		return
+	}
+	cols, next := ddlList(def, open)
+	switch {
+	case def[i].is("PRIMARY"):
+		tb.pks = t.columnNames(cols)
+		// primary key columns are implicitly NOT NULL
+		for _, name := range tb.pks {
+			if col := tb.column(name); col != nil {
+				col.notNull = true
+			}
+		}
+	case def[i].is("UNIQUE"):
+		tb.uks = append(tb.uks, t.columnNames(cols)...)
+	case def[i].is("FOREIGN") && next < len(def) && def[next].is("REFERENCES"):
+		ref, j := t.tableName(def, next+1)
+		var refCols [][]ddlToken
+		if j < len(def) && def[j].text == "(" {
+			refCols, _ = ddlList(def, j)
+		}
+		tb.addForeignKey(t.columnNames(cols), ref, t.columnNames(refCols))
+	}
+}
+
+// addColumn reads a column definition
+func (t *DDLTransformer) addColumn(tb *ddlFileTable, def []ddlToken) {
+	col := &columnInfo{name: t.ident(def[0])}
+	i := 1
+	var words []string
+	for i < len(def) && def[i].kind == ddlWord && (len(words) == 0 || ddlTypeWords[strings.ToLower(def[i].text)]) {
+		words = append(words, strings.ToLower(def[i].text))
+		i++
+		if i < len(def) && def[i].text == "(" && col.args == nil {
+			var args [][]ddlToken
+			args, i = ddlList(def, i)
+			for _, arg := range args {
+				var text []string
+				for _, tok := range arg {
+					text = append(text, tok.text)
+				}
+				col.args = append(col.args, strings.Join(text, ""))
+			}
+		}
+	}
+	// arrays, e.g. integer[] or integer ARRAY
+	array := false
+	for i < len(def) && (def[i].text == "[" || def[i].text == "]" || def[i].is("ARRAY") ||
+		(array && def[i].kind == ddlWord && def[i-1].text == "[")) {
+		array = true
+		i++
+	}
+	col.dataType, col.auto = t.dataType(words, array)
+	for _, w := range words {
+		col.unsigned = col.unsigned || w == "unsigned"
+	}
+	if t.dbms == "mysql" && col.dataType == "serial" {
+		// an alias of BIGINT UNSIGNED NOT NULL AUTO_INCREMENT UNIQUE
+		col.dataType, col.unsigned, col.notNull, col.auto = "bigint", true, true, true
+		tb.uks = append(tb.uks, col.name)
+	}
+
+	for i < len(def) {
+		tok := def[i]
+		switch {
+		case tok.is("NOT") && i+1 < len(def) && def[i+1].is("NULL"):
+			col.notNull = true
+			i += 2
+		case tok.is("DEFAULT"):
+			col.dflt, i = ddlExpr(def, i+1)
+			if strings.HasPrefix(strings.ToLower(col.dflt), "nextval") {
+				col.auto = true
+			}
+		case tok.is("PRIMARY") || tok.is("KEY"):
+			tb.pks = []string{col.name}
+			col.notNull = true
+			i++
+			if tok.is("PRIMARY") && i < len(def) && def[i].is("KEY") {
+				i++
+			}
+		case tok.is("UNIQUE"):
+			tb.uks = append(tb.uks, col.name)
+			i++
+			if i < len(def) && def[i].is("KEY") {
+				i++
+			}
+		case tok.is("AUTO_INCREMENT") || tok.is("AUTOINCREMENT") || tok.is("IDENTITY"):
+			col.auto = true
+			i++
+		case tok.is("COMMENT") && i+1 < len(def):
+			col.comment = def[i+1].text
+			i += 2
+		case tok.is("REFERENCES"):
+			ref, j := t.tableName(def, i+1)
+			var refCols [][]ddlToken
+			if j < len(def) && def[j].text == "(" {
+				refCols, j = ddlList(def, j)
+			}
+			tb.addForeignKey([]string{col.name}, ref, t.columnNames(refCols))
+			i = j
+		case tok.is("ON") && i+1 < len(def) && def[i+1].is("UPDATE"):
+			// ON UPDATE CURRENT_TIMESTAMP, or the action of a foreign key
+			col.onUpdate, i = ddlExpr(def, i+2)
+		case tok.kind == ddlPunct && tok.text == "(":
+			// the expression of CHECK, GENERATED...
+			_, i = ddlList(def, i)
+		default:
+			i++
+		}
+	}
+	tb.columns = append(tb.columns, col)
+}
+
+// dataType returns the data_type information_schema reports for a type
+// name, and whether it implies an auto increment
+func (t *DDLTransformer) dataType(words []string, array bool) (dataType string, auto bool) {
+	var name []string
+	for _, w := range words {
+		if w != "unsigned" && w != "signed" && w != "zerofill" {
+			name = append(name, w)
+		}
+	}
+	dataType = strings.Join(name, " ")
+	if t.dbms == "postgres" {
+		if array {
+			return "ARRAY", false
+		}
+		if v, ok := ddlSerialTypes[dataType]; ok {
+			return v, true
+		}
+		if v, ok := ddlTypeAliasesPostgres[dataType]; ok {
+			dataType = v
+		}
+		// enums, domains and other types created by the schema
+		if _, ok := typeMappingPostgres[dataType]; !ok {
+			dataType = "USER-DEFINED"
+		}
+		return
+	}
+	if v, ok := ddlTypeAliasesMysql[dataType]; ok {
+		dataType = v
+	}
+	return
+}
+
+// addForeignKey records the foreign key of columns referencing refColumns of refTable.
+// An empty refColumns references the primary key
+func (tb *ddlFileTable) addForeignKey(columns []string, refTable string, refColumns []string) {
+	for k, column := range columns {
+		fk := &ForeignKey{Name: column, RefTable: refTable}
+		if k < len(refColumns) {
+			fk.RefColumn = refColumns[k]
+		}
+		tb.fks = append(tb.fks, fk)
+	}
+}
+
+// column returns the column of the table with the given name
+func (tb *ddlFileTable) column(name string) *columnInfo {
+	for _, col := range tb.columns {
+		if col.name == name {
+			return col
+		}
+	}
+	return nil
+}
+
+// GetTableNames returns the tables declared in the schema file, in order
+func (t *DDLTransformer) GetTableNames(db *sql.DB) []string {
+	return t.names
+}
+
+// GetConstraints fills in the primary key, unique keys and foreign keys
+// declared for a table
+func (t *DDLTransformer) GetConstraints(db *sql.DB, table *Table, blackList map[string]bool) {
+	tb, ok := t.tables[table.Name]
+	if !ok {
+		beeLogger.Log.Fatalf("Table '%s' is not declared in the DDL file", table.Name)
+	}
+	for i, pk := range tb.pks {
+		addPkColumn(table, pk, i+1, blackList)
+	}
+	table.Uk = append(table.Uk, tb.uks...)
+	for _, fk := range tb.fks {
+		fk := *fk
+		if fk.RefColumn == "" {
+			fk.RefColumn = "id"
+			if ref, ok := t.tables[fk.RefTable]; ok && len(ref.pks) == 1 {
+				fk.RefColumn = ref.pks[0]
+			}
+		}
+		table.Fk[fk.Name] = &fk
+	}
+}
+
+// GetColumns fills in the columns declared for a table
+func (t *DDLTransformer) GetColumns(db *sql.DB, table *Table, blackList map[string]bool) {
+	for _, c := range t.tables[table.Name].columns {
+		table.Columns = append(table.Columns, newColumn(t.dbms, table, c, blackList))
+	}
+}
+
+// GetGoDataType maps an SQL data type to Golang data type
+func (t *DDLTransformer) GetGoDataType(sqlType string) (string, error) {
+	return dbDriver[t.dbms].GetGoDataType(sqlType)
+}
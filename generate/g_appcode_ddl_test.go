@@ -0,0 +1,204 @@
+// Copyright 2013 bee authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package generate
+
+import (
+	"reflect"
+	"testing"
+)
+
+const mysqlSchema = "-- MySQL dump\n" +
+	"/*!40101 SET NAMES utf8 */;\n" +
+	"CREATE TABLE IF NOT EXISTS `users` (\n" +
+	"  `id` int(10) unsigned NOT NULL AUTO_INCREMENT,\n" +
+	"  `email` varchar(128) NOT NULL DEFAULT '' COMMENT 'it''s the login',\n" +
+	"  `balance` decimal(10,2) DEFAULT NULL,\n" +
+	"  `updated` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,\n" +
+	"  PRIMARY KEY (`id`),\n" +
+	"  UNIQUE KEY `uk_email` (`email`(10)),\n" +
+	"  KEY `idx_balance` (`balance`)\n" +
+	") ENGINE=InnoDB DEFAULT CHARSET=utf8;\n" +
+	"CREATE TABLE posts (id bigint NOT NULL AUTO_INCREMENT PRIMARY KEY, user_id int unsigned REFERENCES users, title text);\n" +
+	"CREATE TABLE orders (user_id int REFERENCES users, item_id int, qty int, PRIMARY KEY (user_id, item_id));\n" +
+	"INSERT INTO users VALUES (1, 'a;b', 0, NOW());\n"
+
+const postgresSchema = `CREATE TABLE public."Users" (
+    id serial NOT NULL,
+    name character varying(64),
+    created timestamp with time zone DEFAULT now()
+);
+CREATE TABLE items (order_id integer NOT NULL, sku varchar(10) NOT NULL, data jsonb, PRIMARY KEY (order_id, sku));
+ALTER TABLE ONLY public."Users" ADD CONSTRAINT users_pkey PRIMARY KEY (id);
+ALTER TABLE ONLY items ADD CONSTRAINT items_order_fkey FOREIGN KEY (order_id) REFERENCES public."Users"(id);
+COMMENT ON COLUMN items.sku IS 'stock keeping unit';
+CREATE FUNCTION touch() RETURNS trigger AS $$ BEGIN NEW.updated = now(); RETURN NEW; END; $$ LANGUAGE plpgsql;
+`
+
+func TestParseDDL(t *testing.T) {
+	tests := []struct {
+		dbms, src string
+		names     []string
+		tables    map[string]*ddlFileTable
+	}{
+		{
+			dbms:  "mysql",
+			src:   mysqlSchema,
+			names: []string{"users", "posts", "orders"},
+			tables: map[string]*ddlFileTable{
+				"users": {
+					pks: []string{"id"},
+					uks: []string{"email"},
+					columns: []*columnInfo{
+						{name: "id", dataType: "int", args: []string{"10"}, unsigned: true, notNull: true, auto: true},
+						{name: "email", dataType: "varchar", args: []string{"128"}, notNull: true, comment: "it's the login"},
+						{name: "balance", dataType: "decimal", args: []string{"10", "2"}, dflt: "NULL"},
+						{name: "updated", dataType: "timestamp", notNull: true, dflt: "CURRENT_TIMESTAMP", onUpdate: "CURRENT_TIMESTAMP"},
+					},
+				},
+				"posts": {
+					pks: []string{"id"},
+					fks: []*ForeignKey{{Name: "user_id", RefTable: "users"}},
+					columns: []*columnInfo{
+						{name: "id", dataType: "bigint", notNull: true, auto: true},
+						{name: "user_id", dataType: "int", unsigned: true},
+						{name: "title", dataType: "text"},
+					},
+				},
+				"orders": {
+					pks: []string{"user_id", "item_id"},
+					fks: []*ForeignKey{{Name: "user_id", RefTable: "users"}},
+					columns: []*columnInfo{
+						{name: "user_id", dataType: "int", notNull: true},
+						{name: "item_id", dataType: "int", notNull: true},
+						{name: "qty", dataType: "int"},
+					},
+				},
+			},
+		},
+		{
+			dbms:  "postgres",
+			src:   postgresSchema,
+			names: []string{"Users", "items"},
+			tables: map[string]*ddlFileTable{
+				"Users": {
+					pks: []string{"id"},
+					columns: []*columnInfo{
+						{name: "id", dataType: "integer", notNull: true, auto: true},
+						{name: "name", dataType: "character varying", args: []string{"64"}},
+						{name: "created", dataType: "timestamp with time zone", dflt: "now ( )"},
+					},
+				},
+				"items": {
+					pks: []string{"order_id", "sku"},
+					fks: []*ForeignKey{{Name: "order_id", RefTable: "Users", RefColumn: "id"}},
+					columns: []*columnInfo{
+						{name: "order_id", dataType: "integer", notNull: true},
+						{name: "sku", dataType: "character varying", args: []string{"10"}, notNull: true, comment: "stock keeping unit"},
+						{name: "data", dataType: "jsonb"},
+					},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		trans, err := parseDDL(tt.dbms, tt.src)
+		if err != nil {
+			t.Errorf("%s: %s", tt.dbms, err)
+			continue
+		}
+		if !reflect.DeepEqual(trans.names, tt.names) {
+			t.Errorf("%s: tables %v, want %v", tt.dbms, trans.names, tt.names)
+		}
+		for name, want := range tt.tables {
+			got := trans.tables[name]
+			if got == nil {
+				t.Errorf("%s: table %s not read", tt.dbms, name)
+				continue
+			}
+			if !reflect.DeepEqual(got.pks, want.pks) || !reflect.DeepEqual(got.uks, want.uks) || !reflect.DeepEqual(got.fks, want.fks) {
+				t.Errorf("%s: %s keys %q %q %+v, want %q %q %+v", tt.dbms, name, got.pks, got.uks, got.fks, want.pks, want.uks, want.fks)
+			}
+			if len(got.columns) != len(want.columns) {
+				t.Errorf("%s: %s has %d columns, want %d", tt.dbms, name, len(got.columns), len(want.columns))
+				continue
+			}
+			for i, col := range got.columns {
+				if !reflect.DeepEqual(col, want.columns[i]) {
+					t.Errorf("%s: %s column\n%+v\nwant\n%+v", tt.dbms, name, *col, *want.columns[i])
+				}
+			}
+		}
+	}
+}
+
+func TestParseDDLErrors(t *testing.T) {
+	tests := []struct{ dbms, src string }{
+		{"mysql", "CREATE TABLE a (b varchar(1) DEFAULT 'x)"},
+		{"mysql", "CREATE TABLE `a (b int)"},
+		{"postgres", "CREATE TABLE a (b text DEFAULT $x$ab)"},
+	}
+	for _, tt := range tests {
+		if _, err := parseDDL(tt.dbms, tt.src); err == nil {
+			t.Errorf("%s: no error parsing %s", tt.dbms, tt.src)
+		}
+	}
+}
+
+func TestDDLTransformerTable(t *testing.T) {
+	trans, err := parseDDL("mysql", mysqlSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table := &Table{Name: "posts", Fk: make(map[string]*ForeignKey)}
+	trans.GetConstraints(nil, table, nil)
+	trans.GetColumns(nil, table, nil)
+	if table.Pk != "id" || table.Fk["user_id"].RefColumn != "id" {
+		t.Errorf("posts has pk %q and foreign keys %+v", table.Pk, table.Fk)
+	}
+	want := []string{
+		"Id int `orm:\"column(id);auto\"`",
+		"UserId *Users `orm:\"column(user_id);null;rel(fk)\"`",
+		"Title string `orm:\"column(title);null\"`",
+	}
+	for i, col := range table.Columns {
+		if i >= len(want) || col.String() != want[i] {
+			t.Errorf("column %d: %s", i, col.String())
+		}
+	}
+
+	table = &Table{Name: "users", Fk: make(map[string]*ForeignKey)}
+	trans.GetConstraints(nil, table, nil)
+	trans.GetColumns(nil, table, nil)
+	updated := table.Columns[3].Tag
+	if !table.ImportTimePkg || updated.Type != "timestamp" || !updated.AutoNow {
+		t.Errorf("updated column has tag %+v", *updated)
+	}
+
+	// the columns of a composite primary key are not null
+	table = &Table{Name: "orders", Fk: make(map[string]*ForeignKey)}
+	blackList := make(map[string]bool)
+	trans.GetConstraints(nil, table, blackList)
+	trans.GetColumns(nil, table, blackList)
+	want = []string{
+		"UserId int `orm:\"column(user_id)\"`",
+		"ItemId int `orm:\"column(item_id)\"`",
+		"Qty int `orm:\"column(qty);null\"`",
+	}
+	for i, col := range table.Columns {
+		if i >= len(want) || col.String() != want[i] {
+			t.Errorf("orders column %d: %s", i, col.String())
+		}
+	}
+}
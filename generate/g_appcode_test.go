@@ -0,0 +1,196 @@
+// Copyright 2013 bee authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package generate
+
+import (
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/beego/bee/utils"
+)
+
+// checkSource fails the test if src has placeholders left, is not valid Go,
+// uses a package it does not import or imports a package it does not use
+func checkSource(t *testing.T, name, src string) {
+	t.Helper()
+	if i := strings.Index(src, "{{"); i >= 0 {
+		t.Errorf("%s: placeholder left in the source: %s", name, src[i:i+strings.Index(src[i:], "}}")+2])
+	}
+	if _, err := format.Source([]byte(src)); err != nil {
+		t.Errorf("%s: %s\n%s", name, err, src)
+		return
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, name, src, 0)
+	if err != nil {
+		t.Errorf("%s: %s", name, err)
+		return
+	}
+	used := make(map[string]bool)
+	for _, imp := range f.Imports {
+		p, _ := strconv.Unquote(imp.Path.Value)
+		pkg := path.Base(p)
+		if imp.Name != nil {
+			pkg = imp.Name.Name
+		}
+		if pkg != "_" {
+			used[pkg] = false
+		}
+	}
+	ast.Inspect(f, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if id, ok := sel.X.(*ast.Ident); ok && id.Obj == nil {
+				if _, ok := used[id.Name]; !ok {
+					t.Errorf("%s: undefined: %s", fset.Position(id.Pos()), id.Name)
+				}
+				used[id.Name] = true
+			}
+		}
+		return true
+	})
+	for pkg, ok := range used {
+		if !ok {
+			t.Errorf("%s: %q imported and not used", name, pkg)
+		}
+	}
+}
+
+// keyColumn returns a column of a table read from a database
+func keyColumn(name, typ string) *Column {
+	return &Column{Name: utils.CamelCase(name), Type: typ, Tag: &OrmTag{Column: name}}
+}
+
+func orderItemsTable(extra ...*Column) *Table {
+	return &Table{
+		Name:    "order_items",
+		Pks:     []string{"order_id", "sku"},
+		Columns: append([]*Column{keyColumn("order_id", "int"), keyColumn("sku", "string")}, extra...),
+	}
+}
+
+func TestControllerTables(t *testing.T) {
+	single := &Table{Name: "user", Pk: "id"}
+	composite := orderItemsTable()
+	join := &Table{Name: "user_tags", JoinTable: true}
+	noKey := &Table{Name: "log"}
+	temporal := &Table{
+		Name:    "readings",
+		Pks:     []string{"sensor", "taken_at"},
+		Columns: []*Column{keyColumn("sensor", "string"), keyColumn("taken_at", "time.Time")},
+	}
+
+	got := controllerTables([]*Table{single, composite, join, noKey, temporal})
+	if want := []*Table{single, composite}; !reflect.DeepEqual(got, want) {
+		var names []string
+		for _, tb := range got {
+			names = append(names, tb.Name)
+		}
+		t.Errorf("controller tables %v, want [user order_items]", names)
+	}
+}
+
+func TestCompositeKeyCode(t *testing.T) {
+	tests := []struct {
+		name       string
+		tb         *Table
+		wantModel  []string
+		wantCtrl   []string
+		ignoreCtrl []string
+	}{
+		{
+			name: "key and columns",
+			tb:   orderItemsTable(keyColumn("quantity", "int")),
+			wantModel: []string{
+				"func GetOrderItemsByKey(orderId int, sku string) (v *OrderItems, err error) {",
+				"WHERE order_id = ? AND sku = ?",
+				"SET quantity = ?",
+			},
+			wantCtrl: []string{
+				`c.Mapping("Put", c.Put)`,
+				`fmt.Sscan(c.Ctx.Input.Param(":order_id"), &orderId)`,
+				`sku = c.Ctx.Input.Param(":sku")`,
+			},
+		},
+		{
+			name: "key only",
+			tb:   orderItemsTable(),
+			wantModel: []string{
+				"func GetOrderItemsByKey(orderId int, sku string) (v *OrderItems, err error) {",
+			},
+			ignoreCtrl: []string{`c.Mapping("Put", c.Put)`, "func UpdateOrderItemsByKey"},
+		},
+	}
+	for _, tt := range tests {
+		model := strings.Replace(compositeModel(tt.tb), "{{modelStruct}}", tt.tb.String(), 1)
+		model = strings.Replace(model, "{{modelName}}", "OrderItems", -1)
+		model = strings.Replace(model, "{{tableName}}", tt.tb.Name, -1)
+		model = strings.Replace(model, "{{packageName}}", "models", -1)
+		model = strings.Replace(model, "{{timePkg}}", "", -1)
+		checkSource(t, tt.name+" model", model)
+
+		ctrl := strings.Replace(compositeController(tt.tb), "{{pkgPath}}", "app/models", -1)
+		checkSource(t, tt.name+" controller", ctrl)
+
+		for _, w := range tt.wantModel {
+			if !strings.Contains(model, w) {
+				t.Errorf("%s: model does not contain %s", tt.name, w)
+			}
+		}
+		for _, w := range tt.wantCtrl {
+			if !strings.Contains(ctrl, w) {
+				t.Errorf("%s: controller does not contain %s", tt.name, w)
+			}
+		}
+		for _, w := range tt.ignoreCtrl {
+			if strings.Contains(model, w) || strings.Contains(ctrl, w) {
+				t.Errorf("%s: source contains %s", tt.name, w)
+			}
+		}
+	}
+}
+
+func TestCustomRegionsOfGeneratedFiles(t *testing.T) {
+	tb := orderItemsTable(keyColumn("quantity", "int"))
+	if strings.Contains(tb.String(), "bee:custom") {
+		t.Errorf("struct has a custom region:\n%s", tb.String())
+	}
+	if !strings.HasSuffix(tb.customString(), "\n// bee:custom begin fields\n// bee:custom end\n}\n") {
+		t.Errorf("struct has no fields region:\n%s", tb.customString())
+	}
+
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	writeRouterFile([]*Table{tb}, dir, "app")
+	router, err := ioutil.ReadFile(filepath.Join(dir, "router.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSource(t, "router", string(router))
+
+	custom := strings.Replace(string(router), "// bee:custom begin routes\n", "// bee:custom begin routes\n\tbeego.Router(\"/health\", &controllers.HealthController{})\n", 1)
+	got, orphans, err := utils.KeepCustomRegions(strings.Replace(RouterTPL, "{{pkgPath}}", "app", 1), custom)
+	if err != nil || len(orphans) != 0 || !strings.Contains(got, "beego.Router(\"/health\"") {
+		t.Errorf("router custom regions not kept (orphans %v, %v):\n%s", orphans, err, got)
+	}
+}
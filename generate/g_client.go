@@ -0,0 +1,613 @@
+// Copyright 2013 bee authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package generate
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/astaxie/beego/swagger"
+	beeLogger "github.com/beego/bee/logger"
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	// tsIdentifier matches the names usable as TypeScript identifiers and unquoted property names
+	tsIdentifier = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+	tsSeparators = regexp.MustCompile(`[^A-Za-z0-9]+`)
+
+	// tsGlobals are the global types a definition must not shadow
+	tsGlobals = map[string]bool{
+		"Array": true, "ArrayBuffer": true, "Blob": true, "Boolean": true, "BodyInit": true, "Date": true,
+		"Error": true, "File": true, "FormData": true, "Function": true, "Headers": true, "Map": true,
+		"Number": true, "Object": true, "Promise": true, "Record": true, "Request": true, "Response": true,
+		"Set": true, "String": true, "Symbol": true, "URL": true, "URLSearchParams": true,
+		"ApiError": true, "ClientConfig": true, "Credentials": true, "Security": true, "SecurityScheme": true,
+	}
+)
+
+// tsClient renders the TypeScript client of a swagger spec
+type tsClient struct {
+	spec  *swagger.Swagger
+	names map[string]string // TypeScript names of the definitions
+}
+
+// GenerateClient generates the API client of the swagger spec written by
+// bee generate docs, i.e. swagger/swagger.json, in client/api.ts
+func GenerateClient(lang, specFile, currpath string) {
+	if lang != "ts" {
+		beeLogger.Log.Fatalf("Unknown client language '%s'. Must be ts", lang)
+	}
+	if specFile == "" {
+		specFile = path.Join(currpath, "swagger", "swagger.json")
+	}
+	src, err := ioutil.ReadFile(specFile)
+	if err != nil {
+		beeLogger.Log.Hint("Generate the swagger spec first, i.e. bee generate docs")
+		beeLogger.Log.Fatalf("Could not read swagger spec: %s", err)
+	}
+	spec := new(swagger.Swagger)
+	if strings.HasSuffix(specFile, ".yml") || strings.HasSuffix(specFile, ".yaml") {
+		err = yaml.Unmarshal(src, spec)
+	} else {
+		err = json.Unmarshal(src, spec)
+	}
+	if err != nil {
+		beeLogger.Log.Fatalf("Could not parse swagger spec '%s': %s", specFile, err)
+	}
+
+	c := &tsClient{spec: spec, names: tsDefinitionNames(spec.Definitions)}
+	fileStr := strings.Replace(TSClientTPL, "{{specFile}}", path.Base(specFile), -1)
+	fileStr = strings.Replace(fileStr, "{{baseUrl}}", c.baseURL(), -1)
+	fileStr = strings.Replace(fileStr, "{{basePath}}", strings.TrimSuffix(spec.BasePath, "/"), -1)
+	fileStr = strings.Replace(fileStr, "{{securityDefinitions}}", c.securityDefinitions(), -1)
+	fileStr = strings.Replace(fileStr, "{{definitions}}", c.definitions(), -1)
+	fileStr = strings.Replace(fileStr, "{{operations}}", c.operations(), -1)
+
+	clientPath := path.Join(currpath, "client")
+	if err := os.MkdirAll(clientPath, 0777); err != nil {
+		beeLogger.Log.Fatalf("Could not create client directory: %s", err)
+	}
+	writeGeneratedFile(path.Join(clientPath, "api.ts"), fileStr)
+}
+
+// tsDefinitionNames names the definitions after their type, i.e. Book for models.Book,
+// or after their whole name when two packages have a type of the same name or the
+// type is a global one, i.e. ModelsObject for models.Object
+func tsDefinitionNames(definitions map[string]swagger.Schema) map[string]string {
+	count := make(map[string]int)
+	for name := range definitions {
+		count[tsTypeName(name[strings.LastIndex(name, ".")+1:])]++
+	}
+	names := make(map[string]string)
+	for name := range definitions {
+		short := tsTypeName(name[strings.LastIndex(name, ".")+1:])
+		switch {
+		case count[short] == 1 && !tsGlobals[short]:
+			names[name] = short
+		case tsGlobals[tsTypeName(name)]:
+			names[name] = tsTypeName(name) + "Type"
+		default:
+			names[name] = tsTypeName(name)
+		}
+	}
+	return names
+}
+
+// tsTypeName returns name as a TypeScript type name, i.e. ModelsBook for models.Book
+func tsTypeName(name string) string {
+	var out string
+	for _, w := range tsSeparators.Split(name, -1) {
+		if w != "" {
+			out += strings.ToUpper(w[:1]) + w[1:]
+		}
+	}
+	if out == "" || (out[0] >= '0' && out[0] <= '9') {
+		out = "T" + out
+	}
+	return out
+}
+
+// tsFuncName returns name as the name of a TypeScript function, i.e. bookGetOne for BookController.GetOne
+func tsFuncName(name string) string {
+	name = strings.Replace(name, "Controller.", ".", 1)
+	name = tsTypeName(name)
+	return strings.ToLower(name[:1]) + name[1:]
+}
+
+// tsProperty returns name as a property name, quoted when it is no identifier
+func tsProperty(name string) string {
+	if tsIdentifier.MatchString(name) {
+		return name
+	}
+	return strconv.Quote(name)
+}
+
+// tsComment returns the doc comment of a description, indented by indent
+func tsComment(description, indent string) string {
+	description = strings.TrimSpace(strings.Replace(description, "*/", "* /", -1))
+	if description == "" {
+		return ""
+	}
+	return indent + "/** " + strings.Replace(description, "\n", "\n"+indent+" * ", -1) + " */\n"
+}
+
+// baseURL returns the scheme and host of the spec, empty to send the requests to the page origin
+func (c *tsClient) baseURL() string {
+	if c.spec.Host == "" {
+		return ""
+	}
+	scheme := "http"
+	if len(c.spec.Schemes) != 0 {
+		scheme = c.spec.Schemes[0]
+	}
+	return scheme + "://" + c.spec.Host
+}
+
+// securityDefinitions returns the security schemes the client applies to the requests
+func (c *tsClient) securityDefinitions() string {
+	var names []string
+	for name := range c.spec.SecurityDefinitions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	b.WriteString("{\n")
+	for _, name := range names {
+		sec := c.spec.SecurityDefinitions[name]
+		switch sec.Type {
+		case "apiKey", "basic", "oauth2":
+		default:
+			beeLogger.Log.Warnf("Security definition '%s' has the unknown type '%s': skipped it", name, sec.Type)
+			continue
+		}
+		fmt.Fprintf(&b, "  %s: { type: %q, name: %q, in: %q },\n", tsProperty(name), sec.Type, sec.Name, sec.In)
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// definitions returns the interfaces and types of the definitions of the spec
+func (c *tsClient) definitions() string {
+	var names []string
+	for name := range c.spec.Definitions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		def := c.spec.Definitions[name]
+		b.WriteString(tsComment(def.Description, ""))
+		if (def.Ref == "" && def.Type == "object") || len(def.Properties) != 0 {
+			fmt.Fprintf(&b, "export interface %s %s\n\n", c.names[name], c.object(def.Properties, def.Required, ""))
+		} else {
+			fmt.Fprintf(&b, "export type %s = %s;\n\n", c.names[name], c.schemaType(&def))
+		}
+	}
+	return b.String()
+}
+
+// refType returns the type of a reference to a definition
+func (c *tsClient) refType(ref string) string {
+	if name, ok := c.names[strings.TrimPrefix(ref, "#/definitions/")]; ok {
+		return name
+	}
+	beeLogger.Log.Warnf("Reference '%s' has no definition: typed it unknown", ref)
+	return "unknown"
+}
+
+// object returns the type of an object with properties, indented by indent
+func (c *tsClient) object(props map[string]swagger.Propertie, required []string, indent string) string {
+	if len(props) == 0 {
+		return "{ [key: string]: unknown }"
+	}
+	var names []string
+	for name := range props {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	b.WriteString("{\n")
+	for _, name := range names {
+		p := props[name]
+		optional := "?"
+		for _, r := range required {
+			if r == name {
+				optional = ""
+			}
+		}
+		b.WriteString(tsComment(p.Description, indent+"  "))
+		fmt.Fprintf(&b, "%s  %s%s: %s;\n", indent, tsProperty(name), optional, c.propertyType(p, indent+"  "))
+	}
+	b.WriteString(indent + "}")
+	return b.String()
+}
+
+// schemaType returns the type of a schema
+func (c *tsClient) schemaType(s *swagger.Schema) string {
+	switch {
+	case s == nil:
+		return "void"
+	case s.Ref != "":
+		return c.refType(s.Ref)
+	case len(s.Enum) != 0:
+		return tsEnum(s.Enum)
+	case s.Type == "array":
+		return tsArray(c.schemaType(s.Items))
+	case s.Type == "object" || len(s.Properties) != 0:
+		return c.object(s.Properties, s.Required, "")
+	}
+	return tsPrimitive(s.Type)
+}
+
+// propertyType returns the type of a property, indented by indent
+func (c *tsClient) propertyType(p swagger.Propertie, indent string) string {
+	switch {
+	case p.Ref != "":
+		return c.refType(p.Ref)
+	case p.Type == "array" && p.Items != nil:
+		return tsArray(c.propertyType(*p.Items, indent))
+	case p.AdditionalProperties != nil:
+		return "{ [key: string]: " + c.propertyType(*p.AdditionalProperties, indent) + " }"
+	case p.Type == "object" || len(p.Properties) != 0:
+		return c.object(p.Properties, p.Required, indent)
+	}
+	return tsPrimitive(p.Type)
+}
+
+// parameterType returns the type of an operation parameter
+func (c *tsClient) parameterType(p swagger.Parameter) string {
+	switch {
+	case p.Schema != nil:
+		return c.schemaType(p.Schema)
+	case p.Type == "array":
+		if p.Items != nil {
+			return tsArray(tsPrimitive(p.Items.Type))
+		}
+		return "unknown[]"
+	case p.Type == "file":
+		return "Blob"
+	}
+	return tsPrimitive(p.Type)
+}
+
+func tsPrimitive(typ string) string {
+	switch typ {
+	case "integer", "number":
+		return "number"
+	case "string":
+		return "string"
+	case "boolean":
+		return "boolean"
+	}
+	return "unknown"
+}
+
+func tsArray(elem string) string {
+	if strings.ContainsAny(elem, " |") {
+		return "Array<" + elem + ">"
+	}
+	return elem + "[]"
+}
+
+func tsEnum(values []interface{}) string {
+	var literals []string
+	for _, v := range values {
+		if lit, err := json.Marshal(v); err == nil {
+			literals = append(literals, string(lit))
+		}
+	}
+	return strings.Join(literals, " | ")
+}
+
+// tsOperation is an operation of the spec
+type tsOperation struct {
+	method string
+	path   string
+	op     *swagger.Operation
+}
+
+// operations returns a client function per operation of the spec
+func (c *tsClient) operations() string {
+	var paths []string
+	for p := range c.spec.Paths {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	var ops []tsOperation
+	for _, p := range paths {
+		item := c.spec.Paths[p]
+		for _, o := range []tsOperation{{"GET", p, item.Get}, {"POST", p, item.Post}, {"PUT", p, item.Put},
+			{"PATCH", p, item.Patch}, {"DELETE", p, item.Delete}, {"HEAD", p, item.Head}, {"OPTIONS", p, item.Options}} {
+			if o.op != nil {
+				ops = append(ops, o)
+			}
+		}
+	}
+
+	var b strings.Builder
+	used := make(map[string]bool)
+	for _, o := range ops {
+		name := ""
+		if o.op.OperationID != "" {
+			name = tsFuncName(o.op.OperationID)
+		} else {
+			name = tsFuncName(strings.ToLower(o.method) + " " + o.path)
+		}
+		for i := 2; used[name]; i++ {
+			name = strings.TrimRight(name, "0123456789") + strconv.Itoa(i)
+		}
+		used[name] = true
+		b.WriteString(c.operation(name, o))
+	}
+	return b.String()
+}
+
+// operation returns the client function of an operation
+func (c *tsClient) operation(name string, o tsOperation) string {
+	var fields, params strings.Builder
+	allOptional := true
+	bodyInit, isForm := "undefined", false
+	for _, p := range o.op.Parameters {
+		if p.In == "formData" {
+			isForm = true
+			if p.Type == "file" {
+				bodyInit = "new FormData()"
+			} else if bodyInit == "undefined" {
+				bodyInit = "new URLSearchParams()"
+			}
+		}
+	}
+	if isForm {
+		params.WriteString("  const form = " + bodyInit + ";\n")
+		bodyInit = "form"
+	}
+
+	url := o.path
+	for _, p := range o.op.Parameters {
+		optional := "?"
+		if p.Required || p.In == "path" {
+			optional = ""
+			allOptional = false
+		}
+		fields.WriteString(tsComment(p.Description, "  "))
+		fmt.Fprintf(&fields, "  %s%s: %s;\n", tsProperty(p.Name), optional, c.parameterType(p))
+		value := "params." + p.Name
+		if !tsIdentifier.MatchString(p.Name) {
+			value = "params[" + strconv.Quote(p.Name) + "]"
+		}
+		switch p.In {
+		case "path":
+			url = strings.Replace(url, "{"+p.Name+"}", "${encodeURIComponent(String("+value+"))}", -1)
+		case "query":
+			fmt.Fprintf(&params, "  addParam(query, %q, %s);\n", p.Name, value)
+		case "header":
+			fmt.Fprintf(&params, "  if (%s !== undefined) {\n    headers[%q] = String(%s);\n  }\n", value, p.Name, value)
+		case "formData":
+			fmt.Fprintf(&params, "  addParam(form, %q, %s);\n", p.Name, value)
+		case "body":
+			fmt.Fprintf(&params, "  headers[\"Content-Type\"] = \"application/json\";\n")
+			bodyInit = "JSON.stringify(" + value + ")"
+		}
+	}
+
+	security := o.op.Security
+	if security == nil {
+		security = c.spec.Security
+	}
+	securityJSON, _ := json.Marshal(security)
+	if security == nil {
+		securityJSON = []byte("[]")
+	}
+
+	signature := ""
+	if fields.Len() != 0 {
+		signature = "params: {\n" + fields.String() + "}"
+		if allOptional {
+			signature += " = {}"
+		}
+	}
+	result := c.resultType(o.op)
+	summary := o.op.Summary
+	if o.op.Description != "" && o.op.Description != summary {
+		summary = strings.TrimSpace(summary + "\n" + o.op.Description)
+	}
+
+	fn := strings.Replace(TSOperationTPL, "{{comment}}", tsComment(o.method+" "+o.path+"\n"+summary, ""), -1)
+	fn = strings.Replace(fn, "{{name}}", name, -1)
+	fn = strings.Replace(fn, "{{signature}}", signature, -1)
+	fn = strings.Replace(fn, "{{result}}", result, -1)
+	fn = strings.Replace(fn, "{{params}}", params.String(), -1)
+	fn = strings.Replace(fn, "{{method}}", o.method, -1)
+	fn = strings.Replace(fn, "{{url}}", url, -1)
+	fn = strings.Replace(fn, "{{body}}", bodyInit, -1)
+	fn = strings.Replace(fn, "{{security}}", string(securityJSON), -1)
+	return fn
+}
+
+// resultType returns the type of the body of the successful responses of an operation
+func (c *tsClient) resultType(op *swagger.Operation) string {
+	var codes []string
+	for code := range op.Responses {
+		if strings.HasPrefix(code, "2") {
+			codes = append(codes, code)
+		}
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		if r := op.Responses[code]; r.Schema != nil {
+			return c.schemaType(r.Schema)
+		}
+	}
+	return "void"
+}
+
+const (
+	TSClientTPL = `// Code generated by bee generate client from {{specFile}}.
+// Keep your code between the bee:custom markers, the rest is replaced when
+// the client is generated again.
+
+// bee:custom begin imports
+// bee:custom end
+
+/** Credentials of the security definitions of the API */
+export interface Credentials {
+  /** values of the apiKey security definitions, by definition name */
+  apiKeys?: { [definition: string]: string };
+  /** basic authentication */
+  username?: string;
+  password?: string;
+  /** bearer token of the oauth2 security definitions */
+  accessToken?: string;
+}
+
+export interface ClientConfig {
+  /** scheme and host the requests are sent to, empty for the origin of the page */
+  baseUrl: string;
+  credentials: Credentials;
+  /** headers added to every request */
+  headers: { [name: string]: string };
+  fetch?: typeof fetch;
+}
+
+/** config is used by every request of the client */
+export const config: ClientConfig = {
+  baseUrl: "{{baseUrl}}",
+  credentials: {},
+  headers: {},
+};
+
+export const basePath = "{{basePath}}";
+
+/** ApiError is thrown for the responses whose status is not 2xx */
+export class ApiError extends Error {
+  status: number;
+  body: unknown;
+
+  constructor(status: number, body: unknown) {
+    super("request failed with status " + status);
+    this.status = status;
+    this.body = body;
+  }
+}
+
+interface SecurityScheme {
+  type: string;
+  name: string;
+  in: string;
+}
+
+const securityDefinitions: { [name: string]: SecurityScheme } = {{securityDefinitions}};
+
+/** alternative sets of security definitions an operation accepts */
+type Security = Array<{ [definition: string]: string[] }>;
+
+function hasCredentials(name: string): boolean {
+  const scheme = securityDefinitions[name];
+  const c = config.credentials;
+  switch (scheme && scheme.type) {
+    case "apiKey":
+      return !!(c.apiKeys && c.apiKeys[name]);
+    case "basic":
+      return c.username !== undefined;
+    case "oauth2":
+      return !!c.accessToken;
+  }
+  return false;
+}
+
+/** authorize applies the first set of security definitions the credentials allow */
+function authorize(security: Security, query: URLSearchParams, headers: { [name: string]: string }): void {
+  const c = config.credentials;
+  for (const requirement of security) {
+    const names = Object.keys(requirement);
+    if (!names.every(hasCredentials)) {
+      continue;
+    }
+    for (const name of names) {
+      const scheme = securityDefinitions[name];
+      if (scheme.type === "apiKey") {
+        const key = (c.apiKeys as { [definition: string]: string })[name];
+        if (scheme.in === "query") {
+          query.set(scheme.name, key);
+        } else {
+          headers[scheme.name] = key;
+        }
+      } else if (scheme.type === "basic") {
+        headers["Authorization"] = "Basic " + btoa(c.username + ":" + (c.password || ""));
+      } else {
+        headers["Authorization"] = "Bearer " + c.accessToken;
+      }
+    }
+    return;
+  }
+}
+
+function addParam(params: URLSearchParams | FormData, name: string, value: unknown): void {
+  if (value === undefined || value === null) {
+    return;
+  }
+  if (value instanceof Blob && params instanceof FormData) {
+    params.append(name, value);
+  } else {
+    params.append(name, Array.isArray(value) ? value.join(",") : String(value));
+  }
+}
+
+async function request<T>(
+  method: string,
+  path: string,
+  query: URLSearchParams,
+  headers: { [name: string]: string },
+  body: BodyInit | undefined,
+  security: Security,
+): Promise<T> {
+  authorize(security, query, headers);
+  const qs = query.toString();
+  const res = await (config.fetch || fetch)(config.baseUrl + basePath + path + (qs ? "?" + qs : ""), {
+    method,
+    headers: { ...config.headers, ...headers },
+    body,
+  });
+  const text = await res.text();
+  let data: unknown = text;
+  if (text && (res.headers.get("Content-Type") || "").indexOf("json") >= 0) {
+    data = JSON.parse(text);
+  }
+  if (!res.ok) {
+    throw new ApiError(res.status, data);
+  }
+  return (text ? data : undefined) as T;
+}
+
+{{definitions}}{{operations}}// bee:custom begin functions
+// bee:custom end
+`
+
+	TSOperationTPL = `{{comment}}export function {{name}}({{signature}}): Promise<{{result}}> {
+  const query = new URLSearchParams();
+  const headers: { [name: string]: string } = {};
+{{params}}  return request<{{result}}>("{{method}}", ` + "`{{url}}`" + `, query, headers, {{body}}, {{security}});
+}
+
+`
+)
@@ -0,0 +1,168 @@
+// Copyright 2013 bee authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package generate
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/astaxie/beego/swagger"
+)
+
+const clientSpec = `{
+  "swagger": "2.0",
+  "host": "api.example.com",
+  "schemes": ["https"],
+  "basePath": "/v1/",
+  "securityDefinitions": {
+    "api_key": {"type": "apiKey", "name": "X-Api-Key", "in": "header"}
+  },
+  "security": [{"api_key": []}],
+  "paths": {
+    "/book/": {
+      "get": {
+        "operationId": "BookController.GetAll",
+        "summary": "get Book",
+        "parameters": [
+          {"in": "query", "name": "limit", "type": "integer"},
+          {"in": "query", "name": "sort-by", "type": "string"}
+        ],
+        "responses": {"200": {"schema": {"type": "array", "items": {"$ref": "#/definitions/models.Book"}}}}
+      },
+      "post": {
+        "operationId": "BookController.Post",
+        "parameters": [{"in": "body", "name": "body", "required": true, "schema": {"$ref": "#/definitions/models.Book"}}],
+        "responses": {"201": {"schema": {"$ref": "#/definitions/models.Book"}}}
+      }
+    },
+    "/book/{id}": {
+      "get": {
+        "operationId": "BookController.GetOne",
+        "parameters": [{"in": "path", "name": "id", "required": true, "type": "integer"}],
+        "responses": {"200": {"schema": {"$ref": "#/definitions/models.Book"}}}
+      },
+      "delete": {
+        "security": [],
+        "parameters": [{"in": "path", "name": "id", "required": true, "type": "integer"}],
+        "responses": {"200": {"description": "deleted"}}
+      }
+    }
+  },
+  "definitions": {
+    "models.Book": {
+      "title": "Book",
+      "type": "object",
+      "description": "Book is a book",
+      "required": ["title"],
+      "properties": {
+        "id": {"type": "integer", "format": "int64"},
+        "title": {"type": "string"},
+        "state": {"$ref": "#/definitions/models.State"},
+        "author": {"$ref": "#/definitions/models.Object"},
+        "tags": {"type": "array", "items": {"type": "string"}}
+      }
+    },
+    "models.Object": {"title": "Object", "type": "object"},
+    "models.State": {"title": "State", "type": "string", "enum": ["draft", "published"]}
+  }
+}
+`
+
+func TestGenerateClient(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	writeFile(t, dir, "swagger/swagger.json", clientSpec)
+	GenerateClient("ts", "", dir)
+	content, err := ioutil.ReadFile(filepath.Join(dir, "client", "api.ts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(content)
+	if i := strings.Index(src, "{{"); i >= 0 {
+		t.Errorf("placeholder left in the client: %s", src[i:])
+	}
+	want := []string{
+		"// Code generated by bee generate client from swagger.json.\n",
+		`baseUrl: "https://api.example.com",`,
+		`export const basePath = "/v1";`,
+		`api_key: { type: "apiKey", name: "X-Api-Key", in: "header" },`,
+		"/** Book is a book */\nexport interface Book {\n  author?: ModelsObject;\n  id?: number;\n  state?: State;\n  tags?: string[];\n  title: string;\n}\n",
+		"export interface ModelsObject { [key: string]: unknown }\n",
+		`export type State = "draft" | "published";`,
+		"export function bookGetAll(params: {\n  limit?: number;\n  \"sort-by\"?: string;\n} = {}): Promise<Book[]> {",
+		`addParam(query, "sort-by", params["sort-by"]);`,
+		"return request<Book>(\"POST\", `/book/`, query, headers, JSON.stringify(params.body), [{\"api_key\":[]}]);",
+		"export function bookGetOne(params: {\n  id: number;\n}): Promise<Book> {",
+		"`/book/${encodeURIComponent(String(params.id))}`",
+		// an operation without id is named after its method and path, and its empty security overrides the spec's
+		"export function deleteBookId(params: {\n  id: number;\n}): Promise<void> {",
+		"query, headers, undefined, []);",
+	}
+	for _, w := range want {
+		if !strings.Contains(src, w) {
+			t.Errorf("client does not contain %s", w)
+		}
+	}
+}
+
+func TestTSDefinitionNames(t *testing.T) {
+	definitions := map[string]swagger.Schema{
+		"models.Book":    {},
+		"models.Author":  {},
+		"other.Author":   {},
+		"models.Object":  {},
+		"models.Promise": {},
+		"Promise":        {},
+		"models.2fa":     {},
+	}
+	want := map[string]string{
+		"models.Book":    "Book",
+		"models.Author":  "ModelsAuthor",
+		"other.Author":   "OtherAuthor",
+		"models.Object":  "ModelsObject",
+		"models.Promise": "ModelsPromise",
+		"Promise":        "PromiseType",
+		"models.2fa":     "T2fa",
+	}
+	if got := tsDefinitionNames(definitions); !reflect.DeepEqual(got, want) {
+		t.Errorf("tsDefinitionNames = %v, want %v", got, want)
+	}
+}
+
+func TestTSNames(t *testing.T) {
+	tests := []struct {
+		f        func(string) string
+		in, want string
+	}{
+		{tsTypeName, "models.Book", "ModelsBook"},
+		{tsTypeName, "my-app.user_role", "MyAppUserRole"},
+		{tsTypeName, "", "T"},
+		{tsFuncName, "BookController.GetOne", "bookGetOne"},
+		{tsFuncName, "get /book/{id}", "getBookId"},
+		{tsProperty, "title", "title"},
+		{tsProperty, "sort-by", `"sort-by"`},
+		{tsProperty, "2fa", `"2fa"`},
+		{func(s string) string { return tsComment(s, "  ") }, "", ""},
+		{func(s string) string { return tsComment(s, "  ") }, "first\nsecond */", "  /** first\n   * second * / */\n"},
+	}
+	for _, tt := range tests {
+		if got := tt.f(tt.in); got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"strconv"
 	"strings"
 
 	beeLogger "github.com/beego/bee/logger"
@@ -57,7 +58,17 @@ func GenerateController(cname, currpath string) {
 		var content string
 		if _, err := os.Stat(modelPath); err == nil {
 			beeLogger.Log.Infof("Using matching model '%s'", controllerName)
-			content = strings.Replace(controllerModelTpl, "{{packageName}}", packageName, -1)
+			tpl := controllerModelTpl
+			if utils.IsExist(path.Join(currpath, "views", cname, "index.tpl")) {
+				if fields := formFields(controllerName, currpath); fields != "" {
+					beeLogger.Log.Infof("Using matching views '%s'", cname)
+					tpl = strings.Replace(controllerViewTpl, "{{viewPath}}", cname, -1)
+					tpl = strings.Replace(tpl, "{{formFields}}", fields, -1)
+				} else {
+					beeLogger.Log.Warnf("Views '%s' edit no field of model '%s', they are not served", cname, controllerName)
+				}
+			}
+			content = strings.Replace(tpl, "{{packageName}}", packageName, -1)
 			pkgPath := getPackagePath(currpath)
 			content = strings.Replace(content, "{{pkgPath}}", pkgPath, -1)
 		} else {
@@ -75,6 +86,15 @@ func GenerateController(cname, currpath string) {
 	}
 }
 
+// formFields returns the quoted names of the model fields the generated forms edit
+func formFields(modelName, currpath string) string {
+	var names []string
+	for _, f := range viewFields(modelName, "", currpath) {
+		names = append(names, strconv.Quote(f.Name))
+	}
+	return strings.Join(names, ", ")
+}
+
 var controllerTpl = `package {{packageName}}
 
 import (
@@ -323,3 +343,291 @@ func (c *{{controllerName}}Controller) Delete() {
 	c.ServeJSON()
 }
 `
+
+// controllerViewTpl serves the views generated by 'bee generate view' to browsers,
+// and JSON to other clients like controllerModelTpl
+var controllerViewTpl = `package {{packageName}}
+
+import (
+	"{{pkgPath}}/models"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"strconv"
+	"strings"
+
+	"github.com/astaxie/beego"
+	"github.com/astaxie/beego/orm"
+	"github.com/astaxie/beego/utils/pagination"
+	"github.com/astaxie/beego/validation"
+)
+
+//  {{controllerName}}Controller operations for {{controllerName}}. Browsers are served the views of {{viewPath}}.
+type {{controllerName}}Controller struct {
+	beego.Controller
+}
+
+// URLMapping ...
+func (c *{{controllerName}}Controller) URLMapping() {
+	c.Mapping("Post", c.Post)
+	c.Mapping("GetOne", c.GetOne)
+	c.Mapping("GetAll", c.GetAll)
+	c.Mapping("New", c.New)
+	c.Mapping("Edit", c.Edit)
+	c.Mapping("Put", c.Put)
+	c.Mapping("Delete", c.Delete)
+}
+
+// Post ...
+// @Title Post
+// @Description create {{controllerName}}
+// @Param	body		body 	models.{{controllerName}}	true		"body for {{controllerName}} content"
+// @Success 201 {int} models.{{controllerName}}
+// @Failure 403 body is empty
+// @router / [post]
+func (c *{{controllerName}}Controller) Post() {
+	var v models.{{controllerName}}
+	if c.Ctx.Input.AcceptsHTML() {
+		if c.parseForm(&v, "create.tpl") {
+			if _, err := models.Add{{controllerName}}(&v); err != nil {
+				c.renderForm("create.tpl", &v, nil, err)
+				return
+			}
+			c.Redirect(c.URLFor("{{controllerName}}Controller.GetOne", ":id", v.Id), 302)
+		}
+		return
+	}
+	json.Unmarshal(c.Ctx.Input.RequestBody, &v)
+	if _, err := models.Add{{controllerName}}(&v); err == nil {
+		c.Ctx.Output.SetStatus(201)
+		c.Data["json"] = v
+	} else {
+		c.Data["json"] = err.Error()
+	}
+	c.ServeJSON()
+}
+
+// GetOne ...
+// @Title Get One
+// @Description get {{controllerName}} by id
+// @Param	id		path 	string	true		"The key for staticblock"
+// @Success 200 {object} models.{{controllerName}}
+// @Failure 403 :id is empty
+// @router /:id [get]
+func (c *{{controllerName}}Controller) GetOne() {
+	idStr := c.Ctx.Input.Param(":id")
+	id, _ := strconv.ParseInt(idStr, 0, 64)
+	v, err := models.Get{{controllerName}}ById(id)
+	if c.Ctx.Input.AcceptsHTML() {
+		if err != nil {
+			c.Abort("404")
+		}
+		c.Data["Item"] = v
+		c.TplName = "{{viewPath}}/show.tpl"
+		return
+	}
+	if err != nil {
+		c.Data["json"] = err.Error()
+	} else {
+		c.Data["json"] = v
+	}
+	c.ServeJSON()
+}
+
+// GetAll ...
+// @Title Get All
+// @Description get {{controllerName}}
+// @Param	query	query	string	false	"Filter. e.g. col1:v1,col2:v2 ..."
+// @Param	fields	query	string	false	"Fields returned. e.g. col1,col2 ..."
+// @Param	sortby	query	string	false	"Sorted-by fields. e.g. col1,col2 ..."
+// @Param	order	query	string	false	"Order corresponding to each sortby field, if single value, apply to all sortby fields. e.g. desc,asc ..."
+// @Param	limit	query	string	false	"Limit the size of result set. Must be an integer"
+// @Param	offset	query	string	false	"Start position of result set. Must be an integer"
+// @Success 200 {object} models.{{controllerName}}
+// @Failure 403
+// @router / [get]
+func (c *{{controllerName}}Controller) GetAll() {
+	var fields []string
+	var sortby []string
+	var order []string
+	var query = make(map[string]string)
+	var limit int64 = 10
+	var offset int64
+
+	// fields: col1,col2,entity.col3
+	if v := c.GetString("fields"); v != "" {
+		fields = strings.Split(v, ",")
+	}
+	// limit: 10 (default is 10)
+	if v, err := c.GetInt64("limit"); err == nil {
+		limit = v
+	}
+	// offset: 0 (default is 0)
+	if v, err := c.GetInt64("offset"); err == nil {
+		offset = v
+	}
+	// sortby: col1,col2
+	if v := c.GetString("sortby"); v != "" {
+		sortby = strings.Split(v, ",")
+	}
+	// order: desc,asc
+	if v := c.GetString("order"); v != "" {
+		order = strings.Split(v, ",")
+	}
+	// query: k:v,k:v
+	if v := c.GetString("query"); v != "" {
+		for _, cond := range strings.Split(v, ",") {
+			kv := strings.SplitN(cond, ":", 2)
+			if len(kv) != 2 {
+				c.Data["json"] = errors.New("Error: invalid query key/value pair")
+				c.ServeJSON()
+				return
+			}
+			k, v := kv[0], kv[1]
+			query[k] = v
+		}
+	}
+
+	html := c.Ctx.Input.AcceptsHTML()
+	if html {
+		// page: 1 (the offset of the page replaces offset)
+		qs := orm.NewOrm().QueryTable(new(models.{{controllerName}}))
+		for k, v := range query {
+			qs = qs.Filter(strings.Replace(k, ".", "__", -1), v)
+		}
+		count, _ := qs.Count()
+		offset = int64(pagination.SetPaginator(c.Ctx, int(limit), count).Offset())
+	}
+	l, err := models.GetAll{{controllerName}}(query, fields, sortby, order, offset, limit)
+	if html {
+		if err != nil {
+			c.Abort("500")
+		}
+		c.Data["Items"] = l
+		c.Data["xsrfdata"] = template.HTML(c.XSRFFormHTML())
+		c.TplName = "{{viewPath}}/index.tpl"
+		return
+	}
+	if err != nil {
+		c.Data["json"] = err.Error()
+	} else {
+		c.Data["json"] = l
+	}
+	c.ServeJSON()
+}
+
+// New shows the form creating a {{controllerName}}
+// @router /new [get]
+func (c *{{controllerName}}Controller) New() {
+	c.renderForm("create.tpl", &models.{{controllerName}}{}, nil, nil)
+}
+
+// Edit shows the form updating a {{controllerName}}
+// @router /:id/edit [get]
+func (c *{{controllerName}}Controller) Edit() {
+	idStr := c.Ctx.Input.Param(":id")
+	id, _ := strconv.ParseInt(idStr, 0, 64)
+	v, err := models.Get{{controllerName}}ById(id)
+	if err != nil {
+		c.Abort("404")
+	}
+	c.renderForm("edit.tpl", v, nil, nil)
+}
+
+// Put ...
+// @Title Put
+// @Description update the {{controllerName}}
+// @Param	id		path 	string	true		"The id you want to update"
+// @Param	body		body 	models.{{controllerName}}	true		"body for {{controllerName}} content"
+// @Success 200 {object} models.{{controllerName}}
+// @Failure 403 :id is not int
+// @router /:id/edit [post]
+// @router /:id [put]
+func (c *{{controllerName}}Controller) Put() {
+	idStr := c.Ctx.Input.Param(":id")
+	id, _ := strconv.ParseInt(idStr, 0, 64)
+	v := models.{{controllerName}}{Id: id}
+	if c.Ctx.Input.AcceptsHTML() {
+		if _, err := models.Get{{controllerName}}ById(id); err != nil {
+			c.Abort("404")
+		}
+		if c.parseForm(&v, "edit.tpl") {
+			// the form leaves out the relations, so only its fields are updated
+			if _, err := orm.NewOrm().Update(&v, {{formFields}}); err != nil {
+				c.renderForm("edit.tpl", &v, nil, err)
+				return
+			}
+			c.Redirect(c.URLFor("{{controllerName}}Controller.GetOne", ":id", v.Id), 302)
+		}
+		return
+	}
+	json.Unmarshal(c.Ctx.Input.RequestBody, &v)
+	if err := models.Update{{controllerName}}ById(&v); err == nil {
+		c.Data["json"] = "OK"
+	} else {
+		c.Data["json"] = err.Error()
+	}
+	c.ServeJSON()
+}
+
+// Delete ...
+// @Title Delete
+// @Description delete the {{controllerName}}
+// @Param	id		path 	string	true		"The id you want to delete"
+// @Success 200 {string} delete success!
+// @Failure 403 id is empty
+// @router /:id/delete [post]
+// @router /:id [delete]
+func (c *{{controllerName}}Controller) Delete() {
+	idStr := c.Ctx.Input.Param(":id")
+	id, _ := strconv.ParseInt(idStr, 0, 64)
+	err := models.Delete{{controllerName}}(id)
+	if c.Ctx.Input.AcceptsHTML() {
+		if err != nil {
+			c.Abort("404")
+		}
+		c.Redirect(c.URLFor("{{controllerName}}Controller.GetAll"), 302)
+		return
+	}
+	if err == nil {
+		c.Data["json"] = "OK"
+	} else {
+		c.Data["json"] = err.Error()
+	}
+	c.ServeJSON()
+}
+
+// parseForm fills v with the submitted form and validates it with its valid tags.
+// The form is shown again with the errors when it is invalid.
+func (c *{{controllerName}}Controller) parseForm(v *models.{{controllerName}}, tpl string) bool {
+	if err := c.ParseForm(v); err != nil {
+		c.renderForm(tpl, v, nil, err)
+		return false
+	}
+	valid := validation.Validation{}
+	ok, err := valid.Valid(v)
+	if err != nil || !ok {
+		errs := make(map[string]string)
+		for _, e := range valid.Errors {
+			errs[e.Field] = e.Message
+		}
+		c.renderForm(tpl, v, errs, err)
+		return false
+	}
+	return true
+}
+
+// renderForm shows a form with the validation errors of its fields
+func (c *{{controllerName}}Controller) renderForm(tpl string, v *models.{{controllerName}}, errs map[string]string, err error) {
+	if errs == nil {
+		errs = make(map[string]string)
+	}
+	if err != nil {
+		c.Data["Error"] = err.Error()
+	}
+	c.Data["Item"] = v
+	c.Data["Errors"] = errs
+	c.Data["xsrfdata"] = template.HTML(c.XSRFFormHTML())
+	c.TplName = "{{viewPath}}/" + tpl
+}
+`
@@ -0,0 +1,679 @@
+// Copyright 2013 bee authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package generate
+
+import (
+	"database/sql"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	beeLogger "github.com/beego/bee/logger"
+	"github.com/beego/bee/utils"
+)
+
+// bookkeepingTables are the tables managed by bee migrate, never part of a diff
+var bookkeepingTables = map[string]bool{
+	"migrations":          true,
+	"migration_checksums": true,
+	"migrations_lock":     true,
+	"seeds":               true,
+}
+
+// schemaDiffer is implemented by the DBDrivers that can render a schema diff
+type schemaDiffer interface {
+	quote(name string) string
+	columnType(col *Column) string
+	modifyColumn(table string, col *Column) []string
+}
+
+// GenerateDiffMigration writes a migration bringing the database in line with
+// the beego orm models of the application
+func GenerateDiffMigration(mname, driver, connStr, currpath string) {
+	differ, ok := schemaDiffers[driver]
+	if !ok {
+		beeLogger.Log.Fatalf("Generating a schema diff for '%s' is not supported", driver)
+	}
+	trans, ok := dbDriver[driver]
+	if !ok {
+		beeLogger.Log.Fatalf("Generating a schema diff for '%s' is not supported", driver)
+	}
+
+	models := parseModels(path.Join(currpath, "models"))
+	if len(models) == 0 {
+		beeLogger.Log.Fatal("No models registered with orm.RegisterModel found in the models directory")
+	}
+
+	db, err := sql.Open(sqlDriverName(driver), connStr)
+	if err != nil {
+		beeLogger.Log.Fatalf("Could not connect to '%s' database using '%s': %s", driver, connStr, err)
+	}
+	defer db.Close()
+	beeLogger.Log.Info("Analyzing database tables...")
+	live := make(map[string]*Table)
+	for _, tb := range liveTables(db, trans) {
+		live[tb.Name] = tb
+	}
+
+	up, down := diffSchema(differ, models, live)
+	if len(up) == 0 {
+		beeLogger.Log.Info("The database is already in line with the models. No migration generated")
+		os.Exit(0)
+	}
+	upsql, downsql := "", ""
+	for _, s := range up {
+		upsql += "m.SQL(" + strconv.Quote(s) + ")\n"
+	}
+	for i := len(down) - 1; i >= 0; i-- {
+		downsql += "m.SQL(" + strconv.Quote(down[i]) + ")\n"
+	}
+	GenerateMigration(mname, upsql, downsql, currpath)
+}
+
+// diffSchema returns the statements turning the live tables into the models and the join
+// tables of their m2m relations, and the statements reverting each of them. Tables without a
+// model are left alone. Foreign key constraints and indexes are not compared.
+func diffSchema(d schemaDiffer, models []*Table, live map[string]*Table) (up, down []string) {
+	for _, model := range append(models, m2mTables(models)...) {
+		tb, ok := live[model.Name]
+		if !ok {
+			var defs []string
+			for _, col := range model.Columns {
+				defs = append(defs, columnDef(d, col))
+			}
+			up = append(up, fmt.Sprintf("CREATE TABLE %s (%s)", d.quote(model.Name), strings.Join(defs, ", ")))
+			down = append(down, "DROP TABLE "+d.quote(model.Name))
+			continue
+		}
+		liveCols := make(map[string]*Column)
+		for _, col := range tb.Columns {
+			liveCols[col.Tag.Column] = col
+		}
+		modelCols := make(map[string]bool)
+		for _, col := range model.Columns {
+			modelCols[col.Tag.Column] = true
+			liveCol, ok := liveCols[col.Tag.Column]
+			if !ok {
+				up = append(up, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", d.quote(model.Name), columnDef(d, col)))
+				down = append(down, fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", d.quote(model.Name), d.quote(col.Tag.Column)))
+			} else if columnChanged(col, liveCol) {
+				upStmts := d.modifyColumn(model.Name, col)
+				if upStmts == nil {
+					beeLogger.Log.Warnf("Column '%s.%s' differs from the model but cannot be altered in place. Skipping it", model.Name, col.Tag.Column)
+					continue
+				}
+				up = append(up, upStmts...)
+				down = append(down, d.modifyColumn(model.Name, liveCol)...)
+			}
+		}
+		for _, col := range tb.Columns {
+			if !modelCols[col.Tag.Column] {
+				beeLogger.Log.Warnf("Column '%s.%s' has no field in the models: the migration drops it with its data", model.Name, col.Tag.Column)
+				up = append(up, fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", d.quote(model.Name), d.quote(col.Tag.Column)))
+				down = append(down, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", d.quote(model.Name), columnDef(d, col)))
+			}
+		}
+	}
+	return
+}
+
+// liveTables returns the tables of a database sorted by name, bookkeeping tables excepted
+func liveTables(db *sql.DB, trans DbTransformer) []*Table {
+	var tableNames []string
+	for _, name := range trans.GetTableNames(db) {
+		if !bookkeepingTables[name] {
+			tableNames = append(tableNames, name)
+		}
+	}
+	sort.Strings(tableNames)
+	tables := getTableObjects(tableNames, db, trans)
+	for _, tb := range tables {
+		for _, col := range tb.Columns {
+			// a string column of unknown size is rendered as text rather than as the default varchar
+			if col.Type == "string" && col.Tag.Size == "" && col.Tag.Type == "" {
+				col.Tag.Type = "text"
+			}
+		}
+	}
+	return tables
+}
+
+// m2mTables returns the join tables the orm creates for the m2m relations of the models,
+// those without rel_through, as named by rel_table or after the tables of both sides
+func m2mTables(models []*Table) (tables []*Table) {
+	byModel := make(map[string]*Table)
+	seen := make(map[string]bool)
+	for _, m := range models {
+		byModel[m.Model] = m
+		seen[m.Name] = true
+	}
+	for _, m := range models {
+		for _, rel := range m.Relations {
+			if !rel.Tag.RelM2M || rel.Tag.RelThrough != "" {
+				continue
+			}
+			related, ok := byModel[strings.TrimLeft(rel.Type, "[]*")]
+			if !ok {
+				beeLogger.Log.Warnf("Field '%s.%s' relates to a model that is not registered: skipped its join table", m.Model, rel.Name)
+				continue
+			}
+			name := rel.Tag.RelTable
+			if name == "" {
+				name = m.Name + "_" + related.Name + "s"
+			}
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			tables = append(tables, &Table{Name: name, Pk: "id", Columns: []*Column{
+				{Name: "Id", Type: "int64", Tag: &OrmTag{Column: "id", Auto: true}},
+				{Name: utils.CamelCase(m.Name), Type: pkType(m), Tag: &OrmTag{Column: m.Name + "_id"}},
+				{Name: utils.CamelCase(related.Name), Type: pkType(related), Tag: &OrmTag{Column: related.Name + "_id"}},
+			}})
+		}
+	}
+	return
+}
+
+// pkType returns the type of the primary key of a model, which the columns referencing it have
+func pkType(tb *Table) string {
+	for _, col := range tb.Columns {
+		if col.Tag.Column == tb.Pk {
+			return col.Type
+		}
+	}
+	return "int"
+}
+
+// columnDef renders the definition of a column as used by CREATE TABLE and ADD COLUMN
+func columnDef(d schemaDiffer, col *Column) string {
+	def := d.quote(col.Tag.Column) + " " + d.columnType(col)
+	if col.Tag.Auto || col.Tag.Pk {
+		return def
+	}
+	if col.Tag.Null {
+		def += " NULL"
+	} else {
+		def += " NOT NULL"
+	}
+	// the orm makes one to one relations unique
+	if col.Tag.Unique || col.Tag.RelOne {
+		def += " UNIQUE"
+	}
+	if col.Tag.Default != "" {
+		def += " DEFAULT " + sqlLiteral(col.Tag.Default, col.Type == "string")
+	}
+	return def
+}
+
+// columnChanged compares a model column with a live one. Only what survives
+// the database introspection is compared: the kind of type, string sizes and nullability.
+func columnChanged(model, live *Column) bool {
+	if model.Tag.Auto || model.Tag.Pk || live.Tag.Auto || live.Tag.Pk {
+		return false
+	}
+	mt, _ := abstractType(model)
+	lt, _ := abstractType(live)
+	if typeFamily(mt) != typeFamily(lt) {
+		return true
+	}
+	if mt == "varchar" && model.Tag.Size != "" && live.Tag.Size != "" && model.Tag.Size != live.Tag.Size {
+		return true
+	}
+	if model.Tag.RelFk || live.Tag.RelFk {
+		return false
+	}
+	return model.Tag.Null != live.Tag.Null
+}
+
+// abstractType classifies a column into the types a schemaDiffer renders
+func abstractType(col *Column) (typ string, unsigned bool) {
+	t := strings.TrimPrefix(col.Type, "*")
+	if col.Tag.Auto {
+		if t == "int64" || t == "uint64" {
+			return "bigauto", false
+		}
+		return "auto", false
+	}
+	if strings.HasPrefix(col.Type, "*") {
+		// foreign keys hold the primary key of the related table
+		if col.KeyType == "" {
+			return "int", false
+		}
+		return abstractType(&Column{Type: col.KeyType, Tag: new(OrmTag)})
+	}
+	unsigned = strings.HasPrefix(t, "uint")
+	switch strings.TrimPrefix(t, "u") {
+	case "int8":
+		return "tinyint", unsigned
+	case "int16":
+		return "smallint", unsigned
+	case "int", "int32":
+		return "int", unsigned
+	case "int64":
+		return "bigint", unsigned
+	case "bool":
+		return "bool", false
+	case "float32":
+		return "float", false
+	case "float64":
+		if col.Tag.Digits != "" {
+			return "decimal", false
+		}
+		return "double", false
+	case "time.Time":
+		if col.Tag.Type == "date" || col.Tag.Type == "time" {
+			return col.Tag.Type, false
+		}
+		return "datetime", false
+	case "[]byte":
+		return "blob", false
+	}
+	if col.Tag.Type == "json" || col.Tag.Type == "jsonb" {
+		return "json", false
+	}
+	if col.Tag.Type == "text" || (col.Tag.Size == "" && !strings.HasPrefix(col.Tag.Type, "varchar") && col.Tag.Type != "") {
+		return "text", false
+	}
+	return "varchar", false
+}
+
+// typeFamily groups abstract types that a database may report interchangeably
+func typeFamily(typ string) string {
+	switch typ {
+	case "float", "double", "decimal":
+		return "float"
+	case "varchar", "text", "json":
+		return "string"
+	case "date", "datetime", "time":
+		return "time"
+	case "blob":
+		return "blob"
+	}
+	return "int"
+}
+
+func varcharSize(col *Column) string {
+	if col.Tag.Size != "" {
+		return col.Tag.Size
+	}
+	return "255"
+}
+
+func (m mysqlDriver) quote(name string) string {
+	return "`" + name + "`"
+}
+
+func (m mysqlDriver) columnType(col *Column) string {
+	typ, unsigned := abstractType(col)
+	var sqlType string
+	switch typ {
+	case "auto":
+		return "int(11) NOT NULL AUTO_INCREMENT PRIMARY KEY"
+	case "bigauto":
+		return "bigint(20) NOT NULL AUTO_INCREMENT PRIMARY KEY"
+	case "tinyint", "smallint", "bigint":
+		sqlType = typ
+	case "int":
+		sqlType = "int(11)"
+	case "bool":
+		sqlType = "tinyint(1)"
+	case "decimal":
+		sqlType = fmt.Sprintf("decimal(%s,%s)", col.Tag.Digits, col.Tag.Decimals)
+	case "float", "double", "date", "datetime", "time", "json", "blob":
+		sqlType = typ
+	case "text":
+		sqlType = "longtext"
+	default:
+		sqlType = "varchar(" + varcharSize(col) + ")"
+	}
+	if unsigned {
+		sqlType += " unsigned"
+	}
+	if col.Tag.Pk {
+		sqlType += " NOT NULL PRIMARY KEY"
+	}
+	return sqlType
+}
+
+func (m mysqlDriver) modifyColumn(table string, col *Column) []string {
+	return []string{fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s", m.quote(table), columnDef(m, col))}
+}
+
+func (m postgresqlDriver) quote(name string) string {
+	return `"` + name + `"`
+}
+
+func (m postgresqlDriver) columnType(col *Column) string {
+	typ, _ := abstractType(col)
+	var sqlType string
+	switch typ {
+	case "auto":
+		return "serial PRIMARY KEY"
+	case "bigauto":
+		return "bigserial PRIMARY KEY"
+	case "tinyint", "smallint":
+		sqlType = "smallint"
+	case "int":
+		sqlType = "integer"
+	case "bigint":
+		sqlType = "bigint"
+	case "bool":
+		sqlType = "boolean"
+	case "float":
+		sqlType = "real"
+	case "double":
+		sqlType = "double precision"
+	case "decimal":
+		sqlType = fmt.Sprintf("numeric(%s,%s)", col.Tag.Digits, col.Tag.Decimals)
+	case "date", "time", "json":
+		sqlType = typ
+	case "datetime":
+		sqlType = "timestamp with time zone"
+	case "blob":
+		sqlType = "bytea"
+	case "text":
+		sqlType = "text"
+	default:
+		sqlType = "varchar(" + varcharSize(col) + ")"
+	}
+	if col.Tag.Pk {
+		sqlType += " NOT NULL PRIMARY KEY"
+	}
+	return sqlType
+}
+
+func (m postgresqlDriver) modifyColumn(table string, col *Column) []string {
+	nullability := "SET NOT NULL"
+	if col.Tag.Null {
+		nullability = "DROP NOT NULL"
+	}
+	return []string{
+		fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE %s", m.quote(table), m.quote(col.Tag.Column), m.columnType(col)),
+		fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s %s", m.quote(table), m.quote(col.Tag.Column), nullability),
+	}
+}
+
+func (m sqliteDriver) quote(name string) string {
+	return `"` + name + `"`
+}
+
+func (m sqliteDriver) columnType(col *Column) string {
+	typ, _ := abstractType(col)
+	var sqlType string
+	switch typ {
+	case "auto", "bigauto":
+		return "INTEGER PRIMARY KEY AUTOINCREMENT"
+	case "tinyint", "smallint", "int", "bigint":
+		sqlType = "INTEGER"
+	case "bool":
+		sqlType = "BOOLEAN"
+	case "float", "double":
+		sqlType = "REAL"
+	case "decimal":
+		sqlType = fmt.Sprintf("DECIMAL(%s,%s)", col.Tag.Digits, col.Tag.Decimals)
+	case "date", "time", "datetime":
+		sqlType = strings.ToUpper(typ)
+	case "blob":
+		sqlType = "BLOB"
+	case "text", "json":
+		sqlType = "TEXT"
+	default:
+		sqlType = "varchar(" + varcharSize(col) + ")"
+	}
+	if col.Tag.Pk {
+		sqlType += " NOT NULL PRIMARY KEY"
+	}
+	return sqlType
+}
+
+// modifyColumn for SQLite returns nil, since SQLite cannot alter a column in place
+func (m sqliteDriver) modifyColumn(table string, col *Column) []string {
+	return nil
+}
+
+// parseModels returns the tables of the models registered with orm.RegisterModel in dir,
+// sorted by table name
+func parseModels(dir string) (tables []*Table) {
+	pkgs, err := parser.ParseDir(token.NewFileSet(), dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		beeLogger.Log.Fatalf("Could not parse models: %s", err)
+	}
+	structs := make(map[string]*ast.StructType)
+	tableNames := make(map[string]string)
+	var registered []string
+	for _, pkg := range pkgs {
+		for _, f := range pkg.Files {
+			ast.Inspect(f, func(n ast.Node) bool {
+				switch x := n.(type) {
+				case *ast.TypeSpec:
+					if st, ok := x.Type.(*ast.StructType); ok {
+						structs[x.Name.Name] = st
+					}
+				case *ast.FuncDecl:
+					if name, table := tableNameMethod(x); name != "" {
+						tableNames[name] = table
+					}
+				case *ast.CallExpr:
+					if sel, ok := x.Fun.(*ast.SelectorExpr); ok && strings.HasPrefix(sel.Sel.Name, "RegisterModel") {
+						for _, arg := range x.Args {
+							if name := modelTypeName(arg); name != "" {
+								registered = append(registered, name)
+							}
+						}
+					}
+				}
+				return true
+			})
+		}
+	}
+	for _, name := range registered {
+		st, ok := structs[name]
+		if !ok {
+			beeLogger.Log.Warnf("Model '%s' is registered but its struct was not found in the models directory", name)
+			continue
+		}
+		tb := &Table{Name: utils.SnakeString(name), Model: name, Fk: make(map[string]*ForeignKey)}
+		if table, ok := tableNames[name]; ok {
+			tb.Name = table
+		}
+		for _, field := range st.Fields.List {
+			if len(field.Names) == 0 || !field.Names[0].IsExported() {
+				continue
+			}
+			col := modelColumn(field)
+			if col == nil {
+				continue
+			}
+			if !col.hasColumn() {
+				tb.Relations = append(tb.Relations, col)
+				continue
+			}
+			if col.Tag.Auto || col.Tag.Pk {
+				tb.Pk = col.Tag.Column
+			}
+			tb.Columns = append(tb.Columns, col)
+		}
+		// like the orm, use an integer field named Id as the auto increment primary key
+		if tb.Pk == "" {
+			for _, col := range tb.Columns {
+				if col.Tag.Column == "id" && strings.Contains(col.Type, "int") {
+					col.Tag.Auto = true
+					tb.Pk = "id"
+				}
+			}
+		}
+		tables = append(tables, tb)
+	}
+	byModel := make(map[string]*Table)
+	for _, tb := range tables {
+		byModel[tb.Model] = tb
+	}
+	for _, tb := range tables {
+		for _, col := range tb.Columns {
+			if related, ok := byModel[strings.TrimPrefix(col.Type, "*")]; ok && (col.Tag.RelFk || col.Tag.RelOne) {
+				col.KeyType = pkType(related)
+			}
+		}
+	}
+	sort.Slice(tables, func(i, j int) bool { return tables[i].Name < tables[j].Name })
+	return
+}
+
+// modelColumn returns the column a model field maps to, or nil when it has none.
+// The m2m and reverse fields are returned without column.
+func modelColumn(field *ast.Field) *Column {
+	col := &Column{Name: field.Names[0].Name, Type: exprString(field.Type), Tag: new(OrmTag)}
+	var tag string
+	if field.Tag != nil {
+		if s, err := strconv.Unquote(field.Tag.Value); err == nil {
+			tag = reflect.StructTag(s).Get("orm")
+		}
+	}
+	if tag == "-" {
+		return nil
+	}
+	for _, opt := range strings.Split(tag, ";") {
+		name, arg := opt, ""
+		if i := strings.Index(opt, "("); i > 0 && strings.HasSuffix(opt, ")") {
+			name, arg = opt[:i], opt[i+1:len(opt)-1]
+		}
+		switch strings.TrimSpace(name) {
+		case "auto":
+			col.Tag.Auto = true
+		case "pk":
+			col.Tag.Pk = true
+		case "null":
+			col.Tag.Null = true
+		case "unique":
+			col.Tag.Unique = true
+		case "index":
+			col.Tag.Index = true
+		case "column":
+			col.Tag.Column = arg
+		case "size":
+			col.Tag.Size = arg
+		case "type":
+			col.Tag.Type = arg
+		case "digits":
+			col.Tag.Digits = arg
+		case "decimals":
+			col.Tag.Decimals = arg
+		case "default":
+			col.Tag.Default = arg
+		case "rel_table":
+			col.Tag.RelTable = arg
+		case "rel_through":
+			col.Tag.RelThrough = arg
+		case "auto_now":
+			col.Tag.AutoNow = true
+		case "auto_now_add":
+			col.Tag.AutoNowAdd = true
+		case "rel":
+			switch arg {
+			case "fk":
+				col.Tag.RelFk = true
+			case "one":
+				col.Tag.RelOne = true
+			case "m2m":
+				col.Tag.RelM2M = true
+			}
+		case "reverse":
+			switch arg {
+			case "one":
+				col.Tag.ReverseOne = true
+			case "many":
+				col.Tag.ReverseMany = true
+			}
+		}
+	}
+	// m2m ids are stored in a join table, reverse fields are read from the other side
+	if !col.hasColumn() {
+		return col
+	}
+	if col.Tag.Column == "" {
+		col.Tag.Column = utils.SnakeString(col.Name)
+		if col.Tag.RelFk || col.Tag.RelOne {
+			col.Tag.Column += "_id"
+		}
+	}
+	if strings.HasPrefix(col.Type, "[]") && col.Type != "[]byte" {
+		return nil
+	}
+	if col.Tag.Auto && col.Tag.Pk {
+		col.Tag.Pk = false
+	}
+	return col
+}
+
+// hasColumn reports whether the field of col is stored in a column of its table
+func (col *Column) hasColumn() bool {
+	return !col.Tag.RelM2M && !col.Tag.ReverseOne && !col.Tag.ReverseMany
+}
+
+// tableNameMethod returns the receiver and the returned literal of a TableName method
+func tableNameMethod(fn *ast.FuncDecl) (typeName, table string) {
+	if fn.Name.Name != "TableName" || fn.Recv == nil || len(fn.Recv.List) != 1 || fn.Body == nil || len(fn.Body.List) != 1 {
+		return
+	}
+	ret, ok := fn.Body.List[0].(*ast.ReturnStmt)
+	if !ok || len(ret.Results) != 1 {
+		return
+	}
+	lit, ok := ret.Results[0].(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return
+	}
+	table, _ = strconv.Unquote(lit.Value)
+	return strings.TrimPrefix(exprString(fn.Recv.List[0].Type), "*"), table
+}
+
+// modelTypeName returns T for the new(T) and &T{} arguments of orm.RegisterModel
+func modelTypeName(expr ast.Expr) string {
+	switch x := expr.(type) {
+	case *ast.CallExpr:
+		if ident, ok := x.Fun.(*ast.Ident); ok && ident.Name == "new" && len(x.Args) == 1 {
+			return exprString(x.Args[0])
+		}
+	case *ast.UnaryExpr:
+		if lit, ok := x.X.(*ast.CompositeLit); ok && x.Op == token.AND {
+			return exprString(lit.Type)
+		}
+	}
+	return ""
+}
+
+func exprString(expr ast.Expr) string {
+	switch x := expr.(type) {
+	case *ast.Ident:
+		return x.Name
+	case *ast.StarExpr:
+		return "*" + exprString(x.X)
+	case *ast.SelectorExpr:
+		return exprString(x.X) + "." + x.Sel.Name
+	case *ast.ArrayType:
+		return "[]" + exprString(x.Elt)
+	}
+	return ""
+}
@@ -0,0 +1,188 @@
+// Copyright 2013 bee authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package generate
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// tempDir creates a temporary directory, removed by the returned function
+func tempDir(t *testing.T) (string, func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "bee-generate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+// writeFile writes content to name in dir and returns its path
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	file := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(file), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(file, []byte(content), 0666); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+const diffModelsSource = `package models
+
+import "github.com/astaxie/beego/orm"
+
+type User struct {
+	Id     int64
+	Name   string   ` + "`orm:\"size(64)\"`" + `
+	Bio    string   ` + "`orm:\"null;type(text)\"`" + `
+	Tags   []*Tag   ` + "`orm:\"rel(m2m)\"`" + `
+	Groups []*Group ` + "`orm:\"rel(m2m);rel_table(memberships)\"`" + `
+	Roles  []*Role  ` + "`orm:\"rel(m2m);rel_through(app/models.UserRole)\"`" + `
+	Posts  []*Post  ` + "`orm:\"reverse(many)\"`" + `
+}
+
+type Post struct {
+	Id     int
+	Title  string
+	Author *User ` + "`orm:\"rel(fk)\"`" + `
+}
+
+type Tag struct {
+	Id    int
+	Name  string
+	Users []*User ` + "`orm:\"reverse(many)\"`" + `
+}
+
+type Group struct {
+	Id   int
+	Name string
+}
+
+type Role struct {
+	Id   int
+	Name string
+}
+
+func init() {
+	orm.RegisterModel(new(User), new(Post), new(Tag), new(Group), new(Role))
+}
+`
+
+// liveColumn returns a column as read from a database
+func liveColumn(name, typ string, tag OrmTag) *Column {
+	tag.Column = name
+	return &Column{Name: name, Type: typ, Tag: &tag}
+}
+
+func TestDiffSchema(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	writeFile(t, dir, "models.go", diffModelsSource)
+	models := parseModels(dir)
+
+	id := liveColumn("id", "int", OrmTag{Auto: true})
+	live := map[string]*Table{
+		"user": {Name: "user", Columns: []*Column{
+			liveColumn("id", "int64", OrmTag{Auto: true}),
+			liveColumn("name", "string", OrmTag{Size: "32"}),
+			liveColumn("legacy", "string", OrmTag{Size: "255"}),
+		}},
+		"tag":   {Name: "tag", Columns: []*Column{id, liveColumn("name", "string", OrmTag{Size: "255"})}},
+		"group": {Name: "group", Columns: []*Column{id, liveColumn("name", "string", OrmTag{Size: "255"})}},
+		"role":  {Name: "role", Columns: []*Column{id, liveColumn("name", "string", OrmTag{Size: "255"})}},
+		"memberships": {Name: "memberships", Columns: []*Column{
+			liveColumn("id", "int64", OrmTag{Auto: true}),
+			liveColumn("user_id", "int64", OrmTag{}),
+			liveColumn("group_id", "int", OrmTag{}),
+		}},
+		"unmanaged": {Name: "unmanaged", Columns: []*Column{id}},
+	}
+
+	tests := []struct {
+		driver   string
+		up, down []string
+	}{
+		{
+			driver: "postgres",
+			up: []string{
+				`CREATE TABLE "post" ("id" serial PRIMARY KEY, "title" varchar(255) NOT NULL, "author_id" bigint NOT NULL)`,
+				`ALTER TABLE "user" ALTER COLUMN "name" TYPE varchar(64)`,
+				`ALTER TABLE "user" ALTER COLUMN "name" SET NOT NULL`,
+				`ALTER TABLE "user" ADD COLUMN "bio" text NULL`,
+				`ALTER TABLE "user" DROP COLUMN "legacy"`,
+				`CREATE TABLE "user_tags" ("id" bigserial PRIMARY KEY, "user_id" bigint NOT NULL, "tag_id" integer NOT NULL)`,
+			},
+			down: []string{
+				`DROP TABLE "post"`,
+				`ALTER TABLE "user" ALTER COLUMN "name" TYPE varchar(32)`,
+				`ALTER TABLE "user" ALTER COLUMN "name" SET NOT NULL`,
+				`ALTER TABLE "user" DROP COLUMN "bio"`,
+				`ALTER TABLE "user" ADD COLUMN "legacy" varchar(255) NOT NULL`,
+				`DROP TABLE "user_tags"`,
+			},
+		},
+		{
+			driver: "sqlite",
+			up: []string{
+				`CREATE TABLE "post" ("id" INTEGER PRIMARY KEY AUTOINCREMENT, "title" varchar(255) NOT NULL, "author_id" INTEGER NOT NULL)`,
+				`ALTER TABLE "user" ADD COLUMN "bio" TEXT NULL`,
+				`ALTER TABLE "user" DROP COLUMN "legacy"`,
+				`CREATE TABLE "user_tags" ("id" INTEGER PRIMARY KEY AUTOINCREMENT, "user_id" INTEGER NOT NULL, "tag_id" INTEGER NOT NULL)`,
+			},
+			down: []string{
+				`DROP TABLE "post"`,
+				`ALTER TABLE "user" DROP COLUMN "bio"`,
+				`ALTER TABLE "user" ADD COLUMN "legacy" varchar(255) NOT NULL`,
+				`DROP TABLE "user_tags"`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		up, down := diffSchema(schemaDiffers[tt.driver], models, live)
+		if !reflect.DeepEqual(up, tt.up) {
+			t.Errorf("%s: up\n%q\nwant\n%q", tt.driver, up, tt.up)
+		}
+		if !reflect.DeepEqual(down, tt.down) {
+			t.Errorf("%s: down\n%q\nwant\n%q", tt.driver, down, tt.down)
+		}
+	}
+}
+
+func TestColumnDef(t *testing.T) {
+	tests := []struct {
+		col  *Column
+		want string
+	}{
+		{&Column{Type: "int64", Tag: &OrmTag{Column: "id", Auto: true}}, "`id` bigint(20) NOT NULL AUTO_INCREMENT PRIMARY KEY"},
+		{&Column{Type: "string", Tag: &OrmTag{Column: "email", Size: "128", Unique: true}}, "`email` varchar(128) NOT NULL UNIQUE"},
+		{&Column{Type: "string", Tag: &OrmTag{Column: "bio", Null: true, Type: "text"}}, "`bio` longtext NULL"},
+		{&Column{Type: "int", Tag: &OrmTag{Column: "age", Default: "18"}}, "`age` int(11) NOT NULL DEFAULT 18"},
+		{&Column{Type: "string", Tag: &OrmTag{Column: "state", Default: "new"}}, "`state` varchar(255) NOT NULL DEFAULT 'new'"},
+		{&Column{Type: "*Profile", Tag: &OrmTag{Column: "profile_id", RelOne: true}}, "`profile_id` int(11) NOT NULL UNIQUE"},
+		{&Column{Type: "*User", KeyType: "int64", Tag: &OrmTag{Column: "user_id", RelFk: true}}, "`user_id` bigint NOT NULL"},
+		{&Column{Type: "float64", Tag: &OrmTag{Column: "price", Digits: "10", Decimals: "2"}}, "`price` decimal(10,2) NOT NULL"},
+	}
+	for _, tt := range tests {
+		if got := columnDef(schemaDiffers["mysql"], tt.col); got != tt.want {
+			t.Errorf("columnDef(%s) = %q, want %q", tt.col.Tag.Column, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,369 @@
+// Copyright 2013 bee authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package generate
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/beego/bee/cmd/commands/migrate"
+	beeLogger "github.com/beego/bee/logger"
+)
+
+func init() {
+	migrate.SchemaDumper = DumpSchema
+	migrate.SchemaCatalog = SchemaCatalog
+}
+
+// schemaDiffers maps a DBMS name to the DBDriver rendering its schema statements
+var schemaDiffers = map[string]schemaDiffer{
+	"mysql":    mysqlDriver{},
+	"postgres": postgresqlDriver{},
+	"sqlite":   sqliteDriver{},
+}
+
+// autoIncrementOption is the table option of MySQL holding the next auto increment value,
+// which changes with the rows rather than with the schema
+var autoIncrementOption = regexp.MustCompile(` AUTO_INCREMENT=\d+`)
+
+// serialTypes are the types of the PostgreSQL columns whose default is their own sequence
+var serialTypes = map[string]string{
+	"smallint": "smallserial",
+	"integer":  "serial",
+	"bigint":   "bigserial",
+}
+
+// DumpSchema returns the statements creating the tables of a database with their
+// defaults, keys, indexes and constraints, bookkeeping tables excepted. They are read
+// from the database itself: SHOW CREATE TABLE on MySQL, sqlite_master on SQLite and
+// pg_catalog on PostgreSQL. Tables are sorted by name and foreign keys are added once
+// every table exists, so that the output only changes when the schema does.
+// Views, triggers and the types created by the schema are not dumped.
+func DumpSchema(driver string, db *sql.DB) []string {
+	switch driver {
+	case "mysql":
+		return dumpMysql(db)
+	case "postgres":
+		return dumpPostgres(db, schemaDiffers[driver])
+	case "sqlite":
+		return dumpSQLite(db)
+	}
+	beeLogger.Log.Fatalf("Dumping the schema of a '%s' database is not supported", driver)
+	return nil
+}
+
+// dumpTableNames returns the names of the tables a query lists, bookkeeping tables excepted
+func dumpTableNames(db *sql.DB, query string) (names []string) {
+	rows, err := db.Query(query)
+	if err != nil {
+		beeLogger.Log.Fatalf("Could not list the tables: %s", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			beeLogger.Log.Fatalf("Could not list the tables: %s", err)
+		}
+		if !bookkeepingTables[name] {
+			names = append(names, name)
+		}
+	}
+	return
+}
+
+// dumpMysql returns the statements of SHOW CREATE TABLE, whose foreign keys are moved
+// to ALTER TABLE statements
+func dumpMysql(db *sql.DB) (statements []string) {
+	var foreignKeys []string
+	for _, name := range dumpTableNames(db, `SELECT table_name FROM information_schema.tables
+		WHERE table_schema = database() AND table_type = 'BASE TABLE' ORDER BY table_name`) {
+		var table, create string
+		if err := db.QueryRow("SHOW CREATE TABLE `"+name+"`").Scan(&table, &create); err != nil {
+			beeLogger.Log.Fatalf("Could not show table '%s': %s", name, err)
+		}
+		stmt, fks := mysqlCreateTable(name, create)
+		statements = append(statements, stmt)
+		foreignKeys = append(foreignKeys, fks...)
+	}
+	return append(statements, foreignKeys...)
+}
+
+// mysqlCreateTable splits the output of SHOW CREATE TABLE into the statement creating
+// the table without its foreign keys, and the statements adding them
+func mysqlCreateTable(name, create string) (stmt string, foreignKeys []string) {
+	// one definition per line, between the CREATE TABLE line and the table options
+	lines := strings.Split(create, "\n")
+	if len(lines) < 3 {
+		return create, nil
+	}
+	var defs []string
+	for _, line := range lines[1 : len(lines)-1] {
+		def := strings.TrimSuffix(strings.TrimSpace(line), ",")
+		if strings.HasPrefix(def, "CONSTRAINT ") && strings.Contains(def, " FOREIGN KEY ") {
+			foreignKeys = append(foreignKeys, "ALTER TABLE `"+name+"` ADD "+def)
+			continue
+		}
+		defs = append(defs, "  "+def)
+	}
+	options := autoIncrementOption.ReplaceAllString(lines[len(lines)-1], "")
+	return lines[0] + "\n" + strings.Join(defs, ",\n") + "\n" + options, foreignKeys
+}
+
+// dumpSQLite returns the statements of sqlite_master, tables first. SQLite accepts
+// foreign keys referencing tables created later, so they are left in place.
+func dumpSQLite(db *sql.DB) (statements []string) {
+	rows, err := db.Query(`SELECT tbl_name, sql FROM sqlite_master
+		WHERE type IN ('table', 'index') AND sql IS NOT NULL AND name NOT LIKE 'sqlite_%'
+		ORDER BY type = 'index', name`)
+	if err != nil {
+		beeLogger.Log.Fatalf("Could not read sqlite_master: %s", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var table, stmt string
+		if err := rows.Scan(&table, &stmt); err != nil {
+			beeLogger.Log.Fatalf("Could not read sqlite_master: %s", err)
+		}
+		if !bookkeepingTables[table] {
+			statements = append(statements, stmt)
+		}
+	}
+	return
+}
+
+// dumpPostgres returns the statements creating the tables as described by pg_catalog:
+// the columns with their types and defaults, the primary key, unique, check and exclusion
+// constraints, then the other indexes and the foreign keys
+func dumpPostgres(db *sql.DB, d schemaDiffer) (statements []string) {
+	var indexes, foreignKeys []string
+	for _, name := range dumpTableNames(db, `SELECT c.relname FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.relkind = 'r' AND n.nspname = current_schema() ORDER BY c.relname`) {
+		table := d.quote(name)
+		defs := postgresColumns(db, d, table)
+
+		rows, err := db.Query(`SELECT conname, contype, pg_get_constraintdef(oid) FROM pg_constraint
+			WHERE conrelid = $1::regclass AND contype IN ('p', 'u', 'c', 'x', 'f')
+			ORDER BY contype <> 'p', conname`, table)
+		if err != nil {
+			beeLogger.Log.Fatalf("Could not read the constraints of '%s': %s", name, err)
+		}
+		for rows.Next() {
+			var conname, contype, def string
+			if err := rows.Scan(&conname, &contype, &def); err != nil {
+				beeLogger.Log.Fatalf("Could not read the constraints of '%s': %s", name, err)
+			}
+			constraint := "CONSTRAINT " + d.quote(conname) + " " + def
+			if contype == "f" {
+				foreignKeys = append(foreignKeys, "ALTER TABLE "+table+" ADD "+constraint)
+			} else {
+				defs = append(defs, constraint)
+			}
+		}
+		rows.Close()
+		statements = append(statements, fmt.Sprintf("CREATE TABLE %s (\n  %s\n)", table, strings.Join(defs, ",\n  ")))
+
+		// the indexes of the constraints are created with them
+		rows, err = db.Query(`SELECT pg_get_indexdef(i.indexrelid) FROM pg_index i
+			JOIN pg_class c ON c.oid = i.indexrelid
+			WHERE i.indrelid = $1::regclass
+				AND NOT EXISTS (SELECT 1 FROM pg_constraint k WHERE k.conindid = i.indexrelid AND k.conrelid = i.indrelid)
+			ORDER BY c.relname`, table)
+		if err != nil {
+			beeLogger.Log.Fatalf("Could not read the indexes of '%s': %s", name, err)
+		}
+		for rows.Next() {
+			var def string
+			if err := rows.Scan(&def); err != nil {
+				beeLogger.Log.Fatalf("Could not read the indexes of '%s': %s", name, err)
+			}
+			indexes = append(indexes, def)
+		}
+		rows.Close()
+	}
+	statements = append(statements, indexes...)
+	return append(statements, foreignKeys...)
+}
+
+// postgresColumns returns the definitions of the columns of a table. The columns whose
+// default is a sequence owned by the column are declared serial, which creates the sequence.
+func postgresColumns(db *sql.DB, d schemaDiffer, table string) (defs []string) {
+	rows, err := db.Query(`SELECT a.attname, format_type(a.atttypid, a.atttypmod), a.attnotnull,
+			a.attidentity, a.attgenerated, pg_get_expr(d.adbin, d.adrelid),
+			pg_get_serial_sequence($1::text, a.attname) IS NOT NULL
+		FROM pg_attribute a
+		LEFT JOIN pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+		WHERE a.attrelid = $1::text::regclass AND a.attnum > 0 AND NOT a.attisdropped
+		ORDER BY a.attnum`, table)
+	if err != nil {
+		beeLogger.Log.Fatalf("Could not read the columns of %s: %s", table, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name, typ, identity, generated string
+		var notNull, owned bool
+		var dflt sql.NullString
+		if err := rows.Scan(&name, &typ, &notNull, &identity, &generated, &dflt, &owned); err != nil {
+			beeLogger.Log.Fatalf("Could not read the columns of %s: %s", table, err)
+		}
+		if serial, ok := serialTypes[typ]; ok && owned && strings.HasPrefix(dflt.String, "nextval(") {
+			typ, dflt.Valid = serial, false
+		}
+		def := d.quote(name) + " " + typ
+		switch {
+		case generated == "s":
+			def += " GENERATED ALWAYS AS (" + dflt.String + ") STORED"
+		case identity == "a":
+			def += " GENERATED ALWAYS AS IDENTITY"
+		case identity == "d":
+			def += " GENERATED BY DEFAULT AS IDENTITY"
+		case dflt.Valid:
+			def += " DEFAULT " + dflt.String
+		}
+		if notNull {
+			def += " NOT NULL"
+		}
+		defs = append(defs, def)
+	}
+	return
+}
+
+// catalogQuery reads one kind of object from the catalog of a database. The first column
+// of its rows is the table the object belongs to, or the object itself.
+type catalogQuery struct {
+	kind  string
+	query string
+}
+
+// catalogQueries maps a DBMS name to the queries listing the objects of its schema
+var catalogQueries = map[string][]catalogQuery{
+	"mysql": {
+		{"object", `SELECT table_name, table_type FROM information_schema.tables WHERE table_schema = database()`},
+		{"column", `SELECT table_name, ordinal_position, column_name, column_type, is_nullable, column_default,
+			extra, collation_name FROM information_schema.columns WHERE table_schema = database()`},
+		{"index", `SELECT table_name, index_name, non_unique, seq_in_index, column_name, sub_part, index_type
+			FROM information_schema.statistics WHERE table_schema = database()`},
+		{"foreign key", `SELECT k.table_name, k.constraint_name, k.ordinal_position, k.column_name,
+				k.referenced_table_name, k.referenced_column_name, r.update_rule, r.delete_rule
+			FROM information_schema.key_column_usage k
+			JOIN information_schema.referential_constraints r
+				ON r.constraint_schema = k.constraint_schema AND r.constraint_name = k.constraint_name
+			WHERE k.table_schema = database()`},
+		{"trigger", `SELECT event_object_table, trigger_name, action_timing, event_manipulation, action_statement
+			FROM information_schema.triggers WHERE trigger_schema = database()`},
+		{"routine", `SELECT routine_name, routine_type, routine_definition
+			FROM information_schema.routines WHERE routine_schema = database()`},
+	},
+	"postgres": {
+		// sequences are listed under the table owning them, if any
+		{"object", `SELECT coalesce(o.relname, c.relname), c.relkind, c.relname FROM pg_class c
+			JOIN pg_namespace n ON n.oid = c.relnamespace
+			LEFT JOIN pg_depend d ON d.classid = 'pg_class'::regclass AND d.objid = c.oid
+				AND d.refclassid = 'pg_class'::regclass AND d.deptype IN ('a', 'i')
+			LEFT JOIN pg_class o ON o.oid = d.refobjid
+			WHERE n.nspname = current_schema() AND c.relkind IN ('r', 'p', 'v', 'm', 'S', 'f')`},
+		{"column", `SELECT c.relname, a.attnum, a.attname, format_type(a.atttypid, a.atttypmod), a.attnotnull,
+				a.attidentity, a.attgenerated, pg_get_expr(d.adbin, d.adrelid)
+			FROM pg_attribute a
+			JOIN pg_class c ON c.oid = a.attrelid
+			JOIN pg_namespace n ON n.oid = c.relnamespace
+			LEFT JOIN pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+			WHERE n.nspname = current_schema() AND c.relkind IN ('r', 'p') AND a.attnum > 0 AND NOT a.attisdropped`},
+		{"constraint", `SELECT c.relname, k.conname, k.contype, pg_get_constraintdef(k.oid) FROM pg_constraint k
+			JOIN pg_class c ON c.oid = k.conrelid
+			JOIN pg_namespace n ON n.oid = c.relnamespace
+			WHERE n.nspname = current_schema()`},
+		{"index", `SELECT c.relname, pg_get_indexdef(i.indexrelid) FROM pg_index i
+			JOIN pg_class c ON c.oid = i.indrelid
+			JOIN pg_namespace n ON n.oid = c.relnamespace
+			WHERE n.nspname = current_schema()`},
+		{"trigger", `SELECT c.relname, t.tgname, pg_get_triggerdef(t.oid) FROM pg_trigger t
+			JOIN pg_class c ON c.oid = t.tgrelid
+			JOIN pg_namespace n ON n.oid = c.relnamespace
+			WHERE n.nspname = current_schema() AND NOT t.tgisinternal`},
+		{"type", `SELECT t.typname, t.typtype, coalesce((SELECT string_agg(e.enumlabel, ', ' ORDER BY e.enumsortorder)
+				FROM pg_enum e WHERE e.enumtypid = t.oid), format_type(t.typbasetype, t.typtypmod))
+			FROM pg_type t
+			JOIN pg_namespace n ON n.oid = t.typnamespace
+			WHERE n.nspname = current_schema() AND t.typtype IN ('d', 'e', 'r')`},
+		{"function", `SELECT p.proname, pg_get_function_identity_arguments(p.oid), md5(p.prosrc) FROM pg_proc p
+			JOIN pg_namespace n ON n.oid = p.pronamespace
+			WHERE n.nspname = current_schema()`},
+	},
+	"sqlite": {
+		{"object", `SELECT tbl_name, type, name FROM sqlite_master WHERE name NOT LIKE 'sqlite_%'`},
+		{"column", `SELECT m.name, p.cid, p.name, p.type, p."notnull", p.dflt_value, p.pk
+			FROM sqlite_master m, pragma_table_info(m.name) p
+			WHERE m.type = 'table' AND m.name NOT LIKE 'sqlite_%'`},
+		// the indexes of the keys are named after their position in the table
+		{"index", `SELECT m.name, CASE WHEN l.origin = 'c' THEN l.name END, l."unique", l.origin, l.partial,
+				(SELECT group_concat(coalesce(name, cid), ', ') FROM
+					(SELECT name, cid FROM pragma_index_info(l.name) ORDER BY seqno))
+			FROM sqlite_master m, pragma_index_list(m.name) l
+			WHERE m.type = 'table' AND m.name NOT LIKE 'sqlite_%'`},
+		{"foreign key", `SELECT m.name, f.id, f.seq, f."from", f."table", f."to", f.on_update, f.on_delete
+			FROM sqlite_master m, pragma_foreign_key_list(m.name) f
+			WHERE m.type = 'table' AND m.name NOT LIKE 'sqlite_%'`},
+	},
+}
+
+// SchemaCatalog returns what the catalog of a database knows of its schema, one sorted line
+// per object: the tables, views, sequences and types, the columns with their types, nullability
+// and defaults, the keys, indexes, foreign keys, triggers and routines. Unlike DumpSchema it
+// does not depend on how the objects were declared, so that two databases can be compared.
+// The objects of the bookkeeping tables are left out.
+func SchemaCatalog(driver string, db *sql.DB) (lines []string) {
+	queries, ok := catalogQueries[driver]
+	if !ok {
+		beeLogger.Log.Fatalf("Reading the catalog of a '%s' database is not supported", driver)
+	}
+	for _, q := range queries {
+		rows, err := db.Query(q.query)
+		if err != nil {
+			beeLogger.Log.Fatalf("Could not read the %ss of the catalog: %s", q.kind, err)
+		}
+		columns, err := rows.Columns()
+		if err != nil {
+			beeLogger.Log.Fatalf("Could not read the %ss of the catalog: %s", q.kind, err)
+		}
+		values := make([]sql.NullString, len(columns))
+		dest := make([]interface{}, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		for rows.Next() {
+			if err := rows.Scan(dest...); err != nil {
+				beeLogger.Log.Fatalf("Could not read the %ss of the catalog: %s", q.kind, err)
+			}
+			if bookkeepingTables[values[0].String] {
+				continue
+			}
+			line := q.kind
+			for _, v := range values {
+				if !v.Valid {
+					v.String = "NULL"
+				}
+				line += " " + v.String
+			}
+			lines = append(lines, line)
+		}
+		rows.Close()
+	}
+	sort.Strings(lines)
+	return
+}
@@ -0,0 +1,165 @@
+// Copyright 2013 bee authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package generate
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// dumpTestSchema has what an introspection of the columns loses: defaults, composite
+// keys, plain indexes and foreign keys referencing a table created later
+var dumpTestSchema = []string{
+	`CREATE TABLE "orders" (
+		"user_id" bigint NOT NULL REFERENCES "users" ("id"),
+		"item_id" bigint NOT NULL,
+		"qty" integer NOT NULL DEFAULT 1,
+		"note" varchar(64) NOT NULL DEFAULT 'none',
+		PRIMARY KEY ("user_id", "item_id")
+	)`,
+	`CREATE TABLE "users" (
+		"id" INTEGER PRIMARY KEY AUTOINCREMENT,
+		"first" varchar(32) NOT NULL,
+		"last" varchar(32) NOT NULL,
+		"created" datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE ("first", "last")
+	)`,
+	`CREATE INDEX "idx_orders_qty" ON "orders" ("qty")`,
+	`CREATE TABLE "migrations" ("id_migration" INTEGER PRIMARY KEY AUTOINCREMENT, "name" varchar(255))`,
+}
+
+// sqliteDB opens a new SQLite database in dir running the statements
+func sqliteDB(t *testing.T, dir, name string, statements []string) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range statements {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			t.Fatalf("%s: %s", stmt, err)
+		}
+	}
+	return db
+}
+
+func TestDumpSchemaRoundTrip(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	db := sqliteDB(t, dir, "a.db", dumpTestSchema)
+	defer db.Close()
+
+	dump := DumpSchema("sqlite", db)
+	if len(dump) != 3 {
+		t.Fatalf("dumped %d statements, want the 2 tables and the index:\n%s", len(dump), strings.Join(dump, ";\n"))
+	}
+	all := strings.Join(dump, ";\n")
+	for _, want := range []string{`DEFAULT 'none'`, `DEFAULT CURRENT_TIMESTAMP`, `PRIMARY KEY ("user_id", "item_id")`,
+		`UNIQUE ("first", "last")`, `REFERENCES "users" ("id")`, `CREATE INDEX "idx_orders_qty"`} {
+		if !strings.Contains(all, want) {
+			t.Errorf("dump does not contain %s:\n%s", want, all)
+		}
+	}
+
+	// loading the dump into an empty database gives the same schema back
+	loaded := sqliteDB(t, dir, "b.db", dump)
+	defer loaded.Close()
+	if again := DumpSchema("sqlite", loaded); !reflect.DeepEqual(again, dump) {
+		t.Errorf("dump of the loaded dump\n%s\nwant\n%s", strings.Join(again, ";\n"), all)
+	}
+}
+
+func TestMysqlCreateTable(t *testing.T) {
+	create := "CREATE TABLE `orders` (\n" +
+		"  `user_id` bigint NOT NULL,\n" +
+		"  `qty` int NOT NULL DEFAULT '1',\n" +
+		"  PRIMARY KEY (`user_id`),\n" +
+		"  KEY `idx_qty` (`qty`),\n" +
+		"  CONSTRAINT `fk_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE\n" +
+		") ENGINE=InnoDB AUTO_INCREMENT=42 DEFAULT CHARSET=utf8mb4"
+	stmt, fks := mysqlCreateTable("orders", create)
+	want := "CREATE TABLE `orders` (\n" +
+		"  `user_id` bigint NOT NULL,\n" +
+		"  `qty` int NOT NULL DEFAULT '1',\n" +
+		"  PRIMARY KEY (`user_id`),\n" +
+		"  KEY `idx_qty` (`qty`)\n" +
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4"
+	if stmt != want {
+		t.Errorf("statement\n%s\nwant\n%s", stmt, want)
+	}
+	wantFks := []string{"ALTER TABLE `orders` ADD CONSTRAINT `fk_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE"}
+	if !reflect.DeepEqual(fks, wantFks) {
+		t.Errorf("foreign keys %q, want %q", fks, wantFks)
+	}
+}
+
+func TestSchemaCatalog(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	db := sqliteDB(t, dir, "a.db", append(dumpTestSchema,
+		`CREATE VIEW "big_orders" AS SELECT * FROM "orders" WHERE "qty" > 10`,
+		`CREATE TRIGGER "orders_qty" BEFORE INSERT ON "orders" BEGIN SELECT RAISE(ABORT, 'qty') WHERE NEW."qty" < 0; END`))
+	defer db.Close()
+	catalog := SchemaCatalog("sqlite", db)
+	for _, want := range []string{
+		"column orders 3 note varchar(64) 1 'none' 0",
+		"column users 0 id INTEGER 0 NULL 1",
+		"foreign key orders 0 0 user_id users id NO ACTION NO ACTION",
+		"index orders NULL 1 pk 0 user_id, item_id",
+		"index orders idx_orders_qty 0 c 0 qty",
+		"index users NULL 1 u 0 first, last",
+		"object orders trigger orders_qty",
+		"object big_orders view big_orders",
+	} {
+		if !hasLine(catalog, want) {
+			t.Errorf("catalog does not list %q:\n%s", want, strings.Join(catalog, "\n"))
+		}
+	}
+	for _, line := range catalog {
+		if strings.Contains(line, "migration") {
+			t.Errorf("catalog lists the bookkeeping table: %s", line)
+		}
+	}
+
+	// the dump leaves out the view and the trigger, which the catalog tells apart
+	loaded := sqliteDB(t, dir, "b.db", DumpSchema("sqlite", db))
+	defer loaded.Close()
+	if again := DumpSchema("sqlite", loaded); !reflect.DeepEqual(again, DumpSchema("sqlite", db)) {
+		t.Errorf("dumps differ:\n%s", strings.Join(again, ";\n"))
+	}
+	var missing []string
+	for _, line := range catalog {
+		if !hasLine(SchemaCatalog("sqlite", loaded), line) {
+			missing = append(missing, line)
+		}
+	}
+	want := []string{"object big_orders view big_orders", "object orders trigger orders_qty"}
+	if !reflect.DeepEqual(missing, want) {
+		t.Errorf("catalog of the loaded dump misses %q, want %q", missing, want)
+	}
+}
+
+func hasLine(lines []string, line string) bool {
+	for _, l := range lines {
+		if l == line {
+			return true
+		}
+	}
+	return false
+}
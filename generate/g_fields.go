@@ -0,0 +1,198 @@
+// Copyright 2013 bee authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package generate
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/beego/bee/utils"
+)
+
+// field is a column described by the -fields option, i.e. name:type[:size][:modifier...].
+// Types with arguments are written decimal(10,2). Relations are written name:fk:Model,
+// name:o2o:Model and name:m2m:Model. Modifiers are null, default(value), unique, index and remove.
+type field struct {
+	Name     string
+	Type     string
+	Size     string
+	Digits   string // of a decimal
+	Decimals string // of a decimal
+	Rel      string // model of a fk, o2o or m2m relation
+	Null     bool
+	Default  string
+	Unique   bool
+	Index    bool
+	Remove   bool // the column is dropped by an alter migration
+}
+
+// fieldTypes are the types of the -fields option, and the Go type of their model field
+var fieldTypes = map[string]string{
+	"string": "string", "text": "string", "json": "string",
+	"auto": "int64", "pk": "int64",
+	"int": "int", "int8": "int8", "int16": "int16", "int32": "int32", "int64": "int64",
+	"uint": "uint", "uint8": "uint8", "uint16": "uint16", "uint32": "uint32", "uint64": "uint64",
+	"bool":  "bool",
+	"float": "float64", "float32": "float32", "float64": "float64", "decimal": "float64",
+	"datetime": "time.Time", "date": "time.Time", "time": "time.Time",
+	"fk": "*", "o2o": "*", "m2m": "[]*",
+}
+
+// Column returns the name of the column of the field
+func (f field) Column() string {
+	if f.IsRelation() {
+		return utils.SnakeString(f.Name) + "_id"
+	}
+	return utils.SnakeString(f.Name)
+}
+
+// IsRelation reports whether the field is a fk, o2o or m2m relation
+func (f field) IsRelation() bool {
+	return f.Rel != ""
+}
+
+// ormColumn returns the model field of f, as parsed from a model by parseModels, so that
+// models, migrations and views are generated from the same schema. A m2m relation has
+// no column, its ids are stored in a join table.
+func (f field) ormColumn() *Column {
+	col := &Column{Name: utils.CamelString(f.Name), Type: fieldTypes[f.Type] + f.Rel, Tag: &OrmTag{
+		Null:    f.Null,
+		Unique:  f.Unique,
+		Index:   f.Index,
+		Default: f.Default,
+	}}
+	if f.Type != "m2m" {
+		col.Tag.Column = f.Column()
+	}
+	switch f.Type {
+	case "string":
+		col.Tag.Size = f.Size
+		if col.Tag.Size == "" {
+			col.Tag.Size = "128"
+		}
+	case "text":
+		col.Tag.Type = "longtext"
+	case "json", "date", "time":
+		col.Tag.Type = f.Type
+	case "datetime":
+		col.Tag.Type = "datetime"
+	case "decimal":
+		col.Tag.Digits, col.Tag.Decimals = f.Digits, f.Decimals
+	case "auto":
+		col.Tag.Auto = true
+	case "pk":
+		col.Tag.Pk = true
+	case "fk":
+		col.Tag.RelFk = true
+		// the models generated from fields have an int64 Id
+		col.KeyType = "int64"
+	case "o2o":
+		col.Tag.RelOne = true
+		col.KeyType = "int64"
+	case "m2m":
+		col.Tag.RelM2M = true
+	}
+	return col
+}
+
+// parseFields parses the -fields option
+func parseFields(fields string) ([]field, error) {
+	var fds []field
+	for _, def := range splitOutsideParens(fields, ',') {
+		def = strings.TrimSpace(def)
+		if def == "" {
+			continue
+		}
+		parts := splitOutsideParens(def, ':')
+		if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("fields format is wrong. Should be: name:type[:size][:modifier],... got '%s'", def)
+		}
+		f := field{Name: parts[0], Type: strings.ToLower(parts[1])}
+		if strings.HasPrefix(f.Type, "decimal") {
+			if f.Type == "decimal" {
+				f.Digits, f.Decimals = "10", "2"
+			} else if args := strings.Split(strings.TrimSuffix(strings.TrimPrefix(f.Type, "decimal("), ")"), ","); len(args) == 2 && isDigits(args[0]) && isDigits(args[1]) {
+				f.Digits, f.Decimals = args[0], args[1]
+			} else {
+				return nil, fmt.Errorf("decimal field '%s' should be written decimal(digits,decimals)", f.Name)
+			}
+			f.Type = "decimal"
+		}
+		if _, ok := fieldTypes[f.Type]; !ok {
+			return nil, fmt.Errorf("unknown type '%s' of field '%s'", parts[1], f.Name)
+		}
+		mods := parts[2:]
+		if f.Type == "fk" || f.Type == "o2o" || f.Type == "m2m" {
+			if len(mods) == 0 || mods[0] == "" {
+				return nil, fmt.Errorf("relation '%s' should name its model, i.e. %s:%s:User", f.Name, f.Name, f.Type)
+			}
+			f.Rel, mods = utils.CamelString(mods[0]), mods[1:]
+		}
+		for _, mod := range mods {
+			switch {
+			case isDigits(mod):
+				f.Size = mod
+			case mod == "null", mod == "nullable":
+				f.Null = true
+			case mod == "unique":
+				f.Unique = true
+			case mod == "index":
+				f.Index = true
+			case mod == "remove":
+				f.Remove = true
+			case strings.HasPrefix(mod, "default(") && strings.HasSuffix(mod, ")"):
+				f.Default = mod[len("default(") : len(mod)-1]
+			default:
+				return nil, fmt.Errorf("unknown modifier '%s' of field '%s'", mod, f.Name)
+			}
+		}
+		fds = append(fds, f)
+	}
+	if len(fds) == 0 {
+		return nil, fmt.Errorf("no fields given")
+	}
+	return fds, nil
+}
+
+// splitOutsideParens splits s around sep, except inside parentheses, i.e. default(a,b)
+func splitOutsideParens(s string, sep rune) (parts []string) {
+	depth, start := 0, 0
+	for i, r := range s {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			if depth > 0 {
+				depth--
+			}
+		case sep:
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return s != ""
+}
@@ -0,0 +1,121 @@
+// Copyright 2013 bee authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package generate
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitOutsideParens(t *testing.T) {
+	tests := []struct {
+		s    string
+		sep  rune
+		want []string
+	}{
+		{"name:string,age:int", ',', []string{"name:string", "age:int"}},
+		{"price:decimal(10,2),name:string", ',', []string{"price:decimal(10,2)", "name:string"}},
+		{"state:string:default(a:b)", ':', []string{"state", "string", "default(a:b)"}},
+		{"name", ',', []string{"name"}},
+		{"", ',', []string{""}},
+		{"a),b", ',', []string{"a)", "b"}},
+	}
+	for _, tt := range tests {
+		if got := splitOutsideParens(tt.s, tt.sep); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitOutsideParens(%q, %q) = %q, want %q", tt.s, tt.sep, got, tt.want)
+		}
+	}
+}
+
+func TestParseFields(t *testing.T) {
+	tests := []struct {
+		fields  string
+		want    []field
+		wantErr bool
+	}{
+		{
+			fields: "name:string:64, age:int,",
+			want:   []field{{Name: "name", Type: "string", Size: "64"}, {Name: "age", Type: "int"}},
+		},
+		{
+			fields: "Title:STRING,body:text",
+			want:   []field{{Name: "Title", Type: "string"}, {Name: "body", Type: "text"}},
+		},
+		{
+			fields: "price:decimal(8,3):default(0),total:decimal,email:string:128:unique:index,bio:text:null",
+			want: []field{
+				{Name: "price", Type: "decimal", Digits: "8", Decimals: "3", Default: "0"},
+				{Name: "total", Type: "decimal", Digits: "10", Decimals: "2"},
+				{Name: "email", Type: "string", Size: "128", Unique: true, Index: true},
+				{Name: "bio", Type: "text", Null: true},
+			},
+		},
+		{
+			fields: "state:string:default(new,open),legacy:int:remove",
+			want: []field{
+				{Name: "state", Type: "string", Default: "new,open"},
+				{Name: "legacy", Type: "int", Remove: true},
+			},
+		},
+		{
+			fields: "author:fk:user,profile:o2o:user_profile:null,tags:m2m:Tag",
+			want: []field{
+				{Name: "author", Type: "fk", Rel: "User"},
+				{Name: "profile", Type: "o2o", Rel: "UserProfile", Null: true},
+				{Name: "tags", Type: "m2m", Rel: "Tag"},
+			},
+		},
+		{fields: "author:fk", wantErr: true},
+		{fields: "price:decimal(10)", wantErr: true},
+		{fields: "price:decimal(a,b)", wantErr: true},
+		{fields: "name:string:default(x", wantErr: true},
+		{fields: "", wantErr: true},
+		{fields: "name", wantErr: true},
+		{fields: "name:", wantErr: true},
+		{fields: ":string", wantErr: true},
+		{fields: "name:varchar", wantErr: true},
+		{fields: "name:string:huge", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseFields(tt.fields)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseFields(%q) error = %v, want error %t", tt.fields, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseFields(%q) = %+v, want %+v", tt.fields, got, tt.want)
+		}
+	}
+}
+
+func TestOrmColumn(t *testing.T) {
+	tests := []struct {
+		f    field
+		want Column
+	}{
+		{field{Name: "first_name", Type: "string"}, Column{Name: "FirstName", Type: "string", Tag: &OrmTag{Column: "first_name", Size: "128"}}},
+		{field{Name: "bio", Type: "text", Null: true}, Column{Name: "Bio", Type: "string", Tag: &OrmTag{Column: "bio", Type: "longtext", Null: true}}},
+		{field{Name: "price", Type: "decimal", Digits: "10", Decimals: "2"}, Column{Name: "Price", Type: "float64", Tag: &OrmTag{Column: "price", Digits: "10", Decimals: "2"}}},
+		{field{Name: "born", Type: "date"}, Column{Name: "Born", Type: "time.Time", Tag: &OrmTag{Column: "born", Type: "date"}}},
+		{field{Name: "author", Type: "fk", Rel: "User"}, Column{Name: "Author", Type: "*User", KeyType: "int64", Tag: &OrmTag{Column: "author_id", RelFk: true}}},
+		{field{Name: "profile", Type: "o2o", Rel: "Profile"}, Column{Name: "Profile", Type: "*Profile", KeyType: "int64", Tag: &OrmTag{Column: "profile_id", RelOne: true}}},
+		{field{Name: "tags", Type: "m2m", Rel: "Tag"}, Column{Name: "Tags", Type: "[]*Tag", Tag: &OrmTag{RelM2M: true}}},
+	}
+	for _, tt := range tests {
+		if got := tt.f.ormColumn(); !reflect.DeepEqual(*got, tt.want) {
+			t.Errorf("ormColumn(%+v) = %+v %+v, want %+v %+v", tt.f, *got, *got.Tag, tt.want, *tt.want.Tag)
+		}
+	}
+}
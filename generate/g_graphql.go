@@ -0,0 +1,502 @@
+// Copyright 2013 bee authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package generate
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	beeLogger "github.com/beego/bee/logger"
+	"github.com/beego/bee/utils"
+)
+
+// gqlScalar is the GraphQL type of a Go type, and the type its resolvers use
+type gqlScalar struct {
+	Name   string // GraphQL type
+	GoType string // type of the graph-gophers resolvers and inputs
+}
+
+// gqlScalars are the GraphQL types of the Go types. Int is a 32-bit integer,
+// so the integers which may not fit are given as strings.
+var gqlScalars = map[string]gqlScalar{
+	"int":       {"Int", "int32"},
+	"int8":      {"Int", "int32"},
+	"int16":     {"Int", "int32"},
+	"int32":     {"Int", "int32"},
+	"int64":     {"String", "string"},
+	"uint":      {"String", "string"},
+	"uint8":     {"Int", "int32"},
+	"uint16":    {"Int", "int32"},
+	"uint32":    {"String", "string"},
+	"uint64":    {"String", "string"},
+	"float32":   {"Float", "float64"},
+	"float64":   {"Float", "float64"},
+	"string":    {"String", "string"},
+	"bool":      {"Boolean", "bool"},
+	"time.Time": {"Time", "graphqlgo.Time"},
+}
+
+// gqlID is the GraphQL type of the ids, given as strings
+var gqlID = gqlScalar{"ID", "graphqlgo.ID"}
+
+// GenerateGraphQL generates the GraphQL schema of the beego orm models, and the
+// graph-gophers resolvers of its queries and mutations, backed by the functions
+// of the models used by the controllers
+func GenerateGraphQL(tables, currpath string) {
+	var models []*apiModel
+	for _, m := range apiModels(tables, currpath) {
+		switch m.name {
+		case "Query", "Mutation", "Time", "Resolver":
+			beeLogger.Log.Warnf("Model '%s' has the name of a type of the schema: skipped it", m.name)
+			continue
+		}
+		if m.id == nil {
+			beeLogger.Log.Warnf("Model '%s' has no integer Id primary key: generated its list query only", m.name)
+		}
+		models = append(models, m)
+	}
+
+	schema, resolvers := graphqlSource(models, getPackagePath(currpath))
+	gqlPath := path.Join(currpath, "graphql")
+	if err := os.MkdirAll(gqlPath, 0777); err != nil {
+		beeLogger.Log.Fatalf("Could not create graphql directory: %s", err)
+	}
+	writeGeneratedFile(path.Join(gqlPath, "schema.graphql"), schema)
+	writeGeneratedFile(path.Join(gqlPath, "resolvers.go"), resolvers)
+	beeLogger.Log.Hint("Serve the schema with beego.Handler(\"/graphql\", graphql.Handler()), after go get github.com/graph-gophers/graphql-go")
+}
+
+// gqlName returns the GraphQL name of a field, i.e. createdAt for CreatedAt
+func gqlName(name string) string {
+	if name == "" {
+		return name
+	}
+	return strings.ToLower(name[:1]) + name[1:]
+}
+
+// listQueryName returns the name of the list query of a model, i.e. books for Book
+func listQueryName(model string) string {
+	name := gqlName(relFieldName(model))
+	if name == gqlName(model) {
+		name += "List"
+	}
+	return name
+}
+
+// graphqlSource returns the SDL schema of the models and the source of their resolvers
+func graphqlSource(models []*apiModel, pkgPath string) (string, string) {
+	var types, queries, mutations, resolvers strings.Builder
+	useTime, useOrm := false, false
+	for _, m := range models {
+		var fields, inputs, fieldResolvers, inputFields, applies strings.Builder
+		for _, col := range append(m.tb.Columns, m.tb.Relations...) {
+			name := gqlName(col.Name)
+			if rel := col.Tag.RelFk || col.Tag.RelOne; rel || !col.hasColumn() {
+				related := relatedModel(col, models)
+				if related == nil {
+					beeLogger.Log.Warnf("Field '%s.%s' relates to a model that is not generated: skipped it", m.name, col.Name)
+					continue
+				}
+				useOrm = true
+				if strings.HasPrefix(col.Type, "[]") {
+					fmt.Fprintf(&fields, "  %s: [%s!]!\n", name, related.name)
+					fieldResolvers.WriteString(replaceField(GraphQLManyTPL, m, col, related.name))
+				} else {
+					fmt.Fprintf(&fields, "  %s: %s\n", name, related.name)
+					tpl := GraphQLOneTPL
+					if rel {
+						tpl = GraphQLFkTPL
+					}
+					fieldResolvers.WriteString(replaceField(tpl, m, col, related.name))
+				}
+				// the fk of the relation is set by the id of the related model, named after its column
+				if rel && related.id != nil {
+					fkName := utils.CamelCase(col.Tag.Column)
+					fmt.Fprintf(&inputs, "  %s: ID\n", gqlName(fkName))
+					fmt.Fprintf(&inputFields, "\t%s *graphqlgo.ID\n", fkName)
+					apply := strings.Replace(GraphQLApplyFkTPL, "{{relatedIdType}}", related.id.Type, -1)
+					apply = strings.Replace(apply, "{{fkName}}", fkName, -1)
+					applies.WriteString(replaceField(apply, m, col, related.name))
+				}
+				continue
+			}
+			scalar, ok := gqlScalars[col.Type]
+			if col == m.id {
+				scalar = gqlID
+			}
+			if !ok {
+				beeLogger.Log.Warnf("Field '%s.%s' of type '%s' has no GraphQL type: skipped it", m.name, col.Name, col.Type)
+				continue
+			}
+			useTime = useTime || scalar.Name == "Time"
+			fmt.Fprintf(&fields, "  %s: %s!\n", name, scalar.Name)
+			fmt.Fprintf(&fieldResolvers, "func (r *%sResolver) %s() %s {\n\treturn %s\n}\n\n",
+				gqlName(m.name), col.Name, scalar.GoType, gqlValue(scalar, col.Type, "r.m."+col.Name))
+			// the orm sets the auto fields
+			if col.Tag.Auto || col.Tag.AutoNow || col.Tag.AutoNowAdd {
+				continue
+			}
+			fmt.Fprintf(&inputs, "  %s: %s\n", name, scalar.Name)
+			fmt.Fprintf(&inputFields, "\t%s *%s\n", col.Name, scalar.GoType)
+			applies.WriteString(applyField(scalar, col.Type, col.Name))
+		}
+
+		fmt.Fprintf(&types, "type %s {\n%s}\n\n", m.name, fields.String())
+		fmt.Fprintf(&queries, "  %s(query: String, sortby: String, order: String, offset: Int! = 0, limit: Int! = 10): [%s!]!\n", listQueryName(m.name), m.name)
+		resolvers.WriteString(replaceModel(GraphQLTypeTPL, m))
+		resolvers.WriteString(fieldResolvers.String())
+		resolvers.WriteString(replaceModel(GraphQLListTPL, m))
+		if m.id == nil {
+			continue
+		}
+		useOrm = true
+		fmt.Fprintf(&queries, "  %s(id: ID!): %s\n", gqlName(m.name), m.name)
+		resolvers.WriteString(replaceModel(GraphQLGetTPL, m))
+		// an input type needs at least one field
+		if inputs.Len() != 0 {
+			fmt.Fprintf(&types, "input %sInput {\n%s}\n\n", m.name, inputs.String())
+			fmt.Fprintf(&mutations, "  create%s(input: %sInput!): %s!\n", m.name, m.name, m.name)
+			fmt.Fprintf(&mutations, "  update%s(id: ID!, input: %sInput!): %s\n", m.name, m.name, m.name)
+			input := strings.Replace(GraphQLInputTPL, "{{inputFields}}", inputFields.String(), -1)
+			input = strings.Replace(input, "{{applies}}", applies.String(), -1)
+			resolvers.WriteString(replaceModel(input, m))
+		}
+		fmt.Fprintf(&mutations, "  delete%s(id: ID!): Boolean!\n", m.name)
+		resolvers.WriteString(replaceModel(GraphQLDeleteTPL, m))
+	}
+
+	var schema strings.Builder
+	schema.WriteString("schema {\n  query: Query\n")
+	if mutations.Len() != 0 {
+		schema.WriteString("  mutation: Mutation\n")
+	}
+	schema.WriteString("}\n\n")
+	if useTime {
+		schema.WriteString("scalar Time\n\n")
+	}
+	schema.WriteString(types.String())
+	fmt.Fprintf(&schema, "type Query {\n%s}\n", queries.String())
+	if mutations.Len() != 0 {
+		fmt.Fprintf(&schema, "\ntype Mutation {\n%s}\n", mutations.String())
+	}
+
+	ormPkg := ""
+	if useOrm {
+		ormPkg = "\"github.com/astaxie/beego/orm\"\n"
+	}
+	fileStr := strings.Replace(GraphQLTPL, "{{schema}}", schema.String(), 1)
+	fileStr = strings.Replace(fileStr, "{{resolvers}}", resolvers.String(), 1)
+	fileStr = strings.Replace(fileStr, "{{pkgPath}}", pkgPath, -1)
+	fileStr = strings.Replace(fileStr, "{{ormPkg}}", ormPkg, -1)
+	return schema.String(), fileStr
+}
+
+// gqlValue returns the resolver value of the model field expr of type goType
+func gqlValue(scalar gqlScalar, goType, expr string) string {
+	switch scalar.GoType {
+	case "int32", "float64":
+		return scalar.GoType + "(" + expr + ")"
+	case "graphqlgo.Time":
+		return "graphqlgo.Time{Time: " + expr + "}"
+	case "graphqlgo.ID":
+		return "graphqlgo.ID(" + formatInt(goType, expr) + ")"
+	}
+	if goType != "string" {
+		return formatInt(goType, expr)
+	}
+	return expr
+}
+
+// formatInt returns the decimal string of the integer expr of type goType
+func formatInt(goType, expr string) string {
+	if strings.HasPrefix(goType, "u") {
+		return "strconv.FormatUint(uint64(" + expr + "), 10)"
+	}
+	return "strconv.FormatInt(int64(" + expr + "), 10)"
+}
+
+// applyField returns the statement setting the model field name of type goType
+// from the input field of the same name, when it is given
+func applyField(scalar gqlScalar, goType, name string) string {
+	var parse string
+	switch {
+	case scalar.GoType == "graphqlgo.ID":
+		parse = "parseID(*in." + name + ")"
+	case scalar.GoType == "string" && goType != "string" && strings.HasPrefix(goType, "u"):
+		parse = "strconv.ParseUint(*in." + name + ", 10, 64)"
+	case scalar.GoType == "string" && goType != "string":
+		parse = "strconv.ParseInt(*in." + name + ", 10, 64)"
+	}
+	if parse != "" {
+		return fmt.Sprintf("\tif in.%s != nil {\n\t\tv, err := %s\n\t\tif err != nil {\n\t\t\treturn err\n\t\t}\n\t\tm.%s = %s(v)\n\t}\n",
+			name, parse, name, goType)
+	}
+	value := "*in." + name
+	switch scalar.GoType {
+	case "int32", "float64":
+		value = goType + "(*in." + name + ")"
+	case "graphqlgo.Time":
+		value = "in." + name + ".Time"
+	}
+	return fmt.Sprintf("\tif in.%s != nil {\n\t\tm.%s = %s\n\t}\n", name, name, value)
+}
+
+// replaceModel fills in the placeholders of a model in tpl
+func replaceModel(tpl string, m *apiModel) string {
+	tpl = strings.Replace(tpl, "{{listName}}", utils.CamelCase(listQueryName(m.name)), -1)
+	tpl = strings.Replace(tpl, "{{resolverName}}", gqlName(m.name)+"Resolver", -1)
+	tpl = strings.Replace(tpl, "{{inputName}}", gqlName(m.name)+"Input", -1)
+	tpl = strings.Replace(tpl, "{{modelName}}", m.name, -1)
+	if m.id != nil {
+		tpl = strings.Replace(tpl, "{{idType}}", m.id.Type, -1)
+	}
+	return tpl
+}
+
+// replaceField fills in the placeholders of the relation field col of m in tpl
+func replaceField(tpl string, m *apiModel, col *Column, related string) string {
+	tpl = strings.Replace(tpl, "{{fieldName}}", col.Name, -1)
+	tpl = strings.Replace(tpl, "{{relatedResolver}}", gqlName(related)+"Resolver", -1)
+	tpl = strings.Replace(tpl, "{{relatedModel}}", related, -1)
+	return replaceModel(tpl, m)
+}
+
+const (
+	GraphQLTPL = `package graphql
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"{{pkgPath}}/models"
+
+	{{ormPkg}}graphqlgo "github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/relay"
+)
+
+// bee:custom begin imports
+// bee:custom end
+
+// Schema is the GraphQL schema of the models, as written to schema.graphql
+const Schema = ` + "`" + `{{schema}}` + "`" + `
+
+// Handler serves the schema, i.e. beego.Handler("/graphql", graphql.Handler())
+func Handler() http.Handler {
+	return &relay.Handler{Schema: graphqlgo.MustParseSchema(Schema, &Resolver{})}
+}
+
+// Resolver resolves the queries and mutations of the schema
+type Resolver struct{}
+
+// listArgs are the arguments of the list queries, as taken by the GetAll actions of the controllers
+type listArgs struct {
+	Query  *string // k:v,k:v
+	Sortby *string // col1,col2
+	Order  *string // desc,asc
+	Offset int32
+	Limit  int32
+}
+
+// params returns the query, sortby and order parameters of the GetAll functions of the models
+func (a listArgs) params() (query map[string]string, sortby []string, order []string, err error) {
+	query = make(map[string]string)
+	if a.Query != nil && *a.Query != "" {
+		for _, cond := range strings.Split(*a.Query, ",") {
+			kv := strings.SplitN(cond, ":", 2)
+			if len(kv) != 2 {
+				return nil, nil, nil, errors.New("Error: invalid query key/value pair")
+			}
+			query[kv[0]] = kv[1]
+		}
+	}
+	if a.Sortby != nil && *a.Sortby != "" {
+		sortby = strings.Split(*a.Sortby, ",")
+	}
+	if a.Order != nil && *a.Order != "" {
+		order = strings.Split(*a.Order, ",")
+	}
+	return
+}
+
+// parseID returns the integer of an id
+func parseID(id graphqlgo.ID) (int64, error) {
+	return strconv.ParseInt(string(id), 10, 64)
+}
+
+{{resolvers}}
+// bee:custom begin methods
+// bee:custom end
+`
+
+	GraphQLTypeTPL = `// {{resolverName}} resolves the fields of a {{modelName}}
+type {{resolverName}} struct {
+	m *models.{{modelName}}
+}
+
+`
+
+	GraphQLFkTPL = `func (r *{{resolverName}}) {{fieldName}}() (*{{relatedResolver}}, error) {
+	if r.m.{{fieldName}} == nil {
+		return nil, nil
+	}
+	if err := orm.NewOrm().Read(r.m.{{fieldName}}); err == orm.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &{{relatedResolver}}{r.m.{{fieldName}}}, nil
+}
+
+`
+
+	GraphQLOneTPL = `func (r *{{resolverName}}) {{fieldName}}() (*{{relatedResolver}}, error) {
+	if _, err := orm.NewOrm().LoadRelated(r.m, "{{fieldName}}"); err == orm.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if r.m.{{fieldName}} == nil {
+		return nil, nil
+	}
+	return &{{relatedResolver}}{r.m.{{fieldName}}}, nil
+}
+
+`
+
+	GraphQLManyTPL = `func (r *{{resolverName}}) {{fieldName}}() ([]*{{relatedResolver}}, error) {
+	if _, err := orm.NewOrm().LoadRelated(r.m, "{{fieldName}}"); err != nil {
+		return nil, err
+	}
+	rs := make([]*{{relatedResolver}}, len(r.m.{{fieldName}}))
+	for i, m := range r.m.{{fieldName}} {
+		rs[i] = &{{relatedResolver}}{m}
+	}
+	return rs, nil
+}
+
+`
+
+	GraphQLListTPL = `// {{listName}} resolves the paginated list of {{modelName}}
+func (*Resolver) {{listName}}(args listArgs) ([]*{{resolverName}}, error) {
+	query, sortby, order, err := args.params()
+	if err != nil {
+		return nil, err
+	}
+	l, err := models.GetAll{{modelName}}(query, nil, sortby, order, int64(args.Offset), int64(args.Limit))
+	if err != nil {
+		return nil, err
+	}
+	rs := make([]*{{resolverName}}, 0, len(l))
+	for _, v := range l {
+		m := v.(models.{{modelName}})
+		rs = append(rs, &{{resolverName}}{&m})
+	}
+	return rs, nil
+}
+
+`
+
+	GraphQLGetTPL = `// {{modelName}} resolves the {{modelName}} of an id
+func (*Resolver) {{modelName}}(args struct{ ID graphqlgo.ID }) (*{{resolverName}}, error) {
+	id, err := parseID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	m, err := models.Get{{modelName}}ById({{idType}}(id))
+	if err == orm.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &{{resolverName}}{m}, nil
+}
+
+`
+
+	GraphQLInputTPL = `// {{inputName}} holds the fields a mutation sets on a {{modelName}}
+type {{inputName}} struct {
+{{inputFields}}}
+
+func (in *{{inputName}}) apply(m *models.{{modelName}}) error {
+{{applies}}	return nil
+}
+
+// Create{{modelName}} resolves the creation of a {{modelName}}
+func (*Resolver) Create{{modelName}}(args struct{ Input {{inputName}} }) (*{{resolverName}}, error) {
+	m := new(models.{{modelName}})
+	if err := args.Input.apply(m); err != nil {
+		return nil, err
+	}
+	if _, err := models.Add{{modelName}}(m); err != nil {
+		return nil, err
+	}
+	return &{{resolverName}}{m}, nil
+}
+
+// Update{{modelName}} resolves the update of the {{modelName}} of an id
+func (*Resolver) Update{{modelName}}(args struct {
+	ID    graphqlgo.ID
+	Input {{inputName}}
+}) (*{{resolverName}}, error) {
+	id, err := parseID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	m, err := models.Get{{modelName}}ById({{idType}}(id))
+	if err == orm.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := args.Input.apply(m); err != nil {
+		return nil, err
+	}
+	if err := models.Update{{modelName}}ById(m); err != nil {
+		return nil, err
+	}
+	return &{{resolverName}}{m}, nil
+}
+
+`
+
+	GraphQLApplyFkTPL = `	if in.{{fkName}} != nil {
+		id, err := parseID(*in.{{fkName}})
+		if err != nil {
+			return err
+		}
+		m.{{fieldName}} = &models.{{relatedModel}}{Id: {{relatedIdType}}(id)}
+	}
+`
+
+	GraphQLDeleteTPL = `// Delete{{modelName}} resolves the deletion of the {{modelName}} of an id
+func (*Resolver) Delete{{modelName}}(args struct{ ID graphqlgo.ID }) (bool, error) {
+	id, err := parseID(args.ID)
+	if err != nil {
+		return false, err
+	}
+	if err := models.Delete{{modelName}}({{idType}}(id)); err == orm.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+`
+)
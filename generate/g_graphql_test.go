@@ -0,0 +1,168 @@
+// Copyright 2013 bee authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package generate
+
+import (
+	"strings"
+	"testing"
+)
+
+const apiModelsSource = `package models
+
+import (
+	"time"
+
+	"github.com/astaxie/beego/orm"
+)
+
+type Author struct {
+	Id    int64     ` + "`orm:\"column(id);auto\"`" + `
+	Name  string    ` + "`orm:\"column(name);size(64)\"`" + `
+	Born  time.Time ` + "`orm:\"column(born);type(date);null\"`" + `
+	Books []*Book   ` + "`orm:\"reverse(many)\"`" + `
+}
+
+type Book struct {
+	Id     int     ` + "`orm:\"column(id);auto\"`" + `
+	Title  string  ` + "`orm:\"column(title)\"`" + `
+	Price  float64 ` + "`orm:\"column(price);digits(10);decimals(2)\"`" + `
+	Sold   uint64  ` + "`orm:\"column(sold)\"`" + `
+	Author *Author ` + "`orm:\"column(author_id);rel(fk)\"`" + `
+	Tags   []*Tag  ` + "`orm:\"rel(m2m)\"`" + `
+}
+
+type Tag struct {
+	Id   int    ` + "`orm:\"column(id);auto\"`" + `
+	Name string ` + "`orm:\"column(name)\"`" + `
+}
+
+type Setting struct {
+	Key   string ` + "`orm:\"column(key);pk\"`" + `
+	Value string ` + "`orm:\"column(value)\"`" + `
+}
+
+func init() {
+	orm.RegisterModel(new(Author), new(Book), new(Tag), new(Setting))
+}
+`
+
+// testAPIModels returns the models of apiModelsSource
+func testAPIModels(t *testing.T, tables string) []*apiModel {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	writeFile(t, dir, "models/models.go", apiModelsSource)
+	return apiModels(tables, dir)
+}
+
+func TestAPIModels(t *testing.T) {
+	tests := []struct {
+		tables string
+		want   []string
+	}{
+		{"", []string{"Author:id", "Book:id", "Setting", "Tag:id"}},
+		{"book,tag", []string{"Book:id", "Tag:id"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, m := range testAPIModels(t, tt.tables) {
+			name := m.name
+			if m.id != nil {
+				name += ":" + m.id.Tag.Column
+			}
+			got = append(got, name)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("apiModels(%q) = %v, want %v", tt.tables, got, tt.want)
+		}
+	}
+}
+
+func TestGraphqlSource(t *testing.T) {
+	schema, resolvers := graphqlSource(testAPIModels(t, ""), "app")
+	checkSource(t, "resolvers", resolvers)
+	if !strings.Contains(resolvers, "const Schema = `"+schema+"`") {
+		t.Error("resolvers do not embed the schema")
+	}
+
+	tests := []struct {
+		src  string
+		want []string
+		not  []string
+	}{
+		{
+			src: schema,
+			want: []string{
+				"scalar Time\n",
+				"type Author {\n  id: ID!\n  name: String!\n  born: Time!\n  books: [Book!]!\n}\n",
+				"input BookInput {\n  title: String\n  price: Float\n  sold: String\n  authorId: ID\n}\n",
+				"  books(query: String, sortby: String, order: String, offset: Int! = 0, limit: Int! = 10): [Book!]!\n  book(id: ID!): Book\n",
+				"  settings(query: String, sortby: String, order: String, offset: Int! = 0, limit: Int! = 10): [Setting!]!\n  tags(",
+				"  updateTag(id: ID!, input: TagInput!): Tag\n",
+			},
+			// a model without an integer id has its list query only
+			not: []string{"input SettingInput", "setting(", "createSetting"},
+		},
+		{
+			src: resolvers,
+			want: []string{
+				`"app/models"`,
+				"func (r *bookResolver) Author() (*authorResolver, error) {",
+				`orm.NewOrm().LoadRelated(r.m, "Tags")`,
+				"m.Author = &models.Author{Id: int64(id)}",
+				"m, err := models.GetAuthorById(int64(id))",
+				"m, err := models.GetBookById(int(id))",
+				// ids and 64-bit integers are strings, which Int would truncate
+				"return graphqlgo.ID(strconv.FormatInt(int64(r.m.Id), 10))",
+				"return strconv.FormatUint(uint64(r.m.Sold), 10)",
+				"v, err := strconv.ParseUint(*in.Sold, 10, 64)",
+				"return graphqlgo.Time{Time: r.m.Born}",
+			},
+			not: []string{"func (*Resolver) Setting(", "func (*Resolver) CreateSetting("},
+		},
+	}
+	for i, tt := range tests {
+		for _, w := range tt.want {
+			if !strings.Contains(tt.src, w) {
+				t.Errorf("%d: source does not contain %s", i, w)
+			}
+		}
+		for _, w := range tt.not {
+			if strings.Contains(tt.src, w) {
+				t.Errorf("%d: source contains %s", i, w)
+			}
+		}
+	}
+
+	// relations to models that are not generated are skipped
+	schema, resolvers = graphqlSource(testAPIModels(t, "book"), "app")
+	checkSource(t, "book resolvers", resolvers)
+	if strings.Contains(schema, "author") || strings.Contains(schema, "tags") || strings.Contains(schema, "scalar Time") {
+		t.Errorf("schema of the books only has relations:\n%s", schema)
+	}
+}
+
+func TestListQueryName(t *testing.T) {
+	tests := map[string]string{
+		"Book":   "books",
+		"News":   "newsList",
+		"Status": "statusList",
+		"Person": "persons",
+	}
+	for model, want := range tests {
+		if got := listQueryName(model); got != want {
+			t.Errorf("listQueryName(%q) = %q, want %q", model, got, want)
+		}
+	}
+}
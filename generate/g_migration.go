@@ -18,6 +18,8 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -35,143 +37,77 @@ const (
 type DBDriver interface {
 	GenerateCreateUp(tableName string) string
 	GenerateCreateDown(tableName string) string
+	GenerateAddColumns(tableName string) string
+	GenerateDropColumns(tableName string) string
+	GenerateCreateJoinTable(tableName, left, right string) string
 }
 
 type mysqlDriver struct{}
 
 func (m mysqlDriver) GenerateCreateUp(tableName string) string {
-	upsql := `m.SQL("CREATE TABLE ` + tableName + "(" + m.generateSQLFromFields(Fields.String()) + `)");`
-	return upsql
+	return createTableSQL(m, tableName)
 }
 
 func (m mysqlDriver) GenerateCreateDown(tableName string) string {
-	downsql := `m.SQL("DROP TABLE ` + "`" + tableName + "`" + `")`
-	return downsql
-}
-
-func (m mysqlDriver) generateSQLFromFields(fields string) string {
-	sql, tags := "", ""
-	fds := strings.Split(fields, ",")
-	for i, v := range fds {
-		kv := strings.SplitN(v, ":", 2)
-		if len(kv) != 2 {
-			beeLogger.Log.Error("Fields format is wrong. Should be: key:type,key:type " + v)
-			return ""
-		}
-		typ, tag := m.getSQLType(kv[1])
-		if typ == "" {
-			beeLogger.Log.Error("Fields format is wrong. Should be: key:type,key:type " + v)
-			return ""
-		}
-		if i == 0 && strings.ToLower(kv[0]) != "id" {
-			sql += "`id` int(11) NOT NULL AUTO_INCREMENT,"
-			tags = tags + "PRIMARY KEY (`id`),"
-		}
-		sql += "`" + utils.SnakeString(kv[0]) + "` " + typ + ","
-		if tag != "" {
-			tags = tags + fmt.Sprintf(tag, "`"+utils.SnakeString(kv[0])+"`") + ","
-		}
-	}
-	sql = strings.TrimRight(sql+tags, ",")
-	return sql
+	return dropTableSQL(m, tableName)
 }
 
-func (m mysqlDriver) getSQLType(ktype string) (tp, tag string) {
-	kv := strings.SplitN(ktype, ":", 2)
-	switch kv[0] {
-	case "string":
-		if len(kv) == 2 {
-			return "varchar(" + kv[1] + ") NOT NULL", ""
-		}
-		return "varchar(128) NOT NULL", ""
-	case "text":
-		return "longtext  NOT NULL", ""
-	case "auto":
-		return "int(11) NOT NULL AUTO_INCREMENT", ""
-	case "pk":
-		return "int(11) NOT NULL", "PRIMARY KEY (%s)"
-	case "datetime":
-		return "datetime NOT NULL", ""
-	case "int", "int8", "int16", "int32", "int64":
-		fallthrough
-	case "uint", "uint8", "uint16", "uint32", "uint64":
-		return "int(11) DEFAULT NULL", ""
-	case "bool":
-		return "tinyint(1) NOT NULL", ""
-	case "float32", "float64":
-		return "float NOT NULL", ""
-	case "float":
-		return "float NOT NULL", ""
-	}
-	return "", ""
+func (m mysqlDriver) GenerateAddColumns(tableName string) string {
+	return alterTableSQL(m.quote(tableName), addColumnClauses(m), true)
+}
+
+func (m mysqlDriver) GenerateDropColumns(tableName string) string {
+	return alterTableSQL(m.quote(tableName), dropColumnClauses(m), true)
+}
+
+func (m mysqlDriver) GenerateCreateJoinTable(tableName, left, right string) string {
+	return joinTableSQL(m.quote, tableName, left, right, "bigint")
 }
 
 type postgresqlDriver struct{}
 
 func (m postgresqlDriver) GenerateCreateUp(tableName string) string {
-	upsql := `m.SQL("CREATE TABLE ` + tableName + "(" + m.generateSQLFromFields(Fields.String()) + `)");`
-	return upsql
+	return createTableSQL(m, tableName)
 }
 
 func (m postgresqlDriver) GenerateCreateDown(tableName string) string {
-	downsql := `m.SQL("DROP TABLE ` + tableName + `")`
-	return downsql
-}
-
-func (m postgresqlDriver) generateSQLFromFields(fields string) string {
-	sql, tags := "", ""
-	fds := strings.Split(fields, ",")
-	for i, v := range fds {
-		kv := strings.SplitN(v, ":", 2)
-		if len(kv) != 2 {
-			beeLogger.Log.Error("Fields format is wrong. Should be: key:type,key:type " + v)
-			return ""
-		}
-		typ, tag := m.getSQLType(kv[1])
-		if typ == "" {
-			beeLogger.Log.Error("Fields format is wrong. Should be: key:type,key:type " + v)
-			return ""
-		}
-		if i == 0 && strings.ToLower(kv[0]) != "id" {
-			sql += "id serial primary key,"
-		}
-		sql += utils.SnakeString(kv[0]) + " " + typ + ","
-		if tag != "" {
-			tags = tags + fmt.Sprintf(tag, utils.SnakeString(kv[0])) + ","
-		}
-	}
-	if tags != "" {
-		sql = strings.TrimRight(sql+" "+tags, ",")
-	} else {
-		sql = strings.TrimRight(sql, ",")
-	}
-	return sql
+	return dropTableSQL(m, tableName)
 }
 
-func (m postgresqlDriver) getSQLType(ktype string) (tp, tag string) {
-	kv := strings.SplitN(ktype, ":", 2)
-	switch kv[0] {
-	case "string":
-		if len(kv) == 2 {
-			return "char(" + kv[1] + ") NOT NULL", ""
-		}
-		return "TEXT NOT NULL", ""
-	case "text":
-		return "TEXT NOT NULL", ""
-	case "auto", "pk":
-		return "serial primary key", ""
-	case "datetime":
-		return "TIMESTAMP WITHOUT TIME ZONE NOT NULL", ""
-	case "int", "int8", "int16", "int32", "int64":
-		fallthrough
-	case "uint", "uint8", "uint16", "uint32", "uint64":
-		return "integer DEFAULT NULL", ""
-	case "bool":
-		return "boolean NOT NULL", ""
-	case "float32", "float64", "float":
-		return "numeric NOT NULL", ""
-	}
-	return "", ""
+func (m postgresqlDriver) GenerateAddColumns(tableName string) string {
+	return alterTableSQL(m.quote(tableName), addColumnClauses(m), true)
+}
+
+func (m postgresqlDriver) GenerateDropColumns(tableName string) string {
+	return alterTableSQL(m.quote(tableName), dropColumnClauses(m), true)
+}
+
+func (m postgresqlDriver) GenerateCreateJoinTable(tableName, left, right string) string {
+	return joinTableSQL(func(name string) string { return name }, tableName, left, right, "bigint")
+}
+
+type sqliteDriver struct{}
+
+func (m sqliteDriver) GenerateCreateUp(tableName string) string {
+	return createTableSQL(m, tableName)
+}
+
+func (m sqliteDriver) GenerateCreateDown(tableName string) string {
+	return dropTableSQL(m, tableName)
+}
+
+// GenerateAddColumns for SQLite adds one column per statement, as ALTER TABLE cannot add several
+func (m sqliteDriver) GenerateAddColumns(tableName string) string {
+	return alterTableSQL(m.quote(tableName), addColumnClauses(m), false)
+}
+
+// GenerateDropColumns for SQLite needs SQLite 3.35.0 or later
+func (m sqliteDriver) GenerateDropColumns(tableName string) string {
+	return alterTableSQL(m.quote(tableName), dropColumnClauses(m), false)
+}
+
+func (m sqliteDriver) GenerateCreateJoinTable(tableName, left, right string) string {
+	return joinTableSQL(func(name string) string { return name }, tableName, left, right, "INTEGER")
 }
 
 func NewDBDriver() DBDriver {
@@ -180,12 +116,219 @@ func NewDBDriver() DBDriver {
 		return mysqlDriver{}
 	case "postgres":
 		return postgresqlDriver{}
+	case "sqlite":
+		return sqliteDriver{}
 	default:
 		beeLogger.Log.Fatal("Driver not supported")
 		return nil
 	}
 }
 
+var (
+	addColumnsRegex    = regexp.MustCompile(`^add_\w+?_to_(\w+)$`)
+	removeColumnsRegex = regexp.MustCompile(`^remove_\w+?_from_(\w+)$`)
+	joinTableRegex     = regexp.MustCompile(`^create_join_table_(\w+)$`)
+)
+
+// HasMigrationConvention reports whether the name of a migration follows one of the
+// conventions of MigrationSQL
+func HasMigrationConvention(mname string) bool {
+	return addColumnsRegex.MatchString(mname) || removeColumnsRegex.MatchString(mname) || joinTableRegex.MatchString(mname)
+}
+
+// MigrationSQL returns the statements of the Up and Down methods of a migration for the
+// configured driver. Migrations named add_x_to_table and remove_x_from_table add and drop
+// the columns given by -fields, create_join_table_a_b creates the join table of a and b.
+// Otherwise the table named after the migration is created from -fields.
+func MigrationSQL(mname string) (upsql, downsql string) {
+	dbMigrator := NewDBDriver()
+	if m := joinTableRegex.FindStringSubmatch(mname); m != nil {
+		left, right := joinTables(m[1])
+		table := left + "_" + right
+		beeLogger.Log.Infof("Creating join table '%s' of '%s' and '%s'", table, left, right)
+		return dbMigrator.GenerateCreateJoinTable(table, left, right), dbMigrator.GenerateCreateDown(table)
+	}
+	if m := addColumnsRegex.FindStringSubmatch(mname); m != nil {
+		requireFields("the columns to add")
+		beeLogger.Log.Infof("Adding columns to table '%s'", m[1])
+		return dbMigrator.GenerateAddColumns(m[1]), dbMigrator.GenerateDropColumns(m[1])
+	}
+	if m := removeColumnsRegex.FindStringSubmatch(mname); m != nil {
+		requireFields("the columns to remove, so that Down can add them back")
+		beeLogger.Log.Infof("Removing columns from table '%s'", m[1])
+		return dbMigrator.GenerateDropColumns(m[1]), dbMigrator.GenerateAddColumns(m[1])
+	}
+	return dbMigrator.GenerateCreateUp(mname), dbMigrator.GenerateCreateDown(mname)
+}
+
+// joinTables returns the tables joined by create_join_table_a_b. Tables with an underscore
+// in their name are given with -tables=a,b.
+func joinTables(name string) (left, right string) {
+	tables := strings.Split(name, "_")
+	if Tables != "" {
+		tables = strings.Split(Tables.String(), ",")
+	}
+	if len(tables) != 2 || strings.TrimSpace(tables[0]) == "" || strings.TrimSpace(tables[1]) == "" {
+		beeLogger.Log.Hint("Name the two tables with -tables, i.e. -tables=\"users,user_groups\"")
+		beeLogger.Log.Fatalf("Could not tell the tables joined by 'create_join_table_%s'", name)
+	}
+	return strings.TrimSpace(tables[0]), strings.TrimSpace(tables[1])
+}
+
+func requireFields(what string) {
+	if Fields == "" {
+		beeLogger.Log.Hintf("Give %s with -fields, i.e. -fields=\"email:string\"", what)
+		beeLogger.Log.Fatal("Fields option should not be empty")
+	}
+}
+
+// createTableSQL returns the m.SQL calls creating a table from the fields: the table with
+// an auto increment id when the fields have none, the indexes of the fields and the join
+// tables of the m2m relations. The columns are those of the model generated from the fields.
+func createTableSQL(d schemaDiffer, table string) string {
+	fds := mustParseFields()
+	var defs, stmts []string
+	if fds[0].Column() != "id" {
+		defs = append(defs, columnDef(d, &Column{Name: "Id", Type: "int64", Tag: &OrmTag{Auto: true, Column: "id"}}))
+	}
+	for _, f := range fds {
+		if f.Type != "m2m" {
+			defs = append(defs, columnDef(d, f.ormColumn()))
+		}
+	}
+	stmts = append(stmts, fmt.Sprintf("CREATE TABLE %s (%s)", d.quote(table), strings.Join(defs, ", ")))
+	for _, f := range fds {
+		if f.Index {
+			stmts = append(stmts, indexSQL(d, table, f.Column()))
+		}
+		if f.Type == "m2m" {
+			join, left, right := m2mTable(table, f)
+			stmts = append(stmts, fmt.Sprintf("CREATE TABLE %s (%s, %s, %s)", d.quote(join),
+				columnDef(d, &Column{Name: "Id", Type: "int64", Tag: &OrmTag{Auto: true, Column: "id"}}),
+				columnDef(d, &Column{Type: "*", KeyType: "int64", Tag: &OrmTag{RelFk: true, Column: left}}),
+				columnDef(d, &Column{Type: "*", KeyType: "int64", Tag: &OrmTag{RelFk: true, Column: right}})))
+		}
+	}
+	return sqlCalls(stmts)
+}
+
+// dropTableSQL returns the m.SQL calls dropping a table and the join tables of its m2m fields
+func dropTableSQL(d schemaDiffer, table string) string {
+	var stmts []string
+	if Fields != "" {
+		for _, f := range mustParseFields() {
+			if f.Type == "m2m" {
+				join, _, _ := m2mTable(table, f)
+				stmts = append(stmts, "DROP TABLE "+d.quote(join))
+			}
+		}
+	}
+	return sqlCalls(append(stmts, "DROP TABLE "+d.quote(table)))
+}
+
+// m2mTable returns the join table the orm uses for a m2m field of table, and its columns
+func m2mTable(table string, f field) (join, left, right string) {
+	rel := utils.SnakeString(f.Rel)
+	return table + "_" + rel + "s", table + "_id", rel + "_id"
+}
+
+// indexSQL returns the statement creating the index of a column
+func indexSQL(d schemaDiffer, table, column string) string {
+	return fmt.Sprintf("CREATE INDEX %s ON %s (%s)", d.quote("idx_"+table+"_"+column), d.quote(table), d.quote(column))
+}
+
+func sqlCalls(stmts []string) string {
+	var calls []string
+	for _, stmt := range stmts {
+		calls = append(calls, fmt.Sprintf("m.SQL(%q)", stmt))
+	}
+	return strings.Join(calls, "\n")
+}
+
+// addColumnClauses returns the ADD COLUMN clauses of the fields. The columns are nullable,
+// so that they can be added to tables which already have rows, unless they have a default.
+func addColumnClauses(d schemaDiffer) (clauses []string) {
+	for _, f := range mustParseFields() {
+		switch f.Type {
+		case "auto", "pk":
+			beeLogger.Log.Fatalf("Could not add primary key '%s' to an existing table", f.Name)
+		case "m2m":
+			beeLogger.Log.Hint("Create the join table of a m2m relation with create_join_table_[a]_[b]")
+			beeLogger.Log.Fatalf("Could not add m2m relation '%s' as a column", f.Name)
+		}
+		col := f.ormColumn()
+		col.Tag.Null = f.Default == ""
+		clauses = append(clauses, "ADD COLUMN "+columnDef(d, col))
+	}
+	return
+}
+
+// dropColumnClauses returns the DROP COLUMN clauses of the fields
+func dropColumnClauses(d schemaDiffer) (clauses []string) {
+	for _, f := range mustParseFields() {
+		if f.Type == "m2m" {
+			beeLogger.Log.Fatalf("Could not drop m2m relation '%s' as a column", f.Name)
+		}
+		clauses = append(clauses, "DROP COLUMN "+d.quote(f.Column()))
+	}
+	return
+}
+
+func mustParseFields() []field {
+	fds, err := parseFields(Fields.String())
+	if err != nil {
+		beeLogger.Log.Fatalf("Could not parse fields: %s", err)
+	}
+	return fds
+}
+
+// alterTableSQL returns the m.SQL calls applying the clauses to a table, in a single
+// statement when the database supports it
+func alterTableSQL(table string, clauses []string, combine bool) string {
+	if combine {
+		return fmt.Sprintf("m.SQL(%q)", "ALTER TABLE "+table+" "+strings.Join(clauses, ", "))
+	}
+	var stmts []string
+	for _, c := range clauses {
+		stmts = append(stmts, "ALTER TABLE "+table+" "+c)
+	}
+	return sqlCalls(stmts)
+}
+
+// joinTableSQL returns the m.SQL call creating the join table of left and right, whose
+// primary key is made of the ids of both. The ids have the type of the int64 Id of the
+// models generated from fields.
+func joinTableSQL(quote func(string) string, table, left, right, intType string) string {
+	leftCol, rightCol := quote(singular(left)+"_id"), quote(singular(right)+"_id")
+	return fmt.Sprintf("m.SQL(%q)", fmt.Sprintf("CREATE TABLE %s (%s %s NOT NULL, %s %s NOT NULL, PRIMARY KEY (%s, %s))",
+		quote(table), leftCol, intType, rightCol, intType, leftCol, rightCol))
+}
+
+// singular returns the singular of a table name in the simplest cases, i.e. users, categories
+// and addresses
+func singular(name string) string {
+	switch {
+	case strings.HasSuffix(name, "ies"):
+		return strings.TrimSuffix(name, "ies") + "y"
+	case strings.HasSuffix(name, "sses"), strings.HasSuffix(name, "xes"):
+		return strings.TrimSuffix(name, "es")
+	case strings.HasSuffix(name, "s") && !strings.HasSuffix(name, "ss"):
+		return strings.TrimSuffix(name, "s")
+	}
+	return name
+}
+
+// plural returns the plural of a model name in the simplest cases, i.e. Posts and Categories
+func plural(name string) string {
+	switch {
+	case len(name) > 1 && strings.HasSuffix(name, "y") && !strings.ContainsAny(name[len(name)-2:len(name)-1], "aeiouAEIOU"):
+		return strings.TrimSuffix(name, "y") + "ies"
+	case strings.HasSuffix(name, "s"), strings.HasSuffix(name, "x"), strings.HasSuffix(name, "ch"), strings.HasSuffix(name, "sh"):
+		return name + "es"
+	}
+	return name + "s"
+}
+
 // generateMigration generates migration file template for database schema update.
 // The generated file template consists of an up() method for updating schema and
 // a down() method for reverting the update.
@@ -209,13 +352,7 @@ func GenerateMigration(mname, upsql, downsql, curpath string) {
 		down := ""
 		if DDL != "" {
 			ddlSpec = "m.ddlSpec()"
-			switch strings.Title(DDL.String()) {
-			case "Create":
-				spec = strings.Replace(DDLSpecCreate, "{{StructName}}", utils.CamelCase(mname)+"_"+today, -1)
-			case "Alter":
-				spec = strings.Replace(DDLSpecAlter, "{{StructName}}", utils.CamelCase(mname)+"_"+today, -1)
-			}
-			spec = strings.Replace(spec, "{{tableName}}", mname, -1)
+			spec, up, down = generateDDLSpec(strings.ToLower(DDL.String()), mname, utils.CamelCase(mname)+"_"+today)
 		} else {
 			up = strings.Replace(MigrationUp, "{{UpSQL}}", upsql, -1)
 			up = strings.Replace(up, "{{StructName}}", utils.CamelCase(mname)+"_"+today, -1)
@@ -261,8 +398,7 @@ const (
 				*/
 				func(m *{{StructName}}) ddlSpec(){
 				m.CreateTable("{{tableName}}", "InnoDB", "utf8")
-				m.PriCol("id").SetAuto(true).SetNullable(false).SetDataType("INT(10)").SetUnsigned(true)
-
+				{{Columns}}
 				}
 				`
 	DDLSpecAlter = `
@@ -271,7 +407,14 @@ const (
 				*/
 				func(m *{{StructName}}) ddlSpec(){
 				m.AlterTable("{{tableName}}")
-
+				{{Columns}}
+				}
+				`
+	DDLIndexUp = `
+				// Run the migrations. Indexes are not supported by the DDL builder.
+				func (m *{{StructName}}) Up() {
+					m.Migration.Up()
+					{{UpSQL}}
 				}
 				`
 	MigrationUp = `
@@ -288,3 +431,160 @@ const (
 				}
 				`
 )
+
+// DDLPrimaryCol is the primary key added to the tables created by a DDL migration without an id field
+const DDLPrimaryCol = `m.PriCol("id").SetAuto(true).SetNullable(false).SetDataType("INT(10)").SetUnsigned(true)`
+
+// generateDDLSpec returns the ddlSpec of a DDL migration, which either creates or alters tableName,
+// and the Up and Down methods it needs. The Down of the beego migration reverses the spec.
+func generateDDLSpec(kind, tableName, structName string) (spec, up, down string) {
+	var tpl string
+	switch kind {
+	case "create":
+		tpl = DDLSpecCreate
+	case "alter":
+		tpl = DDLSpecAlter
+	default:
+		beeLogger.Log.Fatalf("Unknown DDL '%s'. Use either create or alter", kind)
+	}
+	var cols, indexes []string
+	if Fields != "" {
+		fds, err := parseFields(Fields.String())
+		if err != nil {
+			beeLogger.Log.Fatalf("Could not parse fields: %s", err)
+		}
+		hasID := false
+		for _, f := range fds {
+			hasID = hasID || f.Column() == "id"
+		}
+		if kind == "create" && !hasID {
+			cols = append(cols, DDLPrimaryCol)
+		}
+		for _, f := range fds {
+			col, err := ddlColumn(kind, tableName, f)
+			if err != nil {
+				beeLogger.Log.Fatalf("Could not generate the DDL of field '%s': %s", f.Name, err)
+			}
+			cols = append(cols, col)
+			if f.Remove {
+				continue
+			}
+			if f.Index || f.Unique && kind == "alter" {
+				indexes = append(indexes, ddlIndex(tableName, f))
+			}
+		}
+	} else if kind == "create" {
+		cols = append(cols, DDLPrimaryCol)
+	}
+	spec = strings.Replace(tpl, "{{StructName}}", structName, -1)
+	spec = strings.Replace(spec, "{{tableName}}", tableName, -1)
+	spec = strings.Replace(spec, "{{Columns}}", strings.Join(cols, "\n"), -1)
+	if len(indexes) > 0 {
+		up = strings.Replace(DDLIndexUp, "{{StructName}}", structName, -1)
+		up = strings.Replace(up, "{{UpSQL}}", strings.Join(indexes, "\n"), -1)
+	}
+	return
+}
+
+// ddlColumn returns the beego migration builder call adding, or removing, the column of a field
+func ddlColumn(kind, tableName string, f field) (string, error) {
+	dataType, unsigned, err := ddlDataType(f)
+	if err != nil {
+		return "", err
+	}
+	col := f.Column()
+	var call string
+	switch {
+	case col == "id" && kind == "create":
+		call = fmt.Sprintf("m.PriCol(%q)", col)
+		if f.Type == "auto" || strings.HasPrefix(f.Type, "int") || strings.HasPrefix(f.Type, "uint") {
+			call += ".SetAuto(true)"
+		}
+	case (f.Unique || f.Type == "o2o") && kind == "create":
+		call = fmt.Sprintf("m.UniCol(%q, %q)", "uk_"+tableName+"_"+col, col)
+	default:
+		call = fmt.Sprintf("m.NewCol(%q)", col)
+	}
+	call += fmt.Sprintf(".SetDataType(%q)", dataType)
+	if unsigned {
+		call += ".SetUnsigned(true)"
+	}
+	call += fmt.Sprintf(".SetNullable(%t)", f.Null && col != "id")
+	if f.Default != "" {
+		call += fmt.Sprintf(".SetDefault(%q)", sqlDefault(f))
+	}
+	if f.Remove {
+		if kind != "alter" {
+			return "", fmt.Errorf("columns can only be removed by an alter migration")
+		}
+		call += ".Remove()"
+	}
+	return call, nil
+}
+
+// ddlDataType returns the MySQL type of a field, as the DDL builder only generates MySQL
+func ddlDataType(f field) (dataType string, unsigned bool, err error) {
+	typ := strings.TrimPrefix(f.Type, "u")
+	unsigned = typ != f.Type && strings.HasPrefix(typ, "int")
+	switch typ {
+	case "string":
+		size := f.Size
+		if size == "" {
+			size = "128"
+		}
+		return "VARCHAR(" + size + ")", false, nil
+	case "text":
+		return "LONGTEXT", false, nil
+	case "auto", "pk":
+		return "INT(10)", true, nil
+	case "int", "int32":
+		return "INT(11)", unsigned, nil
+	case "int8":
+		return "TINYINT(4)", unsigned, nil
+	case "int16":
+		return "SMALLINT(6)", unsigned, nil
+	case "int64":
+		return "BIGINT(20)", unsigned, nil
+	case "bool":
+		return "TINYINT(1)", false, nil
+	case "float", "float32":
+		return "FLOAT", false, nil
+	case "float64":
+		return "DOUBLE", false, nil
+	case "decimal":
+		return "DECIMAL(" + f.Digits + "," + f.Decimals + ")", false, nil
+	case "datetime", "date", "time", "json":
+		return strings.ToUpper(typ), false, nil
+	case "fk", "o2o":
+		return "INT(10)", true, nil
+	case "m2m":
+		return "", false, fmt.Errorf("m2m relations are stored in a join table, create it with create_join_table_[a]_[b]")
+	}
+	return "", false, fmt.Errorf("unknown type '%s'", f.Type)
+}
+
+// sqlDefault returns the default value of a field as a SQL literal
+func sqlDefault(f field) string {
+	return sqlLiteral(f.Default, f.Type == "string" || f.Type == "text")
+}
+
+// sqlLiteral returns a default value as a SQL literal. Numbers are quoted for string columns.
+func sqlLiteral(value string, str bool) string {
+	switch strings.ToUpper(value) {
+	case "NULL", "CURRENT_TIMESTAMP", "TRUE", "FALSE":
+		return strings.ToUpper(value)
+	}
+	if _, err := strconv.ParseFloat(value, 64); err == nil && !str {
+		return value
+	}
+	return "'" + strings.Replace(value, "'", "''", -1) + "'"
+}
+
+// ddlIndex returns the statement adding the index of a field
+func ddlIndex(tableName string, f field) string {
+	col := f.Column()
+	if f.Unique {
+		return fmt.Sprintf("m.SQL(%q)", fmt.Sprintf("CREATE UNIQUE INDEX `uk_%s_%s` ON `%s` (`%s`)", tableName, col, tableName, col))
+	}
+	return fmt.Sprintf("m.SQL(%q)", fmt.Sprintf("CREATE INDEX `idx_%s_%s` ON `%s` (`%s`)", tableName, col, tableName, col))
+}
@@ -0,0 +1,219 @@
+// Copyright 2013 bee authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package generate
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/beego/bee/utils"
+)
+
+// setFlags sets the options of bee generate, restored by the returned function
+func setFlags(driver, fields, tables string) func() {
+	savedDriver, savedFields, savedTables := SQLDriver, Fields, Tables
+	SQLDriver, Fields, Tables = utils.DocValue(driver), utils.DocValue(fields), utils.DocValue(tables)
+	return func() { SQLDriver, Fields, Tables = savedDriver, savedFields, savedTables }
+}
+
+func TestGenerateDDLSpec(t *testing.T) {
+	tests := []struct {
+		kind, fields string
+		want         []string
+		wantUp       string
+	}{
+		{
+			kind:   "create",
+			fields: "",
+			want:   []string{`m.CreateTable("users", "InnoDB", "utf8")`, DDLPrimaryCol},
+		},
+		{
+			kind:   "create",
+			fields: "name:string:64,age:uint8,bio:text",
+			want: []string{
+				DDLPrimaryCol,
+				`m.NewCol("name").SetDataType("VARCHAR(64)").SetNullable(false)`,
+				`m.NewCol("age").SetDataType("TINYINT(4)").SetUnsigned(true).SetNullable(false)`,
+				`m.NewCol("bio").SetDataType("LONGTEXT").SetNullable(false)`,
+			},
+		},
+		{
+			kind:   "create",
+			fields: "id:int64,name:string",
+			want:   []string{`m.PriCol("id").SetAuto(true).SetDataType("BIGINT(20)").SetNullable(false)`},
+		},
+		{
+			kind:   "create",
+			fields: "name:string:64:unique,age:uint8:default(18):index,bio:text:null,author:fk:user",
+			want: []string{
+				`m.UniCol("uk_users_name", "name").SetDataType("VARCHAR(64)").SetNullable(false)`,
+				`m.NewCol("age").SetDataType("TINYINT(4)").SetUnsigned(true).SetNullable(false).SetDefault("18")`,
+				`m.NewCol("bio").SetDataType("LONGTEXT").SetNullable(true)`,
+				`m.NewCol("author_id").SetDataType("INT(10)").SetUnsigned(true).SetNullable(false)`,
+			},
+			wantUp: "m.SQL(\"CREATE INDEX `idx_users_age` ON `users` (`age`)\")",
+		},
+		{
+			kind:   "alter",
+			fields: "name:string:remove,email:string:unique",
+			want: []string{
+				`m.NewCol("name").SetDataType("VARCHAR(128)").SetNullable(false).Remove()`,
+				`m.NewCol("email").SetDataType("VARCHAR(128)").SetNullable(false)`,
+			},
+			wantUp: "m.SQL(\"CREATE UNIQUE INDEX `uk_users_email` ON `users` (`email`)\")",
+		},
+		{
+			kind:   "alter",
+			fields: "email:string",
+			want:   []string{`m.AlterTable("users")`, `m.NewCol("email").SetDataType("VARCHAR(128)").SetNullable(false)`},
+		},
+	}
+	for _, tt := range tests {
+		restore := setFlags("mysql", tt.fields, "")
+		spec, up, down := generateDDLSpec(tt.kind, "users", "Users_20200101_120000")
+		restore()
+
+		header := strings.Replace(MigrationHeader, "{{StructName}}", "Users_20200101_120000", -1)
+		header = strings.Replace(header, "{{ddlSpec}}", "m.ddlSpec()", -1)
+		header = strings.Replace(header, "{{CurrTime}}", "20200101_120000", -1)
+		checkSource(t, tt.kind+" "+tt.fields, header+spec+up+down)
+		for _, w := range tt.want {
+			if !strings.Contains(spec, w) {
+				t.Errorf("%s %q: spec does not contain %s:\n%s", tt.kind, tt.fields, w, spec)
+			}
+		}
+		if tt.kind == "alter" && strings.Contains(spec, DDLPrimaryCol) {
+			t.Errorf("alter %q: spec adds a primary key:\n%s", tt.fields, spec)
+		}
+		if !strings.Contains(up, tt.wantUp) {
+			t.Errorf("%s %q: Up does not contain %s:\n%s", tt.kind, tt.fields, tt.wantUp, up)
+		}
+	}
+}
+
+func TestMigrationSQL(t *testing.T) {
+	tests := []struct {
+		driver, name, fields, tables string
+		up, down                     string
+	}{
+		{
+			driver: "mysql", name: "add_email_to_users", fields: "email:string,age:int",
+			up:   "m.SQL(\"ALTER TABLE `users` ADD COLUMN `email` varchar(128) NULL, ADD COLUMN `age` int(11) NULL\")",
+			down: "m.SQL(\"ALTER TABLE `users` DROP COLUMN `email`, DROP COLUMN `age`\")",
+		},
+		{
+			driver: "postgres", name: "remove_email_from_users", fields: "email:string:64",
+			up:   `m.SQL("ALTER TABLE \"users\" DROP COLUMN \"email\"")`,
+			down: `m.SQL("ALTER TABLE \"users\" ADD COLUMN \"email\" varchar(64) NULL")`,
+		},
+		{
+			driver: "sqlite", name: "add_email_to_users", fields: "email:string,age:int",
+			up: `m.SQL("ALTER TABLE \"users\" ADD COLUMN \"email\" varchar(128) NULL")` + "\n" +
+				`m.SQL("ALTER TABLE \"users\" ADD COLUMN \"age\" INTEGER NULL")`,
+			down: `m.SQL("ALTER TABLE \"users\" DROP COLUMN \"email\"")` + "\n" +
+				`m.SQL("ALTER TABLE \"users\" DROP COLUMN \"age\"")`,
+		},
+		{
+			driver: "mysql", name: "create_join_table_users_categories",
+			up:   "m.SQL(\"CREATE TABLE `users_categories` (`user_id` bigint NOT NULL, `category_id` bigint NOT NULL, PRIMARY KEY (`user_id`, `category_id`))\")",
+			down: "m.SQL(\"DROP TABLE `users_categories`\")",
+		},
+		{
+			driver: "sqlite", name: "create_join_table_members", tables: "users, user_groups",
+			up:   `m.SQL("CREATE TABLE users_user_groups (user_id INTEGER NOT NULL, user_group_id INTEGER NOT NULL, PRIMARY KEY (user_id, user_group_id))")`,
+			down: `m.SQL("DROP TABLE \"users_user_groups\"")`,
+		},
+		{
+			driver: "mysql", name: "add_price_to_products", fields: "price:decimal(5,1):default(0),note:text",
+			up:   "m.SQL(\"ALTER TABLE `products` ADD COLUMN `price` decimal(5,1) NOT NULL DEFAULT 0, ADD COLUMN `note` longtext NULL\")",
+			down: "m.SQL(\"ALTER TABLE `products` DROP COLUMN `price`, DROP COLUMN `note`\")",
+		},
+		{
+			driver: "postgres", name: "comments", fields: "body:text,post:fk:post,tags:m2m:tag",
+			up: `m.SQL("CREATE TABLE \"comments\" (\"id\" bigserial PRIMARY KEY, \"body\" text NOT NULL, \"post_id\" bigint NOT NULL)")` + "\n" +
+				`m.SQL("CREATE TABLE \"comments_tags\" (\"id\" bigserial PRIMARY KEY, \"comments_id\" bigint NOT NULL, \"tag_id\" bigint NOT NULL)")`,
+			down: `m.SQL("DROP TABLE \"comments_tags\"")` + "\n" + `m.SQL("DROP TABLE \"comments\"")`,
+		},
+		{
+			driver: "sqlite", name: "posts", fields: "title:string:unique,views:int:index,tags:m2m:tag",
+			up: `m.SQL("CREATE TABLE \"posts\" (\"id\" INTEGER PRIMARY KEY AUTOINCREMENT, \"title\" varchar(128) NOT NULL UNIQUE, \"views\" INTEGER NOT NULL)")` + "\n" +
+				`m.SQL("CREATE INDEX \"idx_posts_views\" ON \"posts\" (\"views\")")` + "\n" +
+				`m.SQL("CREATE TABLE \"posts_tags\" (\"id\" INTEGER PRIMARY KEY AUTOINCREMENT, \"posts_id\" INTEGER NOT NULL, \"tag_id\" INTEGER NOT NULL)")`,
+			down: `m.SQL("DROP TABLE \"posts_tags\"")` + "\n" + `m.SQL("DROP TABLE \"posts\"")`,
+		},
+		{
+			driver: "postgres", name: "posts", fields: "title:string",
+			up:   `m.SQL("CREATE TABLE \"posts\" (\"id\" bigserial PRIMARY KEY, \"title\" varchar(128) NOT NULL)")`,
+			down: `m.SQL("DROP TABLE \"posts\"")`,
+		},
+	}
+	for _, tt := range tests {
+		restore := setFlags(tt.driver, tt.fields, tt.tables)
+		up, down := MigrationSQL(tt.name)
+		restore()
+		if up != tt.up {
+			t.Errorf("%s %s: up\n%s\nwant\n%s", tt.driver, tt.name, up, tt.up)
+		}
+		if down != tt.down {
+			t.Errorf("%s %s: down\n%s\nwant\n%s", tt.driver, tt.name, down, tt.down)
+		}
+	}
+}
+
+func TestHasMigrationConvention(t *testing.T) {
+	tests := map[string]bool{
+		"add_email_to_users":             true,
+		"remove_email_from_users":        true,
+		"create_join_table_users_groups": true,
+		"create_users":                   false,
+		"add_email":                      false,
+	}
+	for name, want := range tests {
+		if got := HasMigrationConvention(name); got != want {
+			t.Errorf("HasMigrationConvention(%q) = %t, want %t", name, got, want)
+		}
+	}
+}
+
+func TestSingular(t *testing.T) {
+	tests := map[string]string{
+		"users":      "user",
+		"categories": "category",
+		"addresses":  "address",
+		"boxes":      "box",
+		"class":      "class",
+	}
+	for name, want := range tests {
+		if got := singular(name); got != want {
+			t.Errorf("singular(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestPlural(t *testing.T) {
+	tests := map[string]string{
+		"Post":     "Posts",
+		"Category": "Categories",
+		"Day":      "Days",
+		"Address":  "Addresses",
+		"Box":      "Boxes",
+		"Branch":   "Branches",
+	}
+	for name, want := range tests {
+		if got := plural(name); got != want {
+			t.Errorf("plural(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -17,7 +17,10 @@ package generate
 import (
 	"errors"
 	"fmt"
+	"go/ast"
 	"go/format"
+	"go/parser"
+	"go/token"
 	"io/ioutil"
 	"os"
 	"path"
@@ -26,13 +29,9 @@ import (
 	beeLogger "github.com/beego/bee/logger"
 	"github.com/beego/bee/logger/colors"
 	"github.com/beego/bee/utils"
-	"github.com/flosch/pongo2"
-	"github.com/smartwalle/pongo2render"
 )
 
 func GenerateModel(mname, fields, currpath string) {
-	var render = pongo2render.NewRender("/home/www/server/opensource/bee-mod/default/v1/go/models")
-
 	w := colors.NewColorWriter(os.Stdout)
 
 	p, f := path.Split(mname)
@@ -43,10 +42,11 @@ func GenerateModel(mname, fields, currpath string) {
 		packageName = p[i+1 : len(p)-1]
 	}
 
-	modelStruct, hastime, err := getStruct(modelName, fields)
+	fds, err := parseFields(fields)
 	if err != nil {
 		beeLogger.Log.Fatalf("Could not generate the model struct: %s", err)
 	}
+	modelStruct, hastime := getStruct(modelName, fds)
 
 	beeLogger.Log.Infof("Using '%s' as model name", modelName)
 	beeLogger.Log.Infof("Using '%s' as package name", packageName)
@@ -59,93 +59,108 @@ func GenerateModel(mname, fields, currpath string) {
 		}
 	}
 
-	ctx := pongo2.Context{
-		"packageName": packageName,
-		"name":        modelName,
-		"modelStruct": modelStruct,
-	}
 	fpath := path.Join(fp, strings.ToLower(modelName)+".go")
-
+	content := strings.Replace(ModelTPL, "{{packageName}}", packageName, -1)
+	content = strings.Replace(content, "{{modelStruct}}", modelStruct, 1)
+	content = strings.Replace(content, "{{modelName}}", modelName, -1)
+	content = strings.Replace(content, "{{tableName}}", utils.SnakeString(modelName), -1)
+	content = strings.Replace(content, "{{pkType}}", "int64", -1)
+	timePkg := ""
 	if hastime {
-		ctx["timePkg"] = `"time"`
-	} else {
-		ctx["timePkg"] = ""
+		timePkg = "\"time\"\n"
 	}
-
-	buf, err := render.Template("model.go.tmpl").Execute(ctx)
-	if err != nil {
-		beeLogger.Log.Fatalf("Could not create the model render tmpl: %s", err)
-	}
-	err = write(fpath, buf)
+	content = strings.Replace(content, "{{timePkg}}", timePkg, -1)
+	err = write(fpath, content)
 	if err != nil {
 		beeLogger.Log.Fatalf("Could not create model file: %s", err)
 		return
 	}
 	_, _ = fmt.Fprintf(w, "\t%s%screate%s\t %s%s\n", "\x1b[32m", "\x1b[1m", "\x1b[21m", fpath, "\x1b[0m")
-}
 
-func getStruct(structname, fields string) (string, bool, error) {
-	if fields == "" {
-		return "", false, errors.New("fields cannot be empty")
+	for _, f := range fds {
+		if f.IsRelation() {
+			addReverseField(fp, modelName, f)
+		}
 	}
+}
 
+// getStruct returns the model struct of the fields, and whether it uses the time package.
+// An auto increment Id is added when the fields have no id.
+func getStruct(structname string, fds []field) (string, bool) {
 	hastime := false
 	structStr := "type " + structname + " struct{\n"
-	fds := strings.Split(fields, ",")
-	for i, v := range fds {
-		kv := strings.SplitN(v, ":", 2)
-		if len(kv) != 2 {
-			return "", false, errors.New("the fields format is wrong. Should be key:type,key:type " + v)
-		}
-
-		typ, tag, hastimeinner := getType(kv[1])
-		if typ == "" {
-			return "", false, errors.New("the fields format is wrong. Should be key:type,key:type " + v)
-		}
-
-		if i == 0 && strings.ToLower(kv[0]) != "id" {
-			structStr = structStr + "Id     int64     `orm:\"auto\"`\n"
-		}
-
-		if hastimeinner {
+	if fds[0].Column() != "id" {
+		structStr = structStr + "Id     int64     `orm:\"auto\"`\n"
+	}
+	for _, f := range fds {
+		col := f.ormColumn()
+		// the orm derives the column from the field name
+		col.Tag.Column = ""
+		if col.Type == "time.Time" {
 			hastime = true
 		}
-		structStr = structStr + utils.CamelString(kv[0]) + "       " + typ + "     " + tag + "\n"
+		structStr = structStr + col.String() + "\n"
 	}
 	structStr += "}\n"
-	return structStr, hastime, nil
+	return structStr, hastime
 }
 
-// fields support type
-// http://beego.me/docs/mvc/model/models.md#mysql
-func getType(ktype string) (kt, tag string, hasTime bool) {
-	kv := strings.SplitN(ktype, ":", 2)
-	switch kv[0] {
-	case "string":
-		if len(kv) == 2 {
-			return "string", "`orm:\"size(" + kv[1] + ")\"`", false
+// addReverseField adds the reverse side of a relation to the struct of the related model,
+// i.e. Posts []*Post `orm:"reverse(many)"` to User for author:fk:User
+func addReverseField(dir, modelName string, f field) {
+	reverse := &Column{Name: plural(modelName), Type: "[]*" + modelName, Tag: &OrmTag{ReverseMany: true}}
+	if f.Type == "o2o" {
+		reverse = &Column{Name: modelName, Type: "*" + modelName, Tag: &OrmTag{ReverseOne: true}}
+	}
+	hint := func() {
+		beeLogger.Log.Hintf("Add %s to model '%s'", reverse, f.Rel)
+	}
+	if f.Rel == modelName {
+		hint()
+		return
+	}
+	fpath := path.Join(dir, strings.ToLower(f.Rel)+".go")
+	src, err := ioutil.ReadFile(fpath)
+	if err != nil {
+		beeLogger.Log.Warnf("Could not find model '%s' in '%s'", f.Rel, fpath)
+		hint()
+		return
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, fpath, src, 0)
+	if err != nil {
+		beeLogger.Log.Warnf("Could not parse model '%s': %s", f.Rel, err)
+		hint()
+		return
+	}
+	var st *ast.StructType
+	if obj := file.Scope.Lookup(f.Rel); obj != nil {
+		if spec, ok := obj.Decl.(*ast.TypeSpec); ok {
+			st, _ = spec.Type.(*ast.StructType)
+		}
+	}
+	if st == nil {
+		beeLogger.Log.Warnf("Could not find the struct of model '%s' in '%s'", f.Rel, fpath)
+		hint()
+		return
+	}
+	for _, fd := range st.Fields.List {
+		if typ := exprString(fd.Type); typ == reverse.Type {
+			return
 		}
-		return "string", "`orm:\"size(128)\"`", false
-	case "text":
-		return "string", "`orm:\"type(longtext)\"`", false
-	case "auto":
-		return "int64", "`orm:\"auto\"`", false
-	case "pk":
-		return "int64", "`orm:\"pk\"`", false
-	case "datetime":
-		return "time.Time", "`orm:\"type(datetime)\"`", true
-	case "int", "int8", "int16", "int32", "int64":
-		fallthrough
-	case "uint", "uint8", "uint16", "uint32", "uint64":
-		fallthrough
-	case "bool":
-		fallthrough
-	case "float32", "float64":
-		return kv[0], "", false
-	case "float":
-		return "float64", "", false
-	}
-	return "", "", false
+	}
+	offset := fset.Position(st.Fields.Closing).Offset
+	content := string(src[:offset]) + reverse.String() + "\n" + string(src[offset:])
+	formatted, err := format.Source([]byte(content))
+	if err != nil {
+		beeLogger.Log.Warnf("Could not format model '%s': %s", f.Rel, err)
+		hint()
+		return
+	}
+	if err := ioutil.WriteFile(fpath, formatted, 0644); err != nil {
+		beeLogger.Log.Fatalf("Could not write model '%s': %s", f.Rel, err)
+	}
+	beeLogger.Log.Infof("Added '%s' to model '%s'", reverse.Name, f.Rel)
 }
 
 // write 写bytes到文件
@@ -192,3 +207,56 @@ func createPath(filePath string) error {
 	}
 	return nil
 }
+
+// apiModel is a model exposed by a generated API, i.e. the GraphQL schema or the gRPC services
+type apiModel struct {
+	tb   *Table
+	name string  // name of the model struct
+	id   *Column // the integer Id primary key the model functions take, if any
+}
+
+// apiModels returns the models registered in the models directory, only those of
+// the tables given separated by a comma if any
+func apiModels(tables, currpath string) (models []*apiModel) {
+	selected := make(map[string]bool)
+	if tables != "" {
+		for _, name := range strings.Split(tables, ",") {
+			selected[name] = true
+		}
+	}
+	for _, tb := range parseModels(path.Join(currpath, "models")) {
+		if len(selected) != 0 && !selected[tb.Name] {
+			continue
+		}
+		models = append(models, &apiModel{tb: tb, name: tb.Model, id: idColumn(tb)})
+	}
+	if len(models) == 0 {
+		beeLogger.Log.Fatal("No model found, generate models first, i.e. bee generate appcode")
+	}
+	return
+}
+
+// idColumn returns the column of the Id primary key of a model, if it is an integer
+func idColumn(tb *Table) *Column {
+	for _, col := range tb.Columns {
+		if col.Name != "Id" || col.Tag.Column != tb.Pk {
+			continue
+		}
+		switch col.Type {
+		case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64":
+			return col
+		}
+	}
+	return nil
+}
+
+// relatedModel returns the model of a relation field, i.e. Author for *Author or []*Author
+func relatedModel(col *Column, models []*apiModel) *apiModel {
+	name := strings.TrimLeft(col.Type, "[]*")
+	for _, m := range models {
+		if m.name == name {
+			return m
+		}
+	}
+	return nil
+}
@@ -0,0 +1,342 @@
+// Copyright 2013 bee authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package generate
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	beeLogger "github.com/beego/bee/logger"
+)
+
+// protoTypes are the proto types of the Go types of the model fields, and the Go
+// type protoc-gen-go generates for them
+var protoTypes = map[string][2]string{
+	"int":       {"int64", "int64"},
+	"int8":      {"int32", "int32"},
+	"int16":     {"int32", "int32"},
+	"int32":     {"int32", "int32"},
+	"int64":     {"int64", "int64"},
+	"uint":      {"uint64", "uint64"},
+	"uint8":     {"uint32", "uint32"},
+	"uint16":    {"uint32", "uint32"},
+	"uint32":    {"uint32", "uint32"},
+	"uint64":    {"uint64", "uint64"},
+	"float32":   {"float", "float32"},
+	"float64":   {"double", "float64"},
+	"string":    {"string", "string"},
+	"bool":      {"bool", "bool"},
+	"[]byte":    {"bytes", "[]byte"},
+	"time.Time": {"google.protobuf.Timestamp", "*timestamppb.Timestamp"},
+}
+
+// GenerateProto generates the proto messages of the beego orm models, a CRUD
+// service per model and the Go server implementing them with the functions of
+// the models used by the controllers
+func GenerateProto(tables, currpath string) {
+	pkgPath := getPackagePath(currpath)
+	var models []*apiModel
+	for _, m := range apiModels(tables, currpath) {
+		switch m.name {
+		case "GetAllRequest":
+			beeLogger.Log.Warnf("Model '%s' has the name of a message of the services: skipped it", m.name)
+			continue
+		}
+		if m.id == nil {
+			beeLogger.Log.Warnf("Model '%s' has no integer Id primary key: generated its message only", m.name)
+		}
+		models = append(models, m)
+	}
+
+	protoPath := path.Join(currpath, "proto")
+	if err := os.MkdirAll(protoPath, 0777); err != nil {
+		beeLogger.Log.Fatalf("Could not create proto directory: %s", err)
+	}
+	proto, server := protoSource(models, pkgPath)
+	writeGeneratedFile(path.Join(protoPath, "models.proto"), proto)
+	if server != "" {
+		writeGeneratedFile(path.Join(protoPath, "server.go"), server)
+	}
+	beeLogger.Log.Hint("Generate the Go code with protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative proto/models.proto")
+	beeLogger.Log.Hint("Serve the services with proto.Register(s), s being a grpc.Server")
+}
+
+// protoSource returns the proto file of the models and the source of their server
+func protoSource(models []*apiModel, pkgPath string) (string, string) {
+	var messages, services, servers, registers strings.Builder
+	useTime, useEmpty := false, false
+	for _, m := range models {
+		var fields, toProto, fromProto, toOptional, fromOptional strings.Builder
+		n := 0
+		for _, col := range m.tb.Columns {
+			name := strings.ToLower(col.Tag.Column)
+			goName := protoGoName(name)
+			if col.Tag.RelFk || col.Tag.RelOne {
+				// the relation is sent as the id of the related model
+				related := relatedModel(col, models)
+				if related == nil || related.id == nil {
+					beeLogger.Log.Warnf("Field '%s.%s' relates to a model without integer Id primary key: skipped it", m.name, col.Name)
+					continue
+				}
+				typ := protoTypes[related.id.Type]
+				n++
+				fmt.Fprintf(&fields, "  %s %s = %d;\n", typ[0], name, n)
+				fmt.Fprintf(&toOptional, "\tif m.%s != nil {\n\t\tp.%s = %s\n\t}\n", col.Name, goName, convert(typ[1], related.id.Type, "m."+col.Name+".Id"))
+				fmt.Fprintf(&fromOptional, "\tif p.%s != 0 {\n\t\tm.%s = &models.%s{Id: %s}\n\t}\n", goName, col.Name, related.name, convert(related.id.Type, typ[1], "p."+goName))
+				continue
+			}
+			typ, ok := protoTypes[col.Type]
+			if !ok {
+				beeLogger.Log.Warnf("Field '%s.%s' of type '%s' has no proto type: skipped it", m.name, col.Name, col.Type)
+				continue
+			}
+			n++
+			fmt.Fprintf(&fields, "  %s %s = %d;\n", typ[0], name, n)
+			if col.Type == "time.Time" {
+				useTime = true
+				fmt.Fprintf(&toProto, "\t\t%s: timestamppb.New(m.%s),\n", goName, col.Name)
+				fmt.Fprintf(&fromOptional, "\tif p.%s != nil {\n\t\tm.%s = p.%s.AsTime()\n\t}\n", goName, col.Name, goName)
+				continue
+			}
+			fmt.Fprintf(&toProto, "\t\t%s: %s,\n", goName, convert(typ[1], col.Type, "m."+col.Name))
+			fmt.Fprintf(&fromProto, "\t\t%s: %s,\n", col.Name, convert(col.Type, typ[1], "p."+goName))
+		}
+		fmt.Fprintf(&messages, "message %s {\n%s}\n\n", m.name, fields.String())
+		if m.id == nil {
+			continue
+		}
+
+		useEmpty = true
+		idType := protoTypes[m.id.Type]
+		fmt.Fprintf(&messages, "message %sId {\n  %s id = 1;\n}\n\n", m.name, idType[0])
+		fmt.Fprintf(&messages, "message %sList {\n  repeated %s items = 1;\n}\n\n", m.name, m.name)
+		services.WriteString(strings.Replace(ProtoServiceTPL, "{{modelName}}", m.name, -1))
+		fmt.Fprintf(&registers, "\tRegister%sServiceServer(s, new(%sServer))\n", m.name, m.name)
+
+		server := strings.Replace(ProtoServerTPL, "{{toProto}}", toProto.String(), -1)
+		server = strings.Replace(server, "{{fromProto}}", fromProto.String(), -1)
+		server = strings.Replace(server, "{{toOptional}}", toOptional.String(), -1)
+		server = strings.Replace(server, "{{fromOptional}}", fromOptional.String(), -1)
+		server = strings.Replace(server, "{{protoId}}", convert(idType[1], "int64", "id"), -1)
+		server = strings.Replace(server, "{{modelId}}", convert(m.id.Type, idType[1], "in.Id"), -1)
+		server = strings.Replace(server, "{{modelName}}", m.name, -1)
+		server = strings.Replace(server, "{{varName}}", gqlName(m.name), -1)
+		servers.WriteString(server)
+	}
+
+	pkg := strings.Replace(path.Base(pkgPath), "-", "_", -1)
+	var imports strings.Builder
+	if useEmpty {
+		imports.WriteString("import \"google/protobuf/empty.proto\";\n")
+	}
+	if useTime {
+		imports.WriteString("import \"google/protobuf/timestamp.proto\";\n")
+	}
+	if imports.Len() != 0 {
+		imports.WriteString("\n")
+	}
+	proto := strings.Replace(ProtoTPL, "{{package}}", pkg, -1)
+	proto = strings.Replace(proto, "{{goPackage}}", pkgPath+"/proto;proto", -1)
+	proto = strings.Replace(proto, "{{imports}}", imports.String(), -1)
+	proto = strings.Replace(proto, "{{messages}}", messages.String(), -1)
+	proto = strings.Replace(proto, "{{services}}", services.String(), -1)
+	if registers.Len() == 0 {
+		return proto, ""
+	}
+
+	timePkg := ""
+	if useTime {
+		timePkg = "\"google.golang.org/protobuf/types/known/timestamppb\"\n"
+	}
+	fileStr := strings.Replace(ProtoServerFileTPL, "{{pkgPath}}", pkgPath, -1)
+	fileStr = strings.Replace(fileStr, "{{timePkg}}", timePkg, -1)
+	fileStr = strings.Replace(fileStr, "{{registers}}", registers.String(), -1)
+	fileStr = strings.Replace(fileStr, "{{servers}}", servers.String(), -1)
+	return proto, fileStr
+}
+
+// protoGoName returns the name protoc-gen-go gives to the Go field of a proto
+// field, i.e. AuthorId for author_id or Line2B for line2b
+func protoGoName(name string) string {
+	var b []byte
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case c == '_' && i == 0:
+			b = append(b, 'X')
+		case c == '_' && i+1 < len(name) && 'a' <= name[i+1] && name[i+1] <= 'z':
+		case '0' <= c && c <= '9':
+			b = append(b, c)
+		default:
+			if 'a' <= c && c <= 'z' {
+				c -= 'a' - 'A'
+			}
+			b = append(b, c)
+			for ; i+1 < len(name) && 'a' <= name[i+1] && name[i+1] <= 'z'; i++ {
+				b = append(b, name[i+1])
+			}
+		}
+	}
+	return string(b)
+}
+
+// convert returns expr of type from converted to type to
+func convert(to, from, expr string) string {
+	if to == from {
+		return expr
+	}
+	return to + "(" + expr + ")"
+}
+
+const (
+	ProtoTPL = `syntax = "proto3";
+
+package {{package}};
+
+{{imports}}option go_package = "{{goPackage}}";
+
+// GetAllRequest holds the parameters of the GetAll functions of the models
+message GetAllRequest {
+  map<string, string> query = 1; // k:v
+  repeated string sortby = 2;
+  repeated string order = 3; // desc or asc
+  int64 offset = 4;
+  int64 limit = 5; // 10 when not set
+}
+
+{{messages}}{{services}}// bee:custom begin definitions
+// bee:custom end
+`
+
+	ProtoServiceTPL = `service {{modelName}}Service {
+  rpc Add{{modelName}}({{modelName}}) returns ({{modelName}}Id);
+  rpc Get{{modelName}}ById({{modelName}}Id) returns ({{modelName}});
+  rpc GetAll{{modelName}}(GetAllRequest) returns ({{modelName}}List);
+  rpc Update{{modelName}}ById({{modelName}}) returns (google.protobuf.Empty);
+  rpc Delete{{modelName}}({{modelName}}Id) returns (google.protobuf.Empty);
+}
+
+`
+
+	ProtoServerFileTPL = `package proto
+
+import (
+	"context"
+
+	"{{pkgPath}}/models"
+
+	"github.com/astaxie/beego/orm"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	{{timePkg}})
+
+// bee:custom begin imports
+// bee:custom end
+
+// Register registers the services of the models with s
+func Register(s *grpc.Server) {
+{{registers}}}
+
+// statusError returns the status of an error of the models
+func statusError(err error) error {
+	if err == orm.ErrNoRows {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// limit returns the limit of a GetAllRequest, 10 when it is not set
+func limit(in *GetAllRequest) int64 {
+	if in.Limit == 0 {
+		return 10
+	}
+	return in.Limit
+}
+
+{{servers}}// bee:custom begin methods
+// bee:custom end
+`
+
+	ProtoServerTPL = `// {{modelName}}Server implements {{modelName}}Service with the functions of models.{{modelName}}
+type {{modelName}}Server struct {
+	Unimplemented{{modelName}}ServiceServer
+}
+
+func {{varName}}ToProto(m *models.{{modelName}}) *{{modelName}} {
+	p := &{{modelName}}{
+{{toProto}}	}
+{{toOptional}}	return p
+}
+
+func {{varName}}FromProto(p *{{modelName}}) *models.{{modelName}} {
+	m := &models.{{modelName}}{
+{{fromProto}}	}
+{{fromOptional}}	return m
+}
+
+// Add{{modelName}} inserts a {{modelName}} and returns its id
+func (*{{modelName}}Server) Add{{modelName}}(ctx context.Context, in *{{modelName}}) (*{{modelName}}Id, error) {
+	id, err := models.Add{{modelName}}({{varName}}FromProto(in))
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &{{modelName}}Id{Id: {{protoId}}}, nil
+}
+
+// Get{{modelName}}ById returns the {{modelName}} of an id
+func (*{{modelName}}Server) Get{{modelName}}ById(ctx context.Context, in *{{modelName}}Id) (*{{modelName}}, error) {
+	m, err := models.Get{{modelName}}ById({{modelId}})
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return {{varName}}ToProto(m), nil
+}
+
+// GetAll{{modelName}} returns a page of {{modelName}}
+func (*{{modelName}}Server) GetAll{{modelName}}(ctx context.Context, in *GetAllRequest) (*{{modelName}}List, error) {
+	l, err := models.GetAll{{modelName}}(in.Query, nil, in.Sortby, in.Order, in.Offset, limit(in))
+	if err != nil {
+		return nil, statusError(err)
+	}
+	list := &{{modelName}}List{Items: make([]*{{modelName}}, 0, len(l))}
+	for _, v := range l {
+		m := v.(models.{{modelName}})
+		list.Items = append(list.Items, {{varName}}ToProto(&m))
+	}
+	return list, nil
+}
+
+// Update{{modelName}}ById updates the {{modelName}} of the id of in
+func (*{{modelName}}Server) Update{{modelName}}ById(ctx context.Context, in *{{modelName}}) (*emptypb.Empty, error) {
+	if err := models.Update{{modelName}}ById({{varName}}FromProto(in)); err != nil {
+		return nil, statusError(err)
+	}
+	return new(emptypb.Empty), nil
+}
+
+// Delete{{modelName}} deletes the {{modelName}} of an id
+func (*{{modelName}}Server) Delete{{modelName}}(ctx context.Context, in *{{modelName}}Id) (*emptypb.Empty, error) {
+	if err := models.Delete{{modelName}}({{modelId}}); err != nil {
+		return nil, statusError(err)
+	}
+	return new(emptypb.Empty), nil
+}
+
+`
+)
@@ -0,0 +1,85 @@
+// Copyright 2013 bee authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package generate
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestProtoSource(t *testing.T) {
+	proto, server := protoSource(testAPIModels(t, ""), "github.com/me/my-app")
+	checkSource(t, "server", server)
+
+	tests := []struct {
+		src  string
+		want []string
+	}{
+		{
+			src: proto,
+			want: []string{
+				"package my_app;\n",
+				"import \"google/protobuf/empty.proto\";\nimport \"google/protobuf/timestamp.proto\";\n",
+				`option go_package = "github.com/me/my-app/proto;proto";`,
+				"message Author {\n  int64 id = 1;\n  string name = 2;\n  google.protobuf.Timestamp born = 3;\n}\n",
+				"message Book {\n  int64 id = 1;\n  string title = 2;\n  double price = 3;\n  uint64 sold = 4;\n  int64 author_id = 5;\n}\n",
+				"message Setting {\n  string key = 1;\n  string value = 2;\n}\n",
+				"  rpc GetBookById(BookId) returns (Book);\n",
+			},
+		},
+		{
+			src: server,
+			want: []string{
+				"\tRegisterTagServiceServer(s, new(TagServer))\n",
+				"\t\tBorn: timestamppb.New(m.Born),\n",
+				"\tif p.AuthorId != 0 {\n\t\tm.Author = &models.Author{Id: p.AuthorId}\n\t}\n",
+				"\t\tId: int64(m.Id),\n",
+				"m, err := models.GetBookById(int(in.Id))",
+			},
+		},
+	}
+	for i, tt := range tests {
+		for _, w := range tt.want {
+			if !strings.Contains(tt.src, w) {
+				t.Errorf("%d: source does not contain %s", i, w)
+			}
+		}
+	}
+	// a model without an integer id has its message only
+	if strings.Contains(proto, "SettingService") || strings.Contains(server, "SettingServer") {
+		t.Error("service generated for a model without an integer id")
+	}
+
+	proto, server = protoSource(testAPIModels(t, "setting"), "app")
+	if server != "" || strings.Contains(proto, "import") {
+		t.Errorf("services generated for the settings only:\n%s\n%s", proto, server)
+	}
+}
+
+func TestProtoGoName(t *testing.T) {
+	tests := map[string]string{
+		"id":         "Id",
+		"author_id":  "AuthorId",
+		"line2b":     "Line2B",
+		"_private":   "XPrivate",
+		"a__b":       "A_B",
+		"created_at": "CreatedAt",
+	}
+	for name, want := range tests {
+		if got := protoGoName(name); got != want {
+			t.Errorf("protoGoName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
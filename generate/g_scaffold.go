@@ -1,7 +1,6 @@
 package generate
 
 import (
-	"github.com/beego/bee/generate/beegopro"
 	"strings"
 
 	"github.com/beego/bee/cmd/commands/migrate"
@@ -14,26 +13,13 @@ func GenerateScaffold(sname, fields, currpath, driver, conn string) {
 
 	// Generate the model
 	if utils.AskForConfirmation() {
-		schemas := make([]beegopro.Schema, 0)
-		fds := strings.Split(fields, ",")
-		for _, v := range fds {
-			kv := strings.SplitN(v, ":", 2)
-			if len(kv) != 2 {
-				beeLogger.Log.Error("Fields format is wrong. Should be: key:type,key:type " + v)
-				return
-			}
-			schemas = append(schemas, beegopro.Schema{
-				Name: kv[0],
-				Type: kv[1],
-			})
-		}
+		GenerateModel(sname, fields, currpath)
+	}
 
-		beegopro.DefaultBeegoPro.TextRenderModel(sname, beegopro.ModelsContent{
-			Schema:    schemas,
-			SourceGen: "text",
-			ApiPrefix: "/",
-		})
-		//GenerateModel(sname, fields, currpath)
+	// Generate the views first, so that the controller serves them
+	beeLogger.Log.Infof("Do you want to create views for this '%s' resource? [Yes|No] ", sname)
+	if utils.AskForConfirmation() {
+		GenerateView(sname, fields, currpath)
 	}
 
 	// Generate the controller
@@ -42,12 +28,6 @@ func GenerateScaffold(sname, fields, currpath, driver, conn string) {
 		GenerateController(sname, currpath)
 	}
 
-	// Generate the views
-	beeLogger.Log.Infof("Do you want to create views for this '%s' resource? [Yes|No] ", sname)
-	if utils.AskForConfirmation() {
-		GenerateView(sname, currpath)
-	}
-
 	// Generate a migration
 	beeLogger.Log.Infof("Do you want to create a '%s' migration and schema for this resource? [Yes|No] ", sname)
 	if utils.AskForConfirmation() {
@@ -55,8 +35,8 @@ func GenerateScaffold(sname, fields, currpath, driver, conn string) {
 		downsql := ""
 		if fields != "" {
 			dbMigrator := NewDBDriver()
-			upsql = dbMigrator.GenerateCreateUp(sname)
-			downsql = dbMigrator.GenerateCreateDown(sname)
+			upsql = dbMigrator.GenerateCreateUp(utils.SnakeString(sname))
+			downsql = dbMigrator.GenerateCreateDown(utils.SnakeString(sname))
 		}
 		GenerateMigration(sname, upsql, downsql, currpath)
 	}
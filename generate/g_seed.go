@@ -0,0 +1,91 @@
+// Copyright 2013 bee authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package generate
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	beeLogger "github.com/beego/bee/logger"
+	"github.com/beego/bee/logger/colors"
+	"github.com/beego/bee/utils"
+)
+
+const SPath = "seeds"
+
+// GenerateSeed creates a SQL or Go seed file in database/seeds.
+// Seeds are applied in file name order by 'bee migrate seed'.
+func GenerateSeed(sname, lang, runmodes, curpath string) {
+	w := colors.NewColorWriter(os.Stdout)
+	seedFilePath := path.Join(curpath, DBPath, SPath)
+	if err := os.MkdirAll(seedFilePath, 0777); err != nil {
+		beeLogger.Log.Fatalf("Could not create seed directory: %s", err)
+	}
+
+	today := time.Now().Format(MDateFormat)
+	var tpl, ext, comment string
+	switch lang {
+	case "", "sql":
+		tpl, ext, comment = SeedSQLTPL, "sql", "--"
+	case "go":
+		tpl, ext, comment = SeedGoTPL, "go", "//"
+	default:
+		beeLogger.Log.Fatalf("Unknown seed type '%s'. Use either sql or go", lang)
+	}
+	runmodesLine := ""
+	if runmodes != "" {
+		runmodesLine = fmt.Sprintf("%s bee:runmodes %s\n", comment, runmodes)
+	}
+
+	fpath := path.Join(seedFilePath, fmt.Sprintf("%s_%s.%s", today, sname, ext))
+	f, err := os.OpenFile(fpath, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0666)
+	if err != nil {
+		beeLogger.Log.Fatalf("Could not create seed file: %s", err)
+	}
+	defer utils.CloseFile(f)
+	content := strings.Replace(tpl, "{{Runmodes}}", runmodesLine, -1)
+	content = strings.Replace(content, "{{FuncName}}", "Seed"+utils.CamelCase(sname)+"_"+today, -1)
+	f.WriteString(content)
+	if ext == "go" {
+		// Run 'gofmt' on the generated source code
+		utils.FormatSourceCode(fpath)
+	}
+	fmt.Fprintf(w, "\t%s%screate%s\t %s%s\n", "\x1b[32m", "\x1b[1m", "\x1b[21m", fpath, "\x1b[0m")
+}
+
+const (
+	SeedSQLTPL = `-- Seed applied once by 'bee migrate seed', in a single transaction.
+-- End each statement with a semicolon at the end of a line.
+{{Runmodes}}
+-- INSERT INTO user (name) VALUES ('admin');
+`
+	SeedGoTPL = `package main
+
+import (
+	"github.com/astaxie/beego/orm"
+)
+
+{{Runmodes}}
+// {{FuncName}} is applied once by 'bee migrate seed', in a transaction.
+// Returning an error rolls it back.
+func {{FuncName}}(o orm.Ormer) error {
+	// use o.Raw("INSERT INTO ...").Exec() or o.Insert(&model) to add data
+	return nil
+}
+`
+)
@@ -0,0 +1,337 @@
+// Copyright 2013 bee authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package generate
+
+import (
+	"bytes"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/astaxie/beego/swagger"
+	"github.com/beego/bee/generate/swaggergen"
+	beeLogger "github.com/beego/bee/logger"
+	"github.com/beego/bee/logger/colors"
+	"github.com/beego/bee/utils"
+)
+
+var (
+	goModModuleRegex = regexp.MustCompile(`(?m)^module\s+"?([^"\s]+)"?`)
+	pathParamRegex   = regexp.MustCompile(`{([^}]+)}`)
+)
+
+// GenerateTest generates a test for every route of a router file, and every method of each
+// route. Tests are written to tests/<routerfile>_test.go and call beego.BeeApp.Handlers.ServeHTTP.
+func GenerateTest(routerFile, currpath string) {
+	w := colors.NewColorWriter(os.Stdout)
+
+	routerFile = routerFilePath(routerFile, currpath)
+	beeLogger.Log.Infof("Using '%s' as router file", routerFile)
+	api := swaggergen.ParseRoutes(currpath, routerFile)
+	if len(api.Paths) == 0 {
+		beeLogger.Log.Hint("Routes are read from the namespaces of the router file and the @router annotations of their controllers")
+		beeLogger.Log.Fatalf("No routes found in '%s'", routerFile)
+	}
+
+	testsPath := path.Join(currpath, "tests")
+	if err := os.MkdirAll(testsPath, 0777); err != nil {
+		beeLogger.Log.Fatalf("Could not create tests directory: %s", err)
+	}
+	packageName, hasInit := testsPackage(testsPath)
+
+	var tests bytes.Buffer
+	names := make(map[string]bool)
+	count := 0
+	for _, route := range sortedRoutes(api.Paths) {
+		for _, op := range operations(api.Paths[route]) {
+			name := testName(op.Operation, op.Method, route, names)
+			tests.WriteString(routeTest(name, op.Method, api.BasePath, route, op.Operation))
+			count++
+		}
+	}
+
+	imports, initFunc := []string{`"net/http"`, `"net/http/httptest"`, `"testing"`}, ""
+	if !hasInit {
+		imports = append(imports, `"path/filepath"`, `"runtime"`)
+		initFunc = TestInitTPL
+	}
+	if strings.Contains(tests.String(), "strings.NewReader") {
+		imports = append(imports, `"strings"`)
+	}
+	sort.Strings(imports)
+	content := strings.Replace(TestTPL, "{{packageName}}", packageName, -1)
+	content = strings.Replace(content, "{{imports}}", strings.Join(imports, "\n"), -1)
+	content = strings.Replace(content, "{{routersPkg}}", importPath(currpath, filepath.Dir(routerFile)), -1)
+	content = strings.Replace(content, "{{init}}", initFunc, -1)
+	content = strings.Replace(content, "{{tests}}", tests.String(), -1)
+
+	name := strings.TrimSuffix(filepath.Base(routerFile), ".go")
+	fpath := path.Join(testsPath, name+"_test.go")
+	if f, err := os.OpenFile(fpath, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0666); err == nil {
+		defer utils.CloseFile(f)
+		f.WriteString(content)
+		// Run 'gofmt' on the generated source code
+		utils.FormatSourceCode(fpath)
+		fmt.Fprintf(w, "\t%s%screate%s\t %s%s\n", "\x1b[32m", "\x1b[1m", "\x1b[21m", fpath, "\x1b[0m")
+	} else {
+		beeLogger.Log.Fatalf("Could not create test file: %s", err)
+	}
+	beeLogger.Log.Infof("Generated %d test(s) for %d route(s)", count, len(api.Paths))
+	if files, _ := filepath.Glob(filepath.Join(filepath.Dir(routerFile), "commentsRouter_*.go")); len(files) == 0 {
+		beeLogger.Log.Hint("Run the application once with 'bee run' so that beego generates the routes of the @router annotations")
+	}
+}
+
+// routerFilePath returns the path of the router file, routers/router.go by default.
+// A name without directory, i.e. admin, is looked up in the routers directory.
+func routerFilePath(routerFile, currpath string) string {
+	if routerFile == "" {
+		return path.Join(currpath, "routers", "router.go")
+	}
+	if !filepath.IsAbs(routerFile) {
+		routerFile = filepath.Join(currpath, routerFile)
+	}
+	if !utils.IsExist(routerFile) && filepath.Ext(routerFile) == "" {
+		routerFile = path.Join(currpath, "routers", filepath.Base(routerFile)+".go")
+	}
+	if !utils.IsExist(routerFile) {
+		beeLogger.Log.Fatalf("Router file '%s' does not exist", routerFile)
+	}
+	return routerFile
+}
+
+// testsPackage returns the package of the tests directory, and whether its tests already
+// initialize beego
+func testsPackage(dir string) (name string, hasInit bool) {
+	name = "test"
+	files, _ := filepath.Glob(filepath.Join(dir, "*_test.go"))
+	for _, file := range files {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		if f, err := parser.ParseFile(token.NewFileSet(), file, content, parser.PackageClauseOnly); err == nil {
+			name = f.Name.Name
+		}
+		hasInit = hasInit || bytes.Contains(content, []byte("beego.TestBeegoInit("))
+	}
+	return
+}
+
+// importPath returns the import path of dir according to the go.mod of the application,
+// or the GOPATH when there is none
+func importPath(currpath, dir string) string {
+	rel, err := filepath.Rel(currpath, dir)
+	if err != nil {
+		beeLogger.Log.Fatalf("Could not find the package of '%s': %s", dir, err)
+	}
+	if content, err := ioutil.ReadFile(filepath.Join(currpath, "go.mod")); err == nil {
+		if m := goModModuleRegex.FindSubmatch(content); m != nil {
+			return path.Join(string(m[1]), filepath.ToSlash(rel))
+		}
+	}
+	return path.Join(getPackagePath(currpath), filepath.ToSlash(rel))
+}
+
+func sortedRoutes(paths map[string]*swagger.Item) []string {
+	routes := make([]string, 0, len(paths))
+	for route := range paths {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+	return routes
+}
+
+// routeOperation is an operation of a route with its HTTP method
+type routeOperation struct {
+	Method    string
+	Operation *swagger.Operation
+}
+
+// operations returns the operations of a route in a stable order
+func operations(item *swagger.Item) (ops []routeOperation) {
+	for _, op := range []routeOperation{
+		{"GET", item.Get},
+		{"POST", item.Post},
+		{"PUT", item.Put},
+		{"PATCH", item.Patch},
+		{"DELETE", item.Delete},
+		{"HEAD", item.Head},
+		{"OPTIONS", item.Options},
+	} {
+		if op.Operation != nil {
+			ops = append(ops, op)
+		}
+	}
+	return
+}
+
+// testName returns a unique name for the test of an operation, based on its @Title
+func testName(op *swagger.Operation, method, route string, names map[string]bool) string {
+	base := op.OperationID
+	if base == "" {
+		base = method + " " + route
+	}
+	name := identifier(base)
+	if names[name] {
+		name += identifier(strings.ToLower(method))
+	}
+	unique := name
+	for i := 2; names[unique]; i++ {
+		unique = name + strconv.Itoa(i)
+	}
+	names[unique] = true
+	return unique
+}
+
+// identifier turns s into an exported Go identifier, i.e. ObjectController.Get into ObjectControllerGet
+func identifier(s string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// routeTest renders the test of an operation. Parameters get their default value, or a
+// sample value of their type.
+func routeTest(name, method, basePath, route string, op *swagger.Operation) string {
+	params := make(map[string]swagger.Parameter)
+	query, form := url.Values{}, url.Values{}
+	var headers []string
+	body := "nil"
+	for _, p := range op.Parameters {
+		switch p.In {
+		case "path":
+			params[p.Name] = p
+		case "query":
+			query.Set(p.Name, sampleValue(p))
+		case "formData":
+			form.Set(p.Name, sampleValue(p))
+		case "header":
+			headers = append(headers, fmt.Sprintf("r.Header.Set(%q, %q)", p.Name, sampleValue(p)))
+		case "body":
+			body = "strings.NewReader(`{}`)"
+			headers = append(headers, `r.Header.Set("Content-Type", "application/json")`)
+		}
+	}
+	if len(form) > 0 && body == "nil" {
+		body = fmt.Sprintf("strings.NewReader(%q)", form.Encode())
+		headers = append(headers, `r.Header.Set("Content-Type", "application/x-www-form-urlencoded")`)
+	}
+	target := pathParamRegex.ReplaceAllStringFunc(route, func(s string) string {
+		p, ok := params[s[1:len(s)-1]]
+		if !ok {
+			p = swagger.Parameter{Type: "string"}
+		}
+		return url.PathEscape(sampleValue(p))
+	})
+	target = strings.TrimRight(basePath, "/") + target
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	content := strings.Replace(RouteTestTPL, "{{name}}", name, -1)
+	content = strings.Replace(content, "{{method}}", method, -1)
+	content = strings.Replace(content, "{{route}}", strings.TrimRight(basePath, "/")+route, -1)
+	content = strings.Replace(content, "{{url}}", target, -1)
+	content = strings.Replace(content, "{{body}}", body, -1)
+	if len(headers) > 0 {
+		headers = append(headers, "")
+	}
+	content = strings.Replace(content, "{{headers}}", strings.Join(headers, "\n"), -1)
+	return content
+}
+
+// sampleValue returns the value a test passes for a parameter
+func sampleValue(p swagger.Parameter) string {
+	if p.Default != nil {
+		return fmt.Sprint(p.Default)
+	}
+	switch p.Type {
+	case "integer", "number":
+		return "1"
+	case "boolean":
+		return "true"
+	}
+	return "test"
+}
+
+const (
+	TestTPL = `package {{packageName}}
+
+import (
+	{{imports}}
+
+	_ "{{routersPkg}}"
+
+	"github.com/astaxie/beego"
+	"github.com/astaxie/beego/context"
+	. "github.com/smartystreets/goconvey/convey"
+)
+{{init}}{{tests}}`
+
+	TestInitTPL = `
+func init() {
+	_, file, _, _ := runtime.Caller(0)
+	apppath, _ := filepath.Abs(filepath.Dir(filepath.Join(file, ".."+string(filepath.Separator))))
+	beego.TestBeegoInit(apppath)
+}
+`
+
+	RouteTestTPL = `
+// Test{{name}} tests {{method}} {{route}}
+func Test{{name}}(t *testing.T) {
+	r, _ := http.NewRequest("{{method}}", "{{url}}", {{body}})
+	{{headers}}
+	ctx := context.NewContext()
+	ctx.Reset(httptest.NewRecorder(), r)
+	_, found := beego.BeeApp.Handlers.FindRouter(ctx)
+
+	w := httptest.NewRecorder()
+	beego.BeeApp.Handlers.ServeHTTP(w, r)
+
+	beego.Trace("testing", "Test{{name}}", "Code[%d]\n%s", w.Code, w.Body.String())
+
+	Convey("Subject: Test {{method}} {{route}}\n", t, func() {
+		Convey("The route should be registered", func() {
+			So(found, ShouldBeTrue)
+		})
+		Convey("The request should not fail", func() {
+			So(w.Code, ShouldBeLessThan, http.StatusInternalServerError)
+		})
+	})
+}
+`
+)
@@ -16,60 +16,298 @@ package generate
 
 import (
 	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
 	"os"
 	"path"
+	"strings"
 
 	beeLogger "github.com/beego/bee/logger"
 	"github.com/beego/bee/logger/colors"
 	"github.com/beego/bee/utils"
 )
 
-// recipe
-// admin/recipe
-func GenerateView(viewpath, currpath string) {
+// viewField is a field of the model shown and edited by the views
+type viewField struct {
+	Name string // name of the struct field, also used for the form input
+	Kind string // one of string, text, int, float, bool, datetime, date and time
+}
+
+// GenerateView generates the index, show, create and edit views of a model, i.e. recipe
+// or admin/recipe. The fields of the model are taken from fields, or else from the model
+// struct in the models directory. The views are served by the controller of the same name.
+func GenerateView(viewpath, fields, currpath string) {
 	w := colors.NewColorWriter(os.Stdout)
 
 	beeLogger.Log.Info("Generating view...")
 
+	modelName := strings.Title(path.Base(viewpath))
+	vfields := viewFields(modelName, fields, currpath)
+
 	absViewPath := path.Join(currpath, "views", viewpath)
 	err := os.MkdirAll(absViewPath, os.ModePerm)
 	if err != nil {
 		beeLogger.Log.Fatalf("Could not create '%s' view: %s", viewpath, err)
 	}
 
-	cfile := path.Join(absViewPath, "index.tpl")
-	if f, err := os.OpenFile(cfile, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0666); err == nil {
-		defer utils.CloseFile(f)
-		f.WriteString(cfile)
-		fmt.Fprintf(w, "\t%s%screate%s\t %s%s\n", "\x1b[32m", "\x1b[1m", "\x1b[21m", cfile, "\x1b[0m")
-	} else {
-		beeLogger.Log.Fatalf("Could not create view file: %s", err)
+	for _, view := range []struct{ file, tpl string }{
+		{"index.tpl", ViewIndexTPL},
+		{"show.tpl", ViewShowTPL},
+		{"create.tpl", ViewCreateTPL},
+		{"edit.tpl", ViewEditTPL},
+	} {
+		cfile := path.Join(absViewPath, view.file)
+		if f, err := os.OpenFile(cfile, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0666); err == nil {
+			defer utils.CloseFile(f)
+			f.WriteString(renderView(view.tpl, modelName, vfields))
+			fmt.Fprintf(w, "\t%s%screate%s\t %s%s\n", "\x1b[32m", "\x1b[1m", "\x1b[21m", cfile, "\x1b[0m")
+		} else {
+			beeLogger.Log.Fatalf("Could not create view file: %s", err)
+		}
+	}
+}
+
+// viewFields returns the fields of the -fields option, or of the model struct when it is empty
+func viewFields(modelName, fields, currpath string) (vfields []viewField) {
+	if fields != "" {
+		fds, err := parseFields(fields)
+		if err != nil {
+			beeLogger.Log.Fatalf("Could not parse fields: %s", err)
+		}
+		for _, f := range fds {
+			// relations are not edited by the forms
+			if f.Column() == "id" || f.Type == "auto" || f.Type == "pk" || f.IsRelation() {
+				continue
+			}
+			col := f.ormColumn()
+			vfields = append(vfields, viewField{Name: col.Name, Kind: columnKind(col)})
+		}
+		return
+	}
+
+	modelPath := path.Join(currpath, "models", strings.ToLower(modelName)+".go")
+	file, err := parser.ParseFile(token.NewFileSet(), modelPath, nil, 0)
+	if err != nil {
+		beeLogger.Log.Hint("Give the fields of the model, i.e. -fields=\"title:string,body:text\"")
+		beeLogger.Log.Fatalf("Could not read model '%s': %s", modelName, err)
+	}
+	var st *ast.StructType
+	if obj := file.Scope.Lookup(modelName); obj != nil {
+		if spec, ok := obj.Decl.(*ast.TypeSpec); ok {
+			st, _ = spec.Type.(*ast.StructType)
+		}
+	}
+	if st == nil {
+		beeLogger.Log.Fatalf("Could not find the struct of model '%s' in '%s'", modelName, modelPath)
+	}
+	beeLogger.Log.Infof("Using the fields of model '%s'", modelName)
+	for _, field := range st.Fields.List {
+		if len(field.Names) == 0 || !field.Names[0].IsExported() {
+			continue
+		}
+		col := modelColumn(field)
+		if col == nil || !col.hasColumn() || col.Tag.Auto || col.Tag.Pk || col.Tag.RelFk || col.Tag.RelOne || col.Name == "Id" {
+			continue
+		}
+		vfields = append(vfields, viewField{Name: col.Name, Kind: columnKind(col)})
+	}
+	return
+}
+
+// columnKind returns the kind of view field of a model field
+func columnKind(col *Column) string {
+	switch typ, _ := abstractType(col); typ {
+	case "text", "json":
+		return "text"
+	case "bool":
+		return "bool"
+	case "datetime", "date", "time":
+		return typ
+	case "float", "double", "decimal":
+		return "float"
+	case "tinyint", "smallint", "int", "bigint":
+		return "int"
 	}
+	return "string"
+}
 
-	cfile = path.Join(absViewPath, "show.tpl")
-	if f, err := os.OpenFile(cfile, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0666); err == nil {
-		defer utils.CloseFile(f)
-		f.WriteString(cfile)
-		fmt.Fprintf(w, "\t%s%screate%s\t %s%s\n", "\x1b[32m", "\x1b[1m", "\x1b[21m", cfile, "\x1b[0m")
-	} else {
-		beeLogger.Log.Fatalf("Could not create view file: %s", err)
+// renderView renders a view template for the fields of a model
+func renderView(tpl, modelName string, vfields []viewField) string {
+	var headers, cells, details, inputs []string
+	for _, f := range vfields {
+		headers = append(headers, "\t\t\t\t<th>"+f.Name+"</th>")
+		cells = append(cells, "\t\t\t\t<td>"+fieldValue(f)+"</td>")
+		details = append(details, "\t\t<dt>"+f.Name+"</dt>\n\t\t<dd>"+fieldValue(f)+"</dd>")
+		inputs = append(inputs, fieldInput(f))
 	}
+	content := strings.Replace(tpl, "{{viewModel}}", modelName, -1)
+	content = strings.Replace(content, "{{viewHeaders}}", strings.Join(headers, "\n"), -1)
+	content = strings.Replace(content, "{{viewCells}}", strings.Join(cells, "\n"), -1)
+	content = strings.Replace(content, "{{viewColumns}}", fmt.Sprint(len(vfields)+2), -1)
+	content = strings.Replace(content, "{{viewDetails}}", strings.Join(details, "\n"), -1)
+	content = strings.Replace(content, "{{viewInputs}}", strings.Join(inputs, "\n"), -1)
+	return content
+}
 
-	cfile = path.Join(absViewPath, "create.tpl")
-	if f, err := os.OpenFile(cfile, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0666); err == nil {
-		defer utils.CloseFile(f)
-		f.WriteString(cfile)
-		fmt.Fprintf(w, "\t%s%screate%s\t %s%s\n", "\x1b[32m", "\x1b[1m", "\x1b[21m", cfile, "\x1b[0m")
-	} else {
-		beeLogger.Log.Fatalf("Could not create view file: %s", err)
+// fieldValue returns the template action showing a field of the current item
+func fieldValue(f viewField) string {
+	switch f.Kind {
+	case "bool":
+		return "{{if ." + f.Name + "}}Yes{{else}}No{{end}}"
+	case "datetime":
+		return `{{date .` + f.Name + ` "Y-m-d H:i:s"}}`
+	case "date":
+		return `{{date .` + f.Name + ` "Y-m-d"}}`
+	case "time":
+		return `{{date .` + f.Name + ` "H:i:s"}}`
 	}
+	return "{{." + f.Name + "}}"
+}
 
-	cfile = path.Join(absViewPath, "edit.tpl")
-	if f, err := os.OpenFile(cfile, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0666); err == nil {
-		defer utils.CloseFile(f)
-		f.WriteString(cfile)
-		fmt.Fprintf(w, "\t%s%screate%s\t %s%s\n", "\x1b[32m", "\x1b[1m", "\x1b[21m", cfile, "\x1b[0m")
-	} else {
-		beeLogger.Log.Fatalf("Could not create view file: %s", err)
+// fieldInput returns the form input of a field, with its validation error
+func fieldInput(f viewField) string {
+	value := "{{.Item." + f.Name + "}}"
+	var input string
+	switch f.Kind {
+	case "text":
+		input = `<textarea id="` + f.Name + `" name="` + f.Name + `">` + value + `</textarea>`
+	case "bool":
+		input = `<input type="checkbox" id="` + f.Name + `" name="` + f.Name + `" value="true"{{if .Item.` + f.Name + `}} checked{{end}}>`
+	case "datetime", "date", "time":
+		layout, typ := "2006-01-02T15:04:05", "datetime-local"
+		if f.Kind == "date" {
+			layout, typ = "2006-01-02", "date"
+		} else if f.Kind == "time" {
+			layout, typ = "15:04:05", "time"
+		}
+		value = `{{if not .Item.` + f.Name + `.IsZero}}{{.Item.` + f.Name + `.Format "` + layout + `"}}{{end}}`
+		input = `<input type="` + typ + `" step="1" id="` + f.Name + `" name="` + f.Name + `" value="` + value + `">`
+	case "int":
+		input = `<input type="number" step="1" id="` + f.Name + `" name="` + f.Name + `" value="` + value + `">`
+	case "float":
+		input = `<input type="number" step="any" id="` + f.Name + `" name="` + f.Name + `" value="` + value + `">`
+	default:
+		input = `<input type="text" id="` + f.Name + `" name="` + f.Name + `" value="` + value + `">`
 	}
+	return "\t\t<p>\n" +
+		"\t\t\t<label for=\"" + f.Name + "\">" + f.Name + "</label>\n" +
+		"\t\t\t" + input + "\n" +
+		"\t\t\t{{with .Errors." + f.Name + "}}<span class=\"error\">{{.}}</span>{{end}}\n" +
+		"\t\t</p>"
 }
+
+const (
+	ViewIndexTPL = `<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="utf-8">
+	<title>{{viewModel}}</title>
+</head>
+<body>
+	<h1>{{viewModel}}</h1>
+	<p><a href="{{urlfor "{{viewModel}}Controller.New"}}">New {{viewModel}}</a></p>
+	<table>
+		<thead>
+			<tr>
+				<th>Id</th>
+{{viewHeaders}}
+				<th></th>
+			</tr>
+		</thead>
+		<tbody>
+		{{range .Items}}
+			<tr>
+				<td><a href="{{urlfor "{{viewModel}}Controller.GetOne" ":id" .Id}}">{{.Id}}</a></td>
+{{viewCells}}
+				<td>
+					<a href="{{urlfor "{{viewModel}}Controller.Edit" ":id" .Id}}">Edit</a>
+					<form action="{{urlfor "{{viewModel}}Controller.GetOne" ":id" .Id}}/delete" method="post" style="display: inline">
+						{{$.xsrfdata}}
+						<button type="submit">Delete</button>
+					</form>
+				</td>
+			</tr>
+		{{else}}
+			<tr><td colspan="{{viewColumns}}">Nothing yet.</td></tr>
+		{{end}}
+		</tbody>
+	</table>
+	{{if .paginator.HasPages}}
+	<ul class="pagination">
+		{{if .paginator.HasPrev}}
+		<li><a href="{{.paginator.PageLinkFirst}}">First</a></li>
+		<li><a href="{{.paginator.PageLinkPrev}}">&laquo;</a></li>
+		{{end}}
+		{{range $page := .paginator.Pages}}
+		<li{{if $.paginator.IsActive $page}} class="active"{{end}}><a href="{{$.paginator.PageLink $page}}">{{$page}}</a></li>
+		{{end}}
+		{{if .paginator.HasNext}}
+		<li><a href="{{.paginator.PageLinkNext}}">&raquo;</a></li>
+		<li><a href="{{.paginator.PageLinkLast}}">Last</a></li>
+		{{end}}
+	</ul>
+	{{end}}
+</body>
+</html>
+`
+	ViewShowTPL = `<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="utf-8">
+	<title>{{viewModel}} {{.Item.Id}}</title>
+</head>
+<body>
+	<h1>{{viewModel}} {{.Item.Id}}</h1>
+	{{with .Item}}
+	<dl>
+{{viewDetails}}
+	</dl>
+	{{end}}
+	<p>
+		<a href="{{urlfor "{{viewModel}}Controller.Edit" ":id" .Item.Id}}">Edit</a>
+		<a href="{{urlfor "{{viewModel}}Controller.GetAll"}}">Back</a>
+	</p>
+</body>
+</html>
+`
+	ViewCreateTPL = `<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="utf-8">
+	<title>New {{viewModel}}</title>
+</head>
+<body>
+	<h1>New {{viewModel}}</h1>
+	{{with .Error}}<p class="error">{{.}}</p>{{end}}
+	<form action="{{urlfor "{{viewModel}}Controller.Post"}}" method="post">
+		{{.xsrfdata}}
+{{viewInputs}}
+		<button type="submit">Create</button>
+	</form>
+	<p><a href="{{urlfor "{{viewModel}}Controller.GetAll"}}">Back</a></p>
+</body>
+</html>
+`
+	ViewEditTPL = `<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="utf-8">
+	<title>Edit {{viewModel}} {{.Item.Id}}</title>
+</head>
+<body>
+	<h1>Edit {{viewModel}} {{.Item.Id}}</h1>
+	{{with .Error}}<p class="error">{{.}}</p>{{end}}
+	<form action="{{urlfor "{{viewModel}}Controller.Edit" ":id" .Item.Id}}" method="post">
+		{{.xsrfdata}}
+{{viewInputs}}
+		<button type="submit">Update</button>
+	</form>
+	<p>
+		<a href="{{urlfor "{{viewModel}}Controller.GetOne" ":id" .Item.Id}}">Show</a>
+		<a href="{{urlfor "{{viewModel}}Controller.GetAll"}}">Back</a>
+	</p>
+</body>
+</html>
+`
+)
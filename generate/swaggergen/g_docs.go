@@ -157,11 +157,24 @@ func parsePackageFromDir(path string) error {
 
 // GenerateDocs generates documentations for a given path.
 func GenerateDocs(curpath string) {
+	parseRouterFile(curpath, filepath.Join(curpath, "routers", "router.go"))
+	writeDocs(curpath)
+}
+
+// ParseRoutes parses a router file and the @router annotations of the controllers it includes,
+// and returns the API they describe. Paths are relative to the base path of the API.
+func ParseRoutes(curpath, routerFile string) swagger.Swagger {
+	parseRouterFile(curpath, routerFile)
+	return rootapi
+}
+
+// parseRouterFile analyses the API comments and namespaces of a router file
+func parseRouterFile(curpath, routerFile string) {
 	fset := token.NewFileSet()
 
-	f, err := parser.ParseFile(fset, filepath.Join(curpath, "routers", "router.go"), nil, parser.ParseComments)
+	f, err := parser.ParseFile(fset, routerFile, nil, parser.ParseComments)
 	if err != nil {
-		beeLogger.Log.Fatalf("Error while parsing router.go: %s", err)
+		beeLogger.Log.Fatalf("Error while parsing %s: %s", filepath.Base(routerFile), err)
 	}
 
 	rootapi.Infos = swagger.Information{}
@@ -320,6 +333,10 @@ func GenerateDocs(curpath string) {
 			}
 		}
 	}
+}
+
+// writeDocs writes the API to swagger/swagger.json and swagger/swagger.yml
+func writeDocs(curpath string) {
 	os.Mkdir(path.Join(curpath, "swagger"), 0755)
 	fd, err := os.Create(path.Join(curpath, "swagger", "swagger.json"))
 	if err != nil {
@@ -0,0 +1,115 @@
+// Copyright 2013 bee authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CustomRegionBegin and CustomRegionEnd mark the lines of a generated file
+// that are kept when the file is generated again
+const (
+	CustomRegionBegin = "// bee:custom begin"
+	CustomRegionEnd   = "// bee:custom end"
+)
+
+// customRegion is the content of a custom region
+type customRegion struct {
+	name    string
+	content string
+}
+
+// customRegions returns the custom regions of src, in order
+func customRegions(src string) (regions []customRegion, err error) {
+	var current *customRegion
+	for n, line := range strings.SplitAfter(src, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, CustomRegionBegin):
+			name := strings.TrimSpace(strings.TrimPrefix(trimmed, CustomRegionBegin))
+			if current != nil {
+				return nil, fmt.Errorf("line %d: custom region '%s' begins inside region '%s'", n+1, name, current.name)
+			}
+			if name == "" {
+				return nil, fmt.Errorf("line %d: custom region without a name", n+1)
+			}
+			for _, r := range regions {
+				if r.name == name {
+					return nil, fmt.Errorf("line %d: custom region '%s' is declared twice", n+1, name)
+				}
+			}
+			current = &customRegion{name: name}
+		case strings.HasPrefix(trimmed, CustomRegionEnd):
+			if current == nil {
+				return nil, fmt.Errorf("line %d: custom region ends without beginning", n+1)
+			}
+			regions = append(regions, *current)
+			current = nil
+		case current != nil:
+			current.content += line
+		}
+	}
+	if current != nil {
+		return nil, fmt.Errorf("custom region '%s' does not end", current.name)
+	}
+	return
+}
+
+// KeepCustomRegions fills the custom regions of the newly generated src with
+// their content in old, the file src replaces. Regions src has no place for are
+// appended to it, and their names returned.
+func KeepCustomRegions(src, old string) (string, []string, error) {
+	regions, err := customRegions(old)
+	if err != nil || len(regions) == 0 {
+		return src, nil, err
+	}
+	contents := make(map[string]string)
+	for _, r := range regions {
+		contents[r.name] = r.content
+	}
+
+	var out strings.Builder
+	kept := make(map[string]bool)
+	skip := false
+	for _, line := range strings.SplitAfter(src, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, CustomRegionBegin):
+			out.WriteString(line)
+			name := strings.TrimSpace(strings.TrimPrefix(trimmed, CustomRegionBegin))
+			if content, ok := contents[name]; ok && !kept[name] {
+				out.WriteString(content)
+				kept[name] = true
+				skip = true
+			}
+		case strings.HasPrefix(trimmed, CustomRegionEnd):
+			out.WriteString(line)
+			skip = false
+		case !skip:
+			out.WriteString(line)
+		}
+	}
+
+	var orphans []string
+	for _, r := range regions {
+		if kept[r.name] {
+			continue
+		}
+		orphans = append(orphans, r.name)
+		out.WriteString(fmt.Sprintf("\n%s %s\n%s%s\n", CustomRegionBegin, r.name, r.content, CustomRegionEnd))
+	}
+	return out.String(), orphans, nil
+}
@@ -0,0 +1,103 @@
+// Copyright 2013 bee authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+const generatedSource = `package models
+
+// bee:custom begin imports
+// bee:custom end
+
+type User struct {
+	Id int
+	// bee:custom begin fields
+	// bee:custom end
+}
+`
+
+func TestKeepCustomRegions(t *testing.T) {
+	tests := []struct {
+		name        string
+		old         string
+		want        string
+		wantOrphans []string
+		wantErr     bool
+	}{
+		{
+			name: "no regions",
+			old:  "package models\n",
+			want: generatedSource,
+		},
+		{
+			name: "filled regions",
+			old:  "package models\n\n// bee:custom begin fields\n\tAge int\n// bee:custom end\n\n// bee:custom begin imports\nimport \"time\"\n// bee:custom end\n",
+			want: "package models\n\n// bee:custom begin imports\nimport \"time\"\n// bee:custom end\n\ntype User struct {\n\tId int\n\t// bee:custom begin fields\n\tAge int\n\t// bee:custom end\n}\n",
+		},
+		{
+			name:        "orphan regions",
+			old:         "// bee:custom begin methods\nfunc (u *User) Name() string { return \"\" }\n// bee:custom end\n",
+			want:        generatedSource + "\n// bee:custom begin methods\nfunc (u *User) Name() string { return \"\" }\n// bee:custom end\n",
+			wantOrphans: []string{"methods"},
+		},
+		{
+			name:    "nested regions",
+			old:     "// bee:custom begin fields\n// bee:custom begin imports\n// bee:custom end\n// bee:custom end\n",
+			wantErr: true,
+		},
+		{
+			name:    "unnamed region",
+			old:     "// bee:custom begin\n// bee:custom end\n",
+			wantErr: true,
+		},
+		{
+			name:    "duplicate region",
+			old:     "// bee:custom begin fields\n// bee:custom end\n// bee:custom begin fields\n// bee:custom end\n",
+			wantErr: true,
+		},
+		{
+			name:    "end without begin",
+			old:     "// bee:custom end\n",
+			wantErr: true,
+		},
+		{
+			name:    "unterminated region",
+			old:     "// bee:custom begin fields\n\tAge int\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		got, orphans, err := KeepCustomRegions(generatedSource, tt.old)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: no error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+		if !reflect.DeepEqual(orphans, tt.wantOrphans) {
+			t.Errorf("%s: orphans %v, want %v", tt.name, orphans, tt.wantOrphans)
+		}
+	}
+}